@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/control"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCtlCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ctl <" + strings.Join(control.Commands, "|") + ">",
+		Short: "実行中の run デーモンを操作",
+		Long: `制御ソケット経由で、実行中の run コマンドを操作します。
+
+  status    デーモンの状態（一時停止、直近と次回の sync / fixup）を表示
+  sync      すぐに同期を実行
+  fixup     すぐに fixup を実行
+  snapshot  すぐに VHDX のスナップショットを作成
+  pause     定期 sync / fixup を一時停止
+  resume    一時停止を解除
+  reload    設定ファイルを再読み込み（同期間隔・fixup 間隔も更新）
+  stop      実行中の処理の完了後に終了
+
+制御ソケットは Ops リポジトリの .git/fcsm/control.sock に作成されます。`,
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: control.Commands,
+		RunE:      runCtl,
+	}
+
+	cmd.Flags().String("socket", "", "制御ソケットのパス（デフォルト: 設定の Ops リポジトリから決定）")
+	cmd.Flags().Duration("timeout", 30*time.Minute, "応答を待つ最大時間（sync / fixup の完了を含む）")
+
+	return cmd
+}
+
+func runCtl(cmd *cobra.Command, args []string) error {
+	socketPath, _ := cmd.Flags().GetString("socket")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	if socketPath == "" {
+		configPath, _ := cmd.Flags().GetString("config")
+		if configPath == "" {
+			configPath = "config.hjson"
+		}
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		socketPath, err = controlSocketPath(cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	resp, err := control.Send(ctx, socketPath, control.Request{Command: args[0]})
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return fmt.Errorf("%s", resp.Error)
+	}
+
+	if resp.Status != nil {
+		writeDaemonStatus(os.Stdout, resp.Status)
+		return nil
+	}
+	fmt.Printf("✓ %s\n", resp.Message)
+	return nil
+}
+
+// writeDaemonStatus はデーモンの状態を人が読める形式で出力する。
+func writeDaemonStatus(w io.Writer, status *control.Status) {
+	state := "running"
+	if status.Paused {
+		state = "paused"
+	}
+	if status.DryRun {
+		state += " (dry run)"
+	}
+
+	fmt.Fprintf(w, "Daemon: %s (pid %d, up %v)\n", state, status.PID, time.Since(status.StartedAt).Round(time.Second))
+	if status.ConfigPath != "" {
+		fmt.Fprintf(w, "  Config: %s\n", status.ConfigPath)
+	}
+	fmt.Fprintf(w, "  Dev Repository: %s\n", status.DevRepoPath)
+	fmt.Fprintf(w, "  Ops Repository: %s\n", status.OpsRepoPath)
+	fmt.Fprintf(w, "  Sync:  every %s, last %s, next %s%s\n",
+		status.SyncInterval, describeRun(status.LastSync), describeNext(status.NextSync, status.Paused),
+		describeFailures(status.SyncFailures, status.SyncCircuitOpen))
+	fmt.Fprintf(w, "  Fixup: every %s, last %s, next %s%s\n",
+		status.FixupInterval, describeRun(status.LastFixup), describeNext(status.NextFixup, status.Paused),
+		describeFailures(status.FixupFailures, status.FixupCircuitOpen))
+	if status.LastSnapshot != nil {
+		fmt.Fprintf(w, "  Snapshot: last %s\n", describeRun(status.LastSnapshot))
+	}
+}
+
+func describeRun(info *control.RunInfo) string {
+	if info == nil {
+		return "never"
+	}
+	result := "ok"
+	if info.Error != "" {
+		result = "failed: " + info.Error
+	}
+	return fmt.Sprintf("%s (%s)", info.Time.Local().Format("15:04:05"), result)
+}
+
+// describeFailures は連続失敗の状態を表示用に返す。失敗していない場合は空文字列。
+func describeFailures(failures int, circuitOpen bool) string {
+	switch {
+	case circuitOpen:
+		return fmt.Sprintf(" (stopped after %d consecutive failures, probing)", failures)
+	case failures > 0:
+		return fmt.Sprintf(" (%d consecutive failures, backing off)", failures)
+	default:
+		return ""
+	}
+}
+
+func describeNext(next time.Time, paused bool) string {
+	if paused {
+		return "paused"
+	}
+	if next.IsZero() {
+		return "-"
+	}
+	return next.Local().Format("15:04:05")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"fixup-commit-sync-manager/internal/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+func NewDoctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "実行環境と設定を診断",
+		Long: `git のバージョン、リポジトリの状態、ロックファイル、一時停止ファイル、マウントポイント、
+ディスクの空き容量、設定、ログファイルの書き込み可否を検査し、結果と対処方法を表示します。
+リポジトリやファイルは変更しません。失敗した検査がある場合は終了コード 1 で終了します。`,
+		RunE: runDoctor,
+	}
+	cmd.Flags().String("format", "text", "出力形式（text / json）")
+	return cmd
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = "config.hjson"
+	}
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unsupported format: %s (use text or json)", format)
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	report := doctor.New(configPath).Run(ctx)
+	if err := writeDoctorReport(cmd.OutOrStdout(), report, format); err != nil {
+		return err
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d checks failed", report.Failed)
+	}
+	return nil
+}
+
+// writeDoctorReport は診断結果を format（text / json）で出力する。
+func writeDoctorReport(w io.Writer, report *doctor.Report, format string) error {
+	switch format {
+	case "", "text":
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	default:
+		return fmt.Errorf("unsupported format: %s (use text or json)", format)
+	}
+
+	for _, check := range report.Checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(string(check.Status)), check.Name, check.Message)
+		if check.Fix != "" {
+			fmt.Fprintf(w, "       fix: %s\n", check.Fix)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", report.Passed, report.Warnings, report.Failed)
+	return nil
+}
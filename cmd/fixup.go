@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"fixup-commit-sync-manager/internal/config"
 	"fixup-commit-sync-manager/internal/fixup"
@@ -18,6 +20,11 @@ func NewFixupCmd() *cobra.Command {
 	}
 
 	cmd.Flags().Bool("continuous", false, "設定された間隔で継続的に fixup を実行")
+	cmd.Flags().String("format", "text", "--dry-run 時の実行計画の出力形式（text / json）")
+	cmd.Flags().Bool("all-branches", false, "fixup! コミットや退避された作業が残るすべての Ops ブランチを処理")
+
+	cmd.AddCommand(NewFixupUndoCmd())
+	cmd.AddCommand(NewFixupCompactCmd())
 
 	return cmd
 }
@@ -31,46 +38,74 @@ func runFixup(cmd *cobra.Command, args []string) error {
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
 	verbose, _ := cmd.Flags().GetBool("verbose")
 	continuous, _ := cmd.Flags().GetBool("continuous")
+	format, _ := cmd.Flags().GetString("format")
+	allBranches, _ := cmd.Flags().GetBool("all-branches")
 
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	if dryRun {
-		cfg.DryRun = true
-	}
-	if verbose {
-		cfg.Verbose = true
+	applyFlags := func(cfg *config.Config) {
+		if dryRun {
+			cfg.DryRun = true
+		}
+		if verbose {
+			cfg.Verbose = true
+		}
+		if allBranches {
+			cfg.FixupAllBranches = true
+		}
 	}
+	applyFlags(cfg)
 
 	fixupManager := fixup.NewFixupManager(cfg)
 
+	ctx, stop := shutdownContext()
+	defer stop()
+
 	if continuous {
-		return fixupManager.RunContinuousFixup()
+		stopMetrics := startMetricsExport(cfg)
+		defer stopMetrics()
+		return fixupManager.RunContinuousFixup(ctx, watchConfig(ctx, configPath, cfg, applyFlags))
+	}
+
+	if cfg.FixupAllBranches {
+		return runAllBranchesFixup(ctx, fixupManager, cfg)
 	}
 
-	return runSingleFixup(fixupManager, cfg)
+	if cfg.DryRun {
+		plan, err := fixupManager.Plan(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to plan fixup: %w", err)
+		}
+		return writeFixupPlan(cmd.OutOrStdout(), plan, format)
+	}
+
+	return runSingleFixup(ctx, fixupManager, cfg)
 }
 
-func runSingleFixup(fixupManager *fixup.FixupManager, cfg *config.Config) error {
+func runSingleFixup(ctx context.Context, fixupManager *fixup.FixupManager, cfg *config.Config) error {
 	if cfg.Verbose {
 		fmt.Println("Starting fixup operation...")
 		fmt.Printf("Ops Repository: %s\n", cfg.OpsRepoPath)
 		fmt.Println("Using dynamic branch tracking from Dev repository")
 		fmt.Printf("Autosquash Enabled: %t\n", cfg.AutosquashEnabled)
+		fmt.Printf("Fixup Strategy: %s\n", cfg.FixupStrategy)
 	}
 
-	if cfg.DryRun {
-		fmt.Println("[DRY RUN] Would perform fixup operation")
-		return nil
-	}
-
-	result, err := fixupManager.RunFixup()
+	result, err := fixupManager.RunFixup(ctx)
 	if err != nil {
 		return fmt.Errorf("fixup failed: %w", err)
 	}
 
+	printUnrelatedChanges(result.UnrelatedChanges)
+
+	if result.Compaction != nil && len(result.Compaction.Groups) > 0 {
+		fmt.Println("Compacted sync commits:")
+		printCompaction(result.Compaction)
+	}
+
 	if result.FilesModified == 0 {
 		if cfg.Verbose {
 			fmt.Println("No uncommitted changes found - fixup skipped")
@@ -87,12 +122,36 @@ func runSingleFixup(fixupManager *fixup.FixupManager, cfg *config.Config) error
 		fmt.Printf("  Fixup commit: %s\n", result.FixupCommitHash[:8])
 	}
 
-	if result.CommitHash != "" {
+	if result.CommitHash != "" && len(result.Fixups) <= 1 {
 		fmt.Printf("  Base commit: %s\n", result.CommitHash[:8])
 	}
 
-	if cfg.AutosquashEnabled {
-		fmt.Println("  Autosquash rebase: completed")
+	if len(result.Fixups) > 1 {
+		fmt.Printf("  Fixup commits: %d\n", len(result.Fixups))
+		for _, fixup := range result.Fixups {
+			fmt.Printf("    %s -> %s (%d files)\n", fixup.Commit[:8], fixup.Target[:8], len(fixup.Files))
+		}
+	}
+
+	if result.Amended {
+		fmt.Println("  Amended last sync commit directly (no rebase)")
+		fmt.Printf("  Backup: %s (restore with 'fixup undo')\n", result.BackupRef)
+	} else if cfg.AutosquashEnabled {
+		if result.BackupRef != "" {
+			fmt.Println("  Autosquash rebase: completed")
+			fmt.Printf("  Backup: %s (restore with 'fixup undo')\n", result.BackupRef)
+		} else {
+			fmt.Println("  Autosquash rebase: skipped (no unpublished commits to rewrite)")
+		}
+	}
+
+	if len(result.Rewrites) > 0 {
+		fmt.Printf("  Rewritten commits: %d\n", len(result.Rewrites))
+		if cfg.Verbose {
+			for _, entry := range result.Rewrites {
+				fmt.Printf("    %s -> %s\n", entry.Old[:8], entry.New[:8])
+			}
+		}
 	}
 
 	if cfg.Verbose {
@@ -101,3 +160,75 @@ func runSingleFixup(fixupManager *fixup.FixupManager, cfg *config.Config) error
 
 	return nil
 }
+
+func runAllBranchesFixup(ctx context.Context, fixupManager *fixup.FixupManager, cfg *config.Config) error {
+	if cfg.DryRun {
+		pending, err := fixupManager.PendingBranches(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list pending branches: %w", err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("[DRY RUN] No branches with pending fixups")
+			return nil
+		}
+		fmt.Printf("[DRY RUN] Would fixup %d branches:\n", len(pending))
+		for _, branch := range pending {
+			fmt.Printf("  %s: %s\n", branch.Branch, describePending(branch))
+		}
+		return nil
+	}
+
+	results, err := fixupManager.RunAllBranches(ctx)
+	if len(results) == 0 && err == nil {
+		fmt.Println("No branches with pending fixups")
+		return nil
+	}
+
+	failed := 0
+	fmt.Printf("Fixup across %d branches:\n", len(results))
+	for _, branch := range results {
+		if branch.Err != nil {
+			failed++
+			fmt.Printf("  ✗ %s (%s): %v\n", branch.Branch, describePending(branch), branch.Err)
+			continue
+		}
+
+		result := branch.Result
+		fmt.Printf("  ✓ %s (%s)\n", branch.Branch, describePending(branch))
+		if result.FilesModified > 0 {
+			fmt.Printf("      Files modified: %d, fixup commits: %d\n", result.FilesModified, len(result.Fixups))
+		}
+		if result.PendingFixups > 0 {
+			fmt.Printf("      Squashed pending fixup commits: %d\n", result.PendingFixups)
+		}
+		if result.BackupRef != "" {
+			fmt.Printf("      Backup: %s\n", result.BackupRef)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("fixup failed: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("fixup failed on %d of %d branches", failed, len(results))
+	}
+	return nil
+}
+
+// describePending はブランチに残っていた作業の種類を表示用にまとめる。
+func describePending(branch fixup.BranchResult) string {
+	var reasons []string
+	if branch.PendingFixups > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d pending fixup commits", branch.PendingFixups))
+	}
+	if branch.Stashed {
+		reasons = append(reasons, "stashed changes")
+	}
+	if branch.Dirty {
+		reasons = append(reasons, "uncommitted changes")
+	}
+	if len(reasons) == 0 {
+		return "unknown"
+	}
+	return strings.Join(reasons, ", ")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/fixup"
+
+	"github.com/spf13/cobra"
+)
+
+func NewFixupCompactCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact",
+		Short: "連続する同期コミットを時間枠ごとに一つにまとめる",
+		Long: `Ops リポジトリのカレントブランチで、同じ時間枠（hour / day）に作成された連続する同期コミットを
+一つのコミットにまとめます。人が作成したコミットと公開済みのコミットは変更しません。
+まとめる前の状態はバックアップ ref に保存され、'fixup undo' で戻せます。`,
+		Args: cobra.NoArgs,
+		RunE: runFixupCompact,
+	}
+
+	cmd.Flags().String("window", "", "時間枠（hour / day、デフォルト: 設定の compactionWindow）")
+
+	return cmd
+}
+
+func runFixupCompact(cmd *cobra.Command, args []string) error {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = "config.hjson"
+	}
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	window, _ := cmd.Flags().GetString("window")
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if window != "" {
+		cfg.CompactionWindow = window
+	}
+	if cfg.CompactionWindow == "" {
+		return fmt.Errorf("compaction window is not configured: set compactionWindow or use --window")
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would compact sync commits per %s\n", cfg.CompactionWindow)
+		return nil
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	result, err := fixup.NewFixupManager(cfg).RunCompaction(ctx)
+	if err != nil {
+		return fmt.Errorf("compaction failed: %w", err)
+	}
+
+	if len(result.Groups) == 0 {
+		fmt.Println("No sync commits to compact")
+		return nil
+	}
+
+	fmt.Println("✓ Compaction completed successfully")
+	printCompaction(result)
+	return nil
+}
+
+// printCompaction はまとめた同期コミットの一覧を表示する。
+func printCompaction(result *fixup.CompactionResult) {
+	for _, group := range result.Groups {
+		fmt.Printf("  %s: %d sync commits -> %s\n", group.Window.Format("2006-01-02 15:04"), len(group.Commits), group.NewCommit[:8])
+	}
+	fmt.Printf("  Backup: %s (restore with 'fixup undo')\n", result.BackupRef)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"fixup-commit-sync-manager/internal/fixup"
+)
+
+// writeFixupPlan は fixup の実行計画を format（text / json）で出力する。
+func writeFixupPlan(w io.Writer, plan *fixup.FixupPlan, format string) error {
+	switch format {
+	case "", "text":
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	default:
+		return fmt.Errorf("unsupported format: %s (use text or json)", format)
+	}
+
+	fmt.Fprintf(w, "[DRY RUN] Fixup plan for branch %s\n", plan.Branch)
+	if plan.CurrentBranch != plan.Branch {
+		fmt.Fprintf(w, "  Ops branch would be switched from %s to %s (plan is based on %s)\n", plan.CurrentBranch, plan.Branch, plan.CurrentBranch)
+	}
+	if plan.Upstream != "" {
+		fmt.Fprintf(w, "  Upstream: %s\n", plan.Upstream)
+	}
+	fmt.Fprintf(w, "  Fixup target: %s\n", plan.FixupTarget)
+	fmt.Fprintf(w, "  Fixup strategy: %s\n", plan.FixupStrategy)
+
+	if len(plan.Compactions) > 0 {
+		fmt.Fprintf(w, "\nSync commits to compact:\n")
+		for _, compaction := range plan.Compactions {
+			fmt.Fprintf(w, "  %s: %d commits\n", compaction.Window.Format("2006-01-02 15:04"), len(compaction.Commits))
+			for _, commit := range compaction.Commits {
+				fmt.Fprintf(w, "    %s %s\n", shortHash(commit.Hash), commit.Subject)
+			}
+		}
+	}
+
+	if len(plan.UnrelatedChanges) > 0 {
+		fmt.Fprintf(w, "\nUnrelated ops changes (not staged): %d\n", len(plan.UnrelatedChanges))
+		for _, path := range plan.UnrelatedChanges {
+			fmt.Fprintf(w, "  ? %s\n", path)
+		}
+	}
+
+	if len(plan.Files) == 0 {
+		fmt.Fprintf(w, "\nNo changes to fixup\n")
+		return nil
+	}
+
+	fmt.Fprintf(w, "\nFiles to stage: %d\n", len(plan.Files))
+	for _, path := range plan.Files {
+		fmt.Fprintf(w, "  %s\n", path)
+	}
+
+	if plan.Approximate {
+		fmt.Fprintf(w, "\nNote: sync commits are compacted before the fixup. The fixups and rebase below are estimated\n")
+		fmt.Fprintf(w, "from the commits before compaction; actual targets, todo and rewritten hashes will differ.\n")
+	}
+
+	fmt.Fprintf(w, "\nFixup commits: %d\n", len(plan.Fixups))
+	for _, planned := range plan.Fixups {
+		fmt.Fprintf(w, "  -> %s %s (%d files)\n", shortHash(planned.Target), planned.Subject, len(planned.Files))
+		for _, path := range planned.Files {
+			fmt.Fprintf(w, "       %s\n", path)
+		}
+	}
+
+	switch {
+	case plan.AmendHead:
+		fmt.Fprintf(w, "\nHEAD would be amended directly (no rebase):\n")
+		for _, commit := range plan.Rewritten {
+			fmt.Fprintf(w, "  %s %s\n", shortHash(commit.Hash), commit.Subject)
+		}
+		return nil
+	case !plan.Autosquash:
+		fmt.Fprintf(w, "\nAutosquash rebase: disabled\n")
+		return nil
+	case plan.AutosquashSkipped != "":
+		fmt.Fprintf(w, "\nAutosquash rebase: skipped (%s)\n", plan.AutosquashSkipped)
+		return nil
+	}
+
+	onto := "root"
+	if plan.RebaseOnto != "" {
+		onto = shortHash(plan.RebaseOnto)
+	}
+	fmt.Fprintf(w, "\nAutosquash rebase onto %s:\n", onto)
+	for _, entry := range plan.Todo {
+		commit := "(new)"
+		if entry.Commit != "" {
+			commit = shortHash(entry.Commit)
+		}
+		fmt.Fprintf(w, "  %-8s %-8s %s\n", entry.Action, commit, entry.Subject)
+	}
+
+	fmt.Fprintf(w, "\nCommits that would get new hashes: %d\n", len(plan.Rewritten))
+	for _, commit := range plan.Rewritten {
+		fmt.Fprintf(w, "  %s %s\n", shortHash(commit.Hash), commit.Subject)
+	}
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
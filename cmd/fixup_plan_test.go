@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/fixup"
+)
+
+func TestWriteFixupPlan(t *testing.T) {
+	plan := &fixup.FixupPlan{
+		Branch:        "feature",
+		CurrentBranch: "feature",
+		FixupTarget:   "base",
+		Files:         []string{"main.cpp"},
+		Fixups:        []fixup.PlannedFixup{{Target: "1111111111", Subject: "Add main", Files: []string{"main.cpp"}}},
+		Autosquash:    true,
+		RebaseOnto:    "0000000000",
+		Todo: []fixup.TodoEntry{
+			{Action: "pick", Commit: "1111111111", Subject: "Add main"},
+			{Action: "fixup", Subject: "fixup! Add main"},
+			{Action: "pick", Commit: "2222222222", Subject: "Edit main"},
+		},
+		Rewritten: []fixup.PlannedCommit{{Hash: "1111111111", Subject: "Add main"}, {Hash: "2222222222", Subject: "Edit main"}},
+	}
+
+	var text bytes.Buffer
+	if err := writeFixupPlan(&text, plan, "text"); err != nil {
+		t.Fatalf("writeFixupPlan(text) failed: %v", err)
+	}
+	for _, want := range []string{"Files to stage: 1", "-> 11111111 Add main", "fixup    (new)    fixup! Add main", "Commits that would get new hashes: 2"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Text plan should contain %q:\n%s", want, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeFixupPlan(&out, plan, "json"); err != nil {
+		t.Fatalf("writeFixupPlan(json) failed: %v", err)
+	}
+	var decoded fixup.FixupPlan
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("JSON plan should be valid: %v", err)
+	}
+	if len(decoded.Todo) != 3 || decoded.Todo[1].Commit != "" || decoded.Rewritten[1].Hash != "2222222222" {
+		t.Errorf("Unexpected decoded plan: %+v", decoded)
+	}
+
+	plan.Compactions = []fixup.PlannedCompaction{{Commits: []fixup.PlannedCommit{{Hash: "3333333333"}, {Hash: "4444444444"}}}}
+	plan.Approximate = true
+	text.Reset()
+	if err := writeFixupPlan(&text, plan, "text"); err != nil {
+		t.Fatalf("writeFixupPlan(text) failed: %v", err)
+	}
+	if !strings.Contains(text.String(), "estimated\nfrom the commits before compaction") {
+		t.Errorf("Plan with compactions should be marked as estimated:\n%s", text.String())
+	}
+
+	if err := writeFixupPlan(&out, plan, "yaml"); err == nil {
+		t.Error("Unsupported format should be rejected")
+	}
+}
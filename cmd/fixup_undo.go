@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+
+	"fixup-commit-sync-manager/internal/backup"
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/repolock"
+
+	"github.com/spf13/cobra"
+)
+
+func NewFixupUndoCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "autosquash rebase 前の状態に Ops ブランチを戻す",
+		Long: `autosquash rebase の前に作成したバックアップ ref (refs/fcsm/backup/<branch>/<timestamp>) から
+Ops リポジトリのブランチを復元します。--to を省略した場合は最新のバックアップを使用します。
+復元前の状態も新しいバックアップとして保存されるため、undo 自体も取り消せます。`,
+		Args: cobra.NoArgs,
+		RunE: runFixupUndo,
+	}
+
+	cmd.Flags().String("to", "", "復元するバックアップ（タイムスタンプまたは ref 名）")
+	cmd.Flags().String("branch", "", "対象ブランチ（デフォルト: Ops のカレントブランチ）")
+	cmd.Flags().Bool("list", false, "バックアップの一覧を表示")
+
+	return cmd
+}
+
+func runFixupUndo(cmd *cobra.Command, args []string) error {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = "config.hjson"
+	}
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	to, _ := cmd.Flags().GetString("to")
+	branch, _ := cmd.Flags().GetString("branch")
+	list, _ := cmd.Flags().GetBool("list")
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	runner := git.NewConfiguredRunner(cfg, cfg.OpsRepoPath)
+	if branch == "" {
+		branch, err = git.Output(ctx, runner, "branch", "--show-current")
+		if err != nil {
+			return fmt.Errorf("failed to get ops current branch: %w", err)
+		}
+	}
+
+	backups := backup.NewManager(cfg, runner)
+
+	if list {
+		entries, err := backups.List(ctx, branch)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Printf("No backups found for branch %s\n", branch)
+			return nil
+		}
+		fmt.Printf("Backups for branch %s (newest first):\n", branch)
+		for _, entry := range entries {
+			fmt.Printf("  %s  %s  %s\n", entry.Name(), entry.Commit[:8], entry.Time.Local().Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	}
+
+	target, err := backups.Resolve(ctx, branch, to)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would restore branch %s to %s (%s)\n", branch, target.Name(), target.Commit[:8])
+		return nil
+	}
+
+	lock, err := repolock.NewLocker(cfg, runner).Acquire(ctx, "undo")
+	if err != nil {
+		return fmt.Errorf("failed to lock ops repository: %w", err)
+	}
+	defer lock.Release()
+
+	saved, err := backups.Restore(lock.Context(), branch, target)
+	if lost := lock.Lost(); lost != nil && err != nil {
+		return fmt.Errorf("undo stopped because %w: %v", lost, err)
+	}
+	if err != nil {
+		return fmt.Errorf("undo failed: %w", err)
+	}
+
+	fmt.Printf("✓ Restored branch %s to %s (%s)\n", branch, target.Name(), target.Commit[:8])
+	fmt.Printf("  Previous state saved as: %s\n", saved)
+	return nil
+}
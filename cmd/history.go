@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
+
+	"github.com/spf13/cobra"
+)
+
+func NewHistoryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "sync / fixup / snapshot の実行履歴を表示",
+		Long: `Dev リポジトリの .git/fcsm/history.jsonl に記録された sync / fixup / snapshot の実行結果を表示します。
+変更が無かった回を含めて sync / fixup を実行した回を記録します（一時停止中の回は記録しません）。
+
+--since / --until には日付（2006-01-02）、日時（2006-01-02 15:04、RFC 3339）、
+または現在からさかのぼる期間（30m、24h、7d）を指定できます。日付のみの --until はその日の終わりまでを含みます。`,
+		Args: cobra.NoArgs,
+		RunE: runHistory,
+	}
+
+	cmd.Flags().String("type", "", "種類で絞り込む（"+strings.Join(history.Types, " / ")+"、error は失敗した実行）")
+	cmd.Flags().String("branch", "", "ブランチで絞り込む")
+	cmd.Flags().String("since", "", "この時刻以降に開始した実行のみ表示")
+	cmd.Flags().String("until", "", "この時刻より前に開始した実行のみ表示")
+	cmd.Flags().Int("limit", 50, "新しいものから表示する最大件数（0 で無制限）")
+	cmd.Flags().String("format", "text", "出力形式（text / json）")
+	cmd.RegisterFlagCompletionFunc("type", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return history.Types, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return cmd
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = "config.hjson"
+	}
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	format, _ := cmd.Flags().GetString("format")
+	limit, _ := cmd.Flags().GetInt("limit")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unsupported format: %s (use text or json)", format)
+	}
+
+	filter, err := historyFilter(cmd, time.Now())
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	entries, err := history.NewLog(git.NewConfiguredRunner(cfg, cfg.DevRepoPath)).Read(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	return writeHistory(cmd.OutOrStdout(), entries, format, verbose)
+}
+
+// historyFilter はフラグから絞り込み条件を作成する。相対的な期間は now を基準とする。
+func historyFilter(cmd *cobra.Command, now time.Time) (history.Filter, error) {
+	var filter history.Filter
+	filter.Type, _ = cmd.Flags().GetString("type")
+	filter.Branch, _ = cmd.Flags().GetString("branch")
+	since, _ := cmd.Flags().GetString("since")
+	until, _ := cmd.Flags().GetString("until")
+
+	if filter.Type != "" {
+		valid := false
+		for _, t := range history.Types {
+			valid = valid || t == filter.Type
+		}
+		if !valid {
+			return filter, fmt.Errorf("unsupported type: %s (use %s)", filter.Type, strings.Join(history.Types, ", "))
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseHistoryTime(since, now, false); err != nil {
+		return filter, fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseHistoryTime(until, now, true); err != nil {
+		return filter, fmt.Errorf("invalid --until: %w", err)
+	}
+	return filter, nil
+}
+
+// parseHistoryTime は日付、日時、または now からさかのぼる期間（30m、24h、7d）を時刻に変換する。
+// endOfDay が true の場合、日付のみの指定はその翌日の 0 時（その日の終わり）とする。空の場合はゼロ値を返す。
+func parseHistoryTime(value string, now time.Time, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q (use 2006-01-02, 2006-01-02 15:04, RFC 3339, or a duration like 24h or 7d)", value)
+}
+
+// writeHistory は実行履歴を format（text / json）で出力する。verbose の場合は text でファイルの一覧も出力する。
+func writeHistory(w io.Writer, entries []history.Entry, format string, verbose bool) error {
+	switch format {
+	case "", "text":
+	case "json":
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	default:
+		return fmt.Errorf("unsupported format: %s (use text or json)", format)
+	}
+
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "No history entries")
+		return nil
+	}
+
+	for _, entry := range entries {
+		result := "ok"
+		if entry.Failed() {
+			result = "FAILED"
+		}
+
+		var details []string
+		if len(entry.Files) > 0 {
+			details = append(details, fmt.Sprintf("%d files", len(entry.Files)))
+		}
+		for _, commit := range entry.Commits {
+			details = append(details, shortHash(commit))
+		}
+		if entry.Snapshot != "" {
+			details = append(details, entry.Snapshot)
+		}
+		if len(details) == 0 && !entry.Failed() {
+			details = append(details, "no changes")
+		}
+
+		fmt.Fprintf(w, "%s  %-8s  %-20s  %-6s  %8v  %s\n", entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Type, orDash(entry.Branch), result, entry.Duration.Round(time.Millisecond), strings.Join(details, "  "))
+		if entry.Failed() {
+			fmt.Fprintf(w, "    error (%s): %s\n", entry.Cause, entry.Error)
+		}
+		if verbose {
+			if entry.BackupRef != "" {
+				fmt.Fprintf(w, "    backup: %s\n", entry.BackupRef)
+			}
+			for _, path := range entry.Files {
+				fmt.Fprintf(w, "    %s\n", path)
+			}
+		}
+	}
+	return nil
+}
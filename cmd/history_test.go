@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/history"
+)
+
+func TestParseHistoryTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		value    string
+		endOfDay bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "24h", want: now.Add(-24 * time.Hour)},
+		{value: "7d", want: time.Date(2024, 3, 3, 12, 0, 0, 0, time.Local)},
+		{value: "2024-03-01", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2024-03-01", endOfDay: true, want: time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local)},
+		{value: "2024-03-01 09:30", want: time.Date(2024, 3, 1, 9, 30, 0, 0, time.Local)},
+		{value: "2024-03-01T09:30:00Z", want: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)},
+		{value: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseHistoryTime(tt.value, now, tt.endOfDay)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHistoryTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseHistoryTime(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryFilterRejectsUnknownType(t *testing.T) {
+	cmd := NewHistoryCmd()
+	cmd.Flags().Set("type", "merge")
+	if _, err := historyFilter(cmd, time.Now()); err == nil {
+		t.Error("Unknown type should be rejected")
+	}
+}
+
+func TestWriteHistory(t *testing.T) {
+	entries := []history.Entry{
+		{Time: time.Now(), Type: history.TypeSync, Duration: 1500 * time.Millisecond, Branch: "main",
+			Files: []string{"a.cpp", "b.cpp"}, Commits: []string{"0123456789abcdef"}},
+		{Time: time.Now(), Type: history.TypeFixup, Branch: "main", Error: "rebase failed", Cause: "rebase"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeHistory(&buf, entries, "text", true); err != nil {
+		t.Fatalf("writeHistory() failed: %v", err)
+	}
+	for _, want := range []string{"2 files  01234567", "FAILED", "error (rebase): rebase failed", "    a.cpp"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Output should contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := writeHistory(&buf, nil, "json", false); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("Empty JSON history = %q, %v; want []", buf.String(), err)
+	}
+}
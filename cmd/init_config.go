@@ -208,11 +208,16 @@ func generateHJSONTemplate(cfg *config.Config) string {
   "fixupInterval": "%s",      // Fixupコミット実行間隔
   "fixupMessagePrefix": "%s", // Fixupコミットメッセージプレフィックス
   "autosquashEnabled": %t,    // --autosquashフラグを有効化
+  "backupKeep": %d,           // ブランチごとに残す rebase 前のバックアップ ref の数（0=数で削除しない）
+  "backupMaxAge": "%s",       // これより古いバックアップ ref を削除（空=経過時間で削除しない、例: 720h）
   // 注意: ブランチ設定は動的追従 - Devリポジトリの現在ブランチを自動追跡
 
   // === リトライとエラー処理 ===
   "maxRetries": %d,           // 最大リトライ回数
   "retryDelay": "%s",         // リトライ間隔
+  "failureBackoffMax": "%s",  // 継続実行で失敗が続いた場合の実行間隔の上限
+  "circuitBreakerThreshold": %d,  // この回数続けて失敗したら実行を止め、通知して原因の解消を確認する（0=無効）
+  "circuitProbeInterval": "%s",   // 実行を止めている間の確認間隔
   "notifyOnError": {          // エラー通知設定（オプション）
     // "slackWebhookUrl": "https://hooks.slack.com/..."
   },
@@ -238,8 +243,13 @@ func generateHJSONTemplate(cfg *config.Config) string {
 		cfg.FixupInterval,
 		cfg.FixupMsgPrefix,
 		cfg.AutosquashEnabled,
+		cfg.BackupKeep,
+		cfg.BackupMaxAge,
 		cfg.MaxRetries,
 		cfg.RetryDelay,
+		cfg.FailureBackoffMax,
+		cfg.CircuitBreakerThreshold,
+		cfg.CircuitProbeInterval,
 		cfg.LogLevel,
 		cfg.LogFilePath,
 		cfg.Verbose,
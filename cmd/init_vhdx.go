@@ -1,13 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
 	"fixup-commit-sync-manager/internal/vhdx"
 
 	"github.com/spf13/cobra"
@@ -125,45 +125,27 @@ func cloneRepository(sourcePath, targetPath string, gitExecutable string) error
 		return fmt.Errorf("source is not a git repository: %s", sourcePath)
 	}
 
-	cmd := exec.Command(gitExecutable, "clone", "--local", "--single-branch", sourcePath, targetPath)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git clone failed: %w, output: %s", err, string(output))
+	runner := git.NewRunner(gitExecutable, "")
+	if _, err := runner.Run(context.Background(), "clone", "--local", "--single-branch", sourcePath, targetPath); err != nil {
+		return fmt.Errorf("git clone failed: %w", err)
 	}
 
 	return nil
 }
 
 func setupOpsRepository(opsRepoPath string, cfg *config.Config) error {
-	originalDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-	defer os.Chdir(originalDir)
-
-	if err := os.Chdir(opsRepoPath); err != nil {
-		return fmt.Errorf("failed to change to ops repository directory: %w", err)
-	}
-
-	gitCmd := func(args ...string) error {
-		cmd := exec.Command(cfg.GitExecutable, args...)
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("git %s failed: %w, output: %s", strings.Join(args, " "), err, string(output))
-		}
-		return nil
-	}
+	runner := git.NewConfiguredRunner(cfg, opsRepoPath)
 
-	originalRemoteUrl, err := getOriginalRemoteUrl(cfg.GitExecutable)
+	originalRemoteUrl, err := getOriginalRemoteUrl(runner)
 	if err != nil {
 		return fmt.Errorf("failed to get original remote URL: %w", err)
 	}
 
-	if err := gitCmd("remote", "set-url", "origin", originalRemoteUrl); err != nil {
+	if _, err := runner.Run(context.Background(), "remote", "set-url", "origin", originalRemoteUrl); err != nil {
 		return fmt.Errorf("failed to set remote URL: %w", err)
 	}
 
-	currentBranch, err := getCurrentBranch(cfg.GitExecutable)
+	currentBranch, err := getCurrentBranch(runner)
 	if err != nil {
 		return fmt.Errorf("failed to get current branch: %w", err)
 	}
@@ -174,22 +156,20 @@ func setupOpsRepository(opsRepoPath string, cfg *config.Config) error {
 	return nil
 }
 
-func getOriginalRemoteUrl(gitExecutable string) (string, error) {
-	cmd := exec.Command(gitExecutable, "remote", "get-url", "origin")
-	output, err := cmd.Output()
+func getOriginalRemoteUrl(runner git.Runner) (string, error) {
+	url, err := git.Output(context.Background(), runner, "remote", "get-url", "origin")
 	if err != nil {
 		return "", fmt.Errorf("failed to get remote URL: %w", err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	return url, nil
 }
 
-func getCurrentBranch(gitExecutable string) (string, error) {
-	cmd := exec.Command(gitExecutable, "branch", "--show-current")
-	output, err := cmd.Output()
+func getCurrentBranch(runner git.Runner) (string, error) {
+	branch, err := git.Output(context.Background(), runner, "branch", "--show-current")
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch: %w", err)
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	return branch, nil
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
 )
 
 func TestCloneRepository(t *testing.T) {
@@ -76,12 +77,7 @@ func TestGetCurrentBranch(t *testing.T) {
 		t.Fatalf("Failed to create test repository: %v", err)
 	}
 
-	originalDir, _ := os.Getwd()
-	defer os.Chdir(originalDir)
-
-	os.Chdir(repoDir)
-
-	branch, err := getCurrentBranch("git")
+	branch, err := getCurrentBranch(git.NewRunner("git", repoDir))
 	if err != nil {
 		t.Errorf("getCurrentBranch() failed: %v", err)
 	}
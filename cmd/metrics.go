@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/metrics"
+)
+
+// startMetricsExport は設定に従って /metrics の提供と textfile への書き出しを開始し、停止する関数を返す。
+// 長時間動作するモード（run、sync/fixup --continuous）から呼ぶ。開始できない場合も処理は続ける。
+func startMetricsExport(cfg *config.Config) func() {
+	var stops []func()
+
+	if cfg.MetricsAddr != "" {
+		server, err := metrics.Serve(cfg.MetricsAddr, metrics.Default)
+		if err != nil {
+			fmt.Printf("Warning: metrics endpoint is unavailable: %v\n", err)
+		} else {
+			fmt.Printf("Serving metrics on http://%s/metrics\n", server.Addr())
+			stops = append(stops, func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				server.Shutdown(ctx)
+			})
+		}
+	}
+
+	if cfg.MetricsTextfilePath != "" {
+		interval, err := cfg.GetMetricsTextfileIntervalDuration()
+		if err != nil || interval <= 0 {
+			interval = 15 * time.Second // デフォルト値。
+		}
+		writer := metrics.StartTextfileWriter(cfg.MetricsTextfilePath, interval, metrics.Default)
+		fmt.Printf("Writing metrics to %s every %s\n", cfg.MetricsTextfilePath, interval)
+		stops = append(stops, writer.Stop)
+	}
+
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+// watchConfig は継続実行中に設定ファイル path を監視し、変更された設定を返すチャネルを返す。
+// prepare はコマンドラインフラグによる上書きを再適用する。不正な変更は表示して無視し、現在の設定を維持する。
+func watchConfig(ctx context.Context, path string, cfg *config.Config, prepare func(*config.Config)) <-chan *config.Config {
+	watcher := config.NewWatcher(path, cfg)
+	watcher.Prepare = prepare
+	watcher.Check = checkReloadable
+	watcher.OnError = func(err error) {
+		fmt.Printf("Ignoring invalid configuration change in %s: %v\n", path, err)
+	}
+	watcher.OnChange = func(old, next *config.Config) {
+		if settings := restartRequiredSettings(old, next); len(settings) > 0 {
+			fmt.Printf("Warning: changes to %s take effect after restart\n", strings.Join(settings, ", "))
+		}
+	}
+	return watcher.Watch(ctx)
+}
+
+// restartRequiredSettings は old から next で変更された設定のうち、再起動するまで反映されないものの名前を返す。
+func restartRequiredSettings(old, next *config.Config) []string {
+	var settings []string
+	if old.HTTPAPIAddr != next.HTTPAPIAddr || old.HTTPAPIToken != next.HTTPAPIToken {
+		settings = append(settings, "httpApiAddr/httpApiToken")
+	}
+	if old.MetricsAddr != next.MetricsAddr {
+		settings = append(settings, "metricsAddr")
+	}
+	if old.MetricsTextfilePath != next.MetricsTextfilePath || old.MetricsTextfileInterval != next.MetricsTextfileInterval {
+		settings = append(settings, "metricsTextfilePath/metricsTextfileInterval")
+	}
+	return settings
+}
+
+// checkReloadable は old から next への変更を実行中に反映できるかを確認する。
+// 制御ソケットやリポジトリのロックは起動時のリポジトリに結び付いているため、リポジトリのパスの変更は受け付けない。
+func checkReloadable(old, next *config.Config) error {
+	var errs []error
+	if old.DevRepoPath != next.DevRepoPath {
+		errs = append(errs, fmt.Errorf("devRepoPath cannot be changed while running (%s -> %s), restart to apply it", old.DevRepoPath, next.DevRepoPath))
+	}
+	if old.OpsRepoPath != next.OpsRepoPath {
+		errs = append(errs, fmt.Errorf("opsRepoPath cannot be changed while running (%s -> %s), restart to apply it", old.OpsRepoPath, next.OpsRepoPath))
+	}
+	return errors.Join(errs...)
+}
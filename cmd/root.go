@@ -20,9 +20,13 @@ var rootCmd = &cobra.Command{
 
 利用可能なサブコマンド:
 - run              : メイン機能を実行（初期化から定期実行まで一括処理）
+- ctl              : 実行中の run を操作（状態表示、即時 sync / fixup、一時停止、設定再読み込み、停止）
+- status           : 現在の同期の状態を表示（ブランチ、同期待ちの変更、直近の実行結果など）
+- history          : sync / fixup / snapshot の実行履歴を表示（種類、ブランチ、期間で絞り込み）
 - init             : 初期セットアップ（作業ディレクトリ作成、設定生成）
 - init-config      : 対話型ウィザードで設定ファイルを作成
 - validate-config  : 設定ファイルの構文と内容を検証
+- doctor           : 実行環境と設定を診断（git、リポジトリ、ロック、ディスク容量など）
 - init-vhdx        : VHDX ファイルを初期化して Ops リポジトリをセットアップ
 - mount-vhdx       : VHDX ファイルをマウント
 - unmount-vhdx     : VHDX ファイルをアンマウント
@@ -67,9 +71,13 @@ func init() {
 	})
 
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(NewCtlCmd())
+	rootCmd.AddCommand(NewStatusCmd())
+	rootCmd.AddCommand(NewHistoryCmd())
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(NewInitConfigCmd())
 	rootCmd.AddCommand(NewValidateConfigCmd())
+	rootCmd.AddCommand(NewDoctorCmd())
 	rootCmd.AddCommand(NewInitVHDXCmd())
 	rootCmd.AddCommand(NewMountVHDXCmd())
 	rootCmd.AddCommand(NewUnmountVHDXCmd())
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -14,81 +13,15 @@ import (
 	"syscall"
 	"time"
 
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/fixup"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/sync"
 	"fixup-commit-sync-manager/internal/vhdx"
 
 	"github.com/spf13/cobra"
 )
 
-// Config は設定を表す簡易構造体。
-type Config struct {
-	DevRepoPath         string   `json:"devRepoPath"`
-	OpsRepoPath         string   `json:"opsRepoPath"`
-	SyncInterval        string   `json:"syncInterval"`
-	FixupInterval       string   `json:"fixupInterval"`
-	IncludeExtensions   []string `json:"includeExtensions"`
-	ExcludePatterns     []string `json:"excludePatterns"`
-	VhdxPath            string   `json:"vhdxPath"`
-	MountPoint          string   `json:"mountPoint"`
-	VhdxSize            string   `json:"vhdxSize"`
-	EncryptionEnabled   bool     `json:"encryptionEnabled"`
-	AutosquashEnabled   bool     `json:"autosquashEnabled"`
-	LogLevel            string   `json:"logLevel"`
-	LogFilePath         string   `json:"logFilePath"`
-	Verbose             bool     `json:"verbose"`
-}
-
-// Validate は設定の検証を行う。
-func (c *Config) Validate() error {
-	if c.DevRepoPath == "" {
-		return fmt.Errorf("devRepoPath が設定されていません")
-	}
-	if c.OpsRepoPath == "" {
-		return fmt.Errorf("opsRepoPath が設定されていません")
-	}
-	return nil
-}
-
-// loadConfigFromFile はファイルから設定を読み込む。
-func loadConfigFromFile(configPath string) (*Config, error) {
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("設定ファイル読み込みエラー: %v", err)
-	}
-
-	// コメント行を除去（改良版）。
-	lines := strings.Split(string(data), "\n")
-	var cleanedLines []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		// 空行やコメント行をスキップ。
-		if trimmed == "" || strings.HasPrefix(trimmed, "//") {
-			continue
-		}
-		
-		// 行内コメントを除去。
-		if idx := strings.Index(line, "//"); idx != -1 {
-			// 文字列リテラル内でないことを確認（簡易チェック）。
-			before := line[:idx]
-			quoteCount := strings.Count(before, "\"") - strings.Count(before, "\\\"")
-			if quoteCount%2 == 0 { // 偶数個の引用符 = 文字列外。
-				line = strings.TrimSpace(line[:idx])
-			}
-		}
-		
-		if strings.TrimSpace(line) != "" {
-			cleanedLines = append(cleanedLines, line)
-		}
-	}
-	cleanedData := strings.Join(cleanedLines, "\n")
-
-	var config Config
-	if err := json.Unmarshal([]byte(cleanedData), &config); err != nil {
-		return nil, fmt.Errorf("設定解析エラー: %v", err)
-	}
-
-	return &config, nil
-}
-
 // runCmd はメイン機能を実行するコマンド。
 var runCmd = &cobra.Command{
 	Use:   "run",
@@ -203,7 +136,7 @@ func runInitializationFlow(ctx context.Context, args *RunArgs) error {
 	}
 
 	// 4. VHDX初期化（有効な場合のみ）。
-	if !args.NoVhdx && cfg.VhdxPath != "" {
+	if !args.NoVhdx && cfg.VHDXPath != "" {
 		if err := initializeVhdx(cfg, args); err != nil {
 			return fmt.Errorf("VHDX初期化エラー: %v", err)
 		}
@@ -215,12 +148,12 @@ func runInitializationFlow(ctx context.Context, args *RunArgs) error {
 	}
 
 	// 6. 初回同期。
-	if err := performInitialSync(cfg, args); err != nil {
+	if err := performInitialSync(ctx, cfg, args); err != nil {
 		return fmt.Errorf("初回同期エラー: %v", err)
 	}
 
 	// 7. 初回スナップショット作成。
-	if !args.NoVhdx && cfg.VhdxPath != "" {
+	if !args.NoVhdx && cfg.VHDXPath != "" {
 		if err := createInitialSnapshot(cfg, args); err != nil {
 			log.Printf("初回スナップショット作成に失敗しました: %v", err)
 			// スナップショット作成の失敗は継続可能。
@@ -288,9 +221,9 @@ func checkAndInitConfig(args *RunArgs) error {
 func validateConfiguration(configPath string, verbose bool) error {
 	log.Println("設定を検証しています...")
 	
-	cfg, err := loadConfigFromFile(configPath)
+	cfg, err := loadConfiguration(configPath)
 	if err != nil {
-		return fmt.Errorf("設定読み込みエラー: %v", err)
+		return err
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -301,21 +234,21 @@ func validateConfiguration(configPath string, verbose bool) error {
 		log.Println("設定検証が完了しました:")
 		log.Printf("  Dev Repository: %s", cfg.DevRepoPath)
 		log.Printf("  Ops Repository: %s", cfg.OpsRepoPath)
-		if cfg.VhdxPath != "" {
-			log.Printf("  VHDX Path: %s", cfg.VhdxPath)
+		if cfg.VHDXPath != "" {
+			log.Printf("  VHDX Path: %s", cfg.VHDXPath)
 		}
 	}
 
 	return nil
 }
 
-// loadConfiguration は設定を読み込み。
-func loadConfiguration(configPath string) (*Config, error) {
+// loadConfiguration は設定を読み込み。他のコマンドと同じ HJSON ローダーと既定値を使用する。
+func loadConfiguration(configPath string) (*config.Config, error) {
 	if configPath == "" {
 		configPath = "config.hjson"
 	}
 	
-	cfg, err := loadConfigFromFile(configPath)
+	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("設定読み込みエラー: %v", err)
 	}
@@ -324,29 +257,29 @@ func loadConfiguration(configPath string) (*Config, error) {
 }
 
 // initializeVhdx はVHDXの初期化。
-func initializeVhdx(cfg *Config, args *RunArgs) error {
-	if cfg.VhdxPath == "" {
+func initializeVhdx(cfg *config.Config, args *RunArgs) error {
+	if cfg.VHDXPath == "" {
 		return nil
 	}
 
 	// VHDXファイルが既に存在するかチェック。
-	if _, err := os.Stat(cfg.VhdxPath); err == nil {
-		log.Printf("VHDX ファイルは既に存在します: %s", cfg.VhdxPath)
+	if _, err := os.Stat(cfg.VHDXPath); err == nil {
+		log.Printf("VHDX ファイルは既に存在します: %s", cfg.VHDXPath)
 		return nil
 	}
 
 	log.Println("VHDX を初期化しています...")
 	
 	// VHDXサイズのデフォルト値設定。
-	vhdxSize := cfg.VhdxSize
+	vhdxSize := cfg.VHDXSize
 	if vhdxSize == "" {
 		vhdxSize = "10GB"
 	}
 	
 	// 実際のVHDXマネージャーを使用してVHDX作成。
-	vhdxManager := vhdx.NewVHDXManager(cfg.VhdxPath, cfg.MountPoint, vhdxSize, cfg.EncryptionEnabled)
+	vhdxManager := vhdx.NewVHDXManager(cfg.VHDXPath, cfg.MountPoint, vhdxSize, cfg.EncryptionEnabled)
 	
-	log.Printf("VHDX作成: %s (サイズ: %s)", cfg.VhdxPath, vhdxSize)
+	log.Printf("VHDX作成: %s (サイズ: %s)", cfg.VHDXPath, vhdxSize)
 	
 	// VHDX作成実行。
 	if err := vhdxManager.CreateVHDX(); err != nil {
@@ -360,25 +293,25 @@ func initializeVhdx(cfg *Config, args *RunArgs) error {
 }
 
 // mountVhdx はVHDXのマウント。
-func mountVhdx(cfg *Config, args *RunArgs) error {
-	if cfg.VhdxPath == "" {
+func mountVhdx(cfg *config.Config, args *RunArgs) error {
+	if cfg.VHDXPath == "" {
 		return nil
 	}
 
 	log.Println("VHDX をマウントしています...")
 	
 	// VHDXサイズのデフォルト値設定。
-	vhdxSize := cfg.VhdxSize
+	vhdxSize := cfg.VHDXSize
 	if vhdxSize == "" {
 		vhdxSize = "10GB"
 	}
 	
 	// 実際のVHDXマネージャーを使用してマウント。
-	vhdxManager := vhdx.NewVHDXManager(cfg.VhdxPath, cfg.MountPoint, vhdxSize, cfg.EncryptionEnabled)
+	vhdxManager := vhdx.NewVHDXManager(cfg.VHDXPath, cfg.MountPoint, vhdxSize, cfg.EncryptionEnabled)
 	
 	// VHDXファイルが存在するかチェック。
-	if _, err := os.Stat(cfg.VhdxPath); os.IsNotExist(err) {
-		log.Printf("警告: VHDXファイルが見つかりません: %s", cfg.VhdxPath)
+	if _, err := os.Stat(cfg.VHDXPath); os.IsNotExist(err) {
+		log.Printf("警告: VHDXファイルが見つかりません: %s", cfg.VHDXPath)
 		log.Println("VHDXファイルの作成を試行します...")
 		
 		// VHDX作成を試行。
@@ -388,7 +321,7 @@ func mountVhdx(cfg *Config, args *RunArgs) error {
 			return nil
 		}
 		
-		log.Printf("VHDXファイルを作成しました: %s", cfg.VhdxPath)
+		log.Printf("VHDXファイルを作成しました: %s", cfg.VHDXPath)
 	}
 	
 	// VHDXマウント実行。
@@ -411,55 +344,70 @@ func mountVhdx(cfg *Config, args *RunArgs) error {
 }
 
 // performInitialSync は初回同期を実行。
-func performInitialSync(cfg *Config, args *RunArgs) error {
+func performInitialSync(ctx context.Context, cfg *config.Config, args *RunArgs) error {
 	log.Println("初回同期を実行しています...")
 
-	// Ops リポジトリが存在しない場合は Dev からクローン。
-	opsRepoPath := cfg.OpsRepoPath
-	if cfg.VhdxPath != "" && cfg.MountPoint != "" {
-		// VHDXマウントポイントが実際に利用可能かチェック。
-		if _, err := os.Stat(cfg.MountPoint); err == nil {
-			// Windowsドライブレター形式のマウントポイントに対応（例: "Q:" → "Q:\\devBaseName"）
-			devBaseName := filepath.Base(cfg.DevRepoPath)
-			opsRepoPath, _ = filepath.Abs(filepath.Join(cfg.MountPoint, devBaseName))
-			log.Printf("VHDXマウントポイントを使用: %s", opsRepoPath)
-		} else {
-			// VHDXマウントポイントが利用できない場合
-			if isVHDXPath(cfg.OpsRepoPath, cfg.MountPoint) {
-				// 設定のopsRepoPathもVHDXパスの場合、ローカルフォールバックパスを生成
-				opsRepoPath = generateLocalFallbackPath(cfg.DevRepoPath, cfg.OpsRepoPath)
-				log.Printf("VHDXマウントポイントが利用できないため、ローカルフォールバックパスを使用: %s", opsRepoPath)
-			} else {
-				log.Printf("VHDXマウントポイントが利用できないため、設定のopsRepoPathを使用: %s", cfg.OpsRepoPath)
-			}
-		}
-	} else if isVHDXPath(cfg.OpsRepoPath, cfg.MountPoint) {
-		// VHDX設定がないが、opsRepoPathがVHDXパスの場合もフォールバック
-		opsRepoPath = generateLocalFallbackPath(cfg.DevRepoPath, cfg.OpsRepoPath)
-		log.Printf("opsRepoPathがVHDXパスのため、ローカルフォールバックパスを使用: %s", opsRepoPath)
+	engineCfg := engineConfig(cfg, args)
+	if engineCfg.OpsRepoPath != cfg.OpsRepoPath {
+		log.Printf("Ops リポジトリとして %s を使用します", engineCfg.OpsRepoPath)
 	}
 
-	if _, err := os.Stat(filepath.Join(opsRepoPath, ".git")); os.IsNotExist(err) {
+	// Ops リポジトリが存在しない場合は Dev からクローン。
+	if _, err := os.Stat(filepath.Join(engineCfg.OpsRepoPath, ".git")); os.IsNotExist(err) {
 		log.Println("Ops リポジトリが存在しません。Dev リポジトリからクローンします")
-		if err := cloneRepositorySimple(cfg.DevRepoPath, opsRepoPath); err != nil {
+		if err := cloneRepositorySimple(cfg.DevRepoPath, engineCfg.OpsRepoPath, cfg.GitExecutable); err != nil {
 			return fmt.Errorf("リポジトリクローンエラー: %v", err)
 		}
 	}
 
-	// 同期実行（簡易実装）。
-	log.Printf("同期: %s -> %s", cfg.DevRepoPath, opsRepoPath)
+	log.Printf("同期: %s -> %s", cfg.DevRepoPath, engineCfg.OpsRepoPath)
 	
 	if args.DryRun {
 		log.Println("プレビューモードで同期を実行します")
 		return nil
 	}
 
+	result, err := sync.NewFileSyncer(engineCfg).Sync(ctx)
+	if err != nil {
+		return fmt.Errorf("同期エラー: %v", err)
+	}
+	logSyncResult(result)
+
 	log.Println("初回同期が完了しました")
 	return nil
 }
 
+// resolveOpsRepoPath は VHDX のマウント状態に応じて、実際に使用する Ops リポジトリのパスを返す。
+func resolveOpsRepoPath(cfg *config.Config) string {
+	if cfg.VHDXPath != "" && cfg.MountPoint != "" {
+		// VHDXマウントポイントが実際に利用可能かチェック。
+		if _, err := os.Stat(cfg.MountPoint); err == nil {
+			// Windowsドライブレター形式のマウントポイントに対応（例: "Q:" → "Q:\\devBaseName"）
+			devBaseName := filepath.Base(cfg.DevRepoPath)
+			opsRepoPath, _ := filepath.Abs(filepath.Join(cfg.MountPoint, devBaseName))
+			return opsRepoPath
+		}
+	}
+
+	// VHDXマウントポイントが利用できず、設定のopsRepoPathがVHDXパスの場合はローカルフォールバックパスを使用。
+	if isVHDXPath(cfg.OpsRepoPath, cfg.MountPoint) {
+		return generateLocalFallbackPath(cfg.DevRepoPath, cfg.OpsRepoPath)
+	}
+	return cfg.OpsRepoPath
+}
+
+// engineConfig は同期・fixup エンジンに渡す設定を返す。
+// 設定を複製し、実際の Ops リポジトリのパスと run コマンドのフラグを反映する。
+func engineConfig(cfg *config.Config, args *RunArgs) *config.Config {
+	engineCfg := *cfg
+	engineCfg.OpsRepoPath = resolveOpsRepoPath(cfg)
+	engineCfg.DryRun = cfg.DryRun || args.DryRun
+	engineCfg.Verbose = cfg.Verbose || args.Verbose
+	return &engineCfg
+}
+
 // cloneRepositorySimple はリポジトリをクローン。
-func cloneRepositorySimple(srcPath, destPath string) error {
+func cloneRepositorySimple(srcPath, destPath, gitExecutable string) error {
 	// ディレクトリ作成（ドライブルートの場合は作成をスキップ）。
 	parentDir := filepath.Dir(destPath)
 	
@@ -475,10 +423,13 @@ func cloneRepositorySimple(srcPath, destPath string) error {
 	}
 
 	// git clone 実行 (ローカルクローン)。
-	cmd := fmt.Sprintf("git clone %s %s", srcPath, destPath)
-	log.Printf("クローン実行: %s", cmd)
-	
-	return runCommand(cmd)
+	log.Printf("クローン実行: %s -> %s", srcPath, destPath)
+	runner := git.NewRunner(gitExecutable, "")
+	if _, err := runner.Run(context.Background(), "clone", "--local", srcPath, destPath); err != nil {
+		return fmt.Errorf("git clone エラー: %w", err)
+	}
+
+	return nil
 }
 
 // isWindowsDriveRoot はWindowsドライブルート（例: "Q:"）かどうかを判定する。
@@ -512,8 +463,8 @@ func generateLocalFallbackPath(devRepoPath, originalOpsPath string) string {
 }
 
 // createInitialSnapshot は初回スナップショットを作成。
-func createInitialSnapshot(cfg *Config, args *RunArgs) error {
-	if cfg.VhdxPath == "" {
+func createInitialSnapshot(cfg *config.Config, args *RunArgs) error {
+	if cfg.VHDXPath == "" {
 		return nil
 	}
 
@@ -521,117 +472,120 @@ func createInitialSnapshot(cfg *Config, args *RunArgs) error {
 	
 	snapshotName := fmt.Sprintf("initial-sync-%s", time.Now().Format("20060102-150405"))
 	log.Printf("スナップショット作成: %s", snapshotName)
+
+	if args.DryRun {
+		log.Println("プレビューモードのためスナップショット作成をスキップします")
+		return nil
+	}
+
+	vhdxManager := newSnapshotManager(cfg)
+	if err := vhdxManager.CreateSnapshot(snapshotName); err != nil {
+		return fmt.Errorf("スナップショット作成エラー: %w", err)
+	}
 	
 	log.Printf("初回スナップショットが作成されました: %s", snapshotName)
 	return nil
 }
 
-// runPeriodicExecution は定期実行を開始。
-func runPeriodicExecution(ctx context.Context, cfg *Config, args *RunArgs) error {
+// runPeriodicExecution は定期実行を開始。実行中は制御ソケットで ctl コマンドからの操作を受け付ける。
+func runPeriodicExecution(ctx context.Context, cfg *config.Config, args *RunArgs) error {
 	log.Println("定期実行を開始します")
 	
-	// 同期間隔とfixup間隔の解析。
-	syncInterval, err := time.ParseDuration(cfg.SyncInterval)
-	if err != nil {
-		syncInterval = 5 * time.Minute // デフォルト値。
-		log.Println("同期間隔の解析に失敗しました。デフォルト値 5m を使用します")
-	}
-
-	fixupInterval, err := time.ParseDuration(cfg.FixupInterval)
-	if err != nil {
-		fixupInterval = 1 * time.Hour // デフォルト値。
-		log.Println("fixup間隔の解析に失敗しました。デフォルト値 1h を使用します")
-	}
-
-	// タイマーの作成。
-	syncTicker := time.NewTicker(syncInterval)
-	fixupTicker := time.NewTicker(fixupInterval)
-	defer syncTicker.Stop()
-	defer fixupTicker.Stop()
-
-	log.Printf("同期間隔: %v, fixup間隔: %v", syncInterval, fixupInterval)
-
-	for {
-		select {
-		case <-ctx.Done():
-			log.Println("定期実行を終了します")
-			return nil
-			
-		case <-syncTicker.C:
-			log.Println("定期同期を実行します")
-			if err := executePeriodicSync(cfg, args); err != nil {
-				log.Printf("定期同期でエラーが発生しました: %v", err)
-			}
-			
-		case <-fixupTicker.C:
-			log.Println("定期fixupを実行します")
-			if err := executePeriodicFixup(cfg, args); err != nil {
-				log.Printf("定期fixupでエラーが発生しました: %v", err)
-			}
-		}
-	}
+	return newDaemon(cfg, args).run(ctx)
 }
 
 // executePeriodicSync は定期同期を実行。
-func executePeriodicSync(cfg *Config, args *RunArgs) error {
-	opsRepoPath := cfg.OpsRepoPath
-	if cfg.VhdxPath != "" && cfg.MountPoint != "" {
-		// VHDXマウントポイントが実際に利用可能かチェック。
-		if _, err := os.Stat(cfg.MountPoint); err == nil {
-			// Windowsドライブレター形式のマウントポイントに対応（例: "Q:" → "Q:\\devBaseName"）
-			devBaseName := filepath.Base(cfg.DevRepoPath)
-			opsRepoPath, _ = filepath.Abs(filepath.Join(cfg.MountPoint, devBaseName))
-		} else if isVHDXPath(cfg.OpsRepoPath, cfg.MountPoint) {
-			// 設定のopsRepoPathもVHDXパスの場合、ローカルフォールバックパスを生成
-			opsRepoPath = generateLocalFallbackPath(cfg.DevRepoPath, cfg.OpsRepoPath)
-		}
-	} else if isVHDXPath(cfg.OpsRepoPath, cfg.MountPoint) {
-		// VHDX設定がないが、opsRepoPathがVHDXパスの場合もフォールバック
-		opsRepoPath = generateLocalFallbackPath(cfg.DevRepoPath, cfg.OpsRepoPath)
-	}
+func executePeriodicSync(ctx context.Context, cfg *config.Config, args *RunArgs) error {
+	engineCfg := engineConfig(cfg, args)
 
-	log.Printf("定期同期: %s -> %s", cfg.DevRepoPath, opsRepoPath)
+	log.Printf("定期同期: %s -> %s", cfg.DevRepoPath, engineCfg.OpsRepoPath)
 	
 	if args.DryRun {
 		log.Println("DryRunモードで同期処理をスキップします")
 		return nil
 	}
 
+	result, err := sync.NewFileSyncer(engineCfg).Sync(ctx)
+	if err != nil {
+		return err
+	}
+	logSyncResult(result)
+
 	log.Println("定期同期が完了しました")
 	return nil
 }
 
 // executePeriodicFixup は定期fixupを実行。
-func executePeriodicFixup(cfg *Config, args *RunArgs) error {
-	opsRepoPath := cfg.OpsRepoPath
-	if cfg.VhdxPath != "" && cfg.MountPoint != "" {
-		// VHDXマウントポイントが実際に利用可能かチェック。
-		if _, err := os.Stat(cfg.MountPoint); err == nil {
-			// Windowsドライブレター形式のマウントポイントに対応（例: "Q:" → "Q:\\devBaseName"）
-			devBaseName := filepath.Base(cfg.DevRepoPath)
-			opsRepoPath, _ = filepath.Abs(filepath.Join(cfg.MountPoint, devBaseName))
-		} else if isVHDXPath(cfg.OpsRepoPath, cfg.MountPoint) {
-			// 設定のopsRepoPathもVHDXパスの場合、ローカルフォールバックパスを生成
-			opsRepoPath = generateLocalFallbackPath(cfg.DevRepoPath, cfg.OpsRepoPath)
-		}
-	} else if isVHDXPath(cfg.OpsRepoPath, cfg.MountPoint) {
-		// VHDX設定がないが、opsRepoPathがVHDXパスの場合もフォールバック
-		opsRepoPath = generateLocalFallbackPath(cfg.DevRepoPath, cfg.OpsRepoPath)
-	}
+func executePeriodicFixup(ctx context.Context, cfg *config.Config, args *RunArgs) error {
+	engineCfg := engineConfig(cfg, args)
 
-	log.Printf("fixup処理を実行します: %s", opsRepoPath)
+	log.Printf("fixup処理を実行します: %s", engineCfg.OpsRepoPath)
 	
-	// 基本的なfixup処理を実装。
-	// 詳細なfixup機能は fixup.go で実装される予定。
 	if args.DryRun {
 		log.Println("DryRunモードでfixup処理をスキップします")
 		return nil
 	}
 
+	fixupManager := fixup.NewFixupManager(engineCfg)
+
+	if engineCfg.FixupAllBranches {
+		results, err := fixupManager.RunAllBranches(ctx)
+		for _, branch := range results {
+			if branch.Err != nil {
+				log.Printf("ブランチ %s の fixup に失敗しました: %v", branch.Branch, branch.Err)
+				continue
+			}
+			log.Printf("ブランチ %s:", branch.Branch)
+			logFixupResult(branch.Result)
+		}
+		if err != nil {
+			return err
+		}
+		log.Println("fixup処理が完了しました")
+		return nil
+	}
+
+	result, err := fixupManager.RunFixup(ctx)
+	if err != nil {
+		return err
+	}
+	logFixupResult(result)
+
 	log.Println("fixup処理が完了しました")
 	return nil
 }
 
+// logSyncResult は同期結果をログに記録する。
+func logSyncResult(result *sync.SyncResult) {
+	if result.CommitHash == "" {
+		log.Println("同期対象の変更はありません")
+	} else {
+		log.Printf("同期コミット %s: 追加 %d, 変更 %d, 削除 %d",
+			result.CommitHash[:8], len(result.FilesAdded), len(result.FilesModified), len(result.FilesDeleted))
+	}
+	if len(result.UnrelatedChanges) > 0 {
+		log.Printf("同期対象外の Ops の未コミット変更があります (%d 件、コミットしません)", len(result.UnrelatedChanges))
+	}
+}
+
+// logFixupResult は fixup 結果をログに記録する。
+func logFixupResult(result *fixup.FixupResult) {
+	switch {
+	case result.FilesModified > 0:
+		log.Printf("fixup コミット %s: %d ファイル", result.FixupCommitHash[:8], result.FilesModified)
+	case result.PendingFixups > 0:
+		log.Printf("残っていた fixup コミット %d 件を autosquash しました", result.PendingFixups)
+	default:
+		log.Println("fixup 対象の変更はありません")
+	}
+	if result.BackupRef != "" {
+		log.Printf("書き換え前のバックアップ: %s", result.BackupRef)
+	}
+	if len(result.UnrelatedChanges) > 0 {
+		log.Printf("同期対象外の Ops の未コミット変更があります (%d 件、fixup しません)", len(result.UnrelatedChanges))
+	}
+}
+
 // runCommand はシェルコマンドを実行。
 func runCommand(command string) error {
 	log.Printf("コマンド実行: %s", command)
@@ -0,0 +1,472 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/fixup"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
+	"fixup-commit-sync-manager/internal/httpapi"
+	"fixup-commit-sync-manager/internal/retry"
+	"fixup-commit-sync-manager/internal/sync"
+)
+
+// daemon は run コマンドの定期実行ループ。制御ソケットからの要求もループ内で順に処理するため、
+// sync と fixup が同時に実行されることはない。
+type daemon struct {
+	cfg       *config.Config
+	args      *RunArgs
+	startedAt time.Time
+	paused    bool
+
+	syncInterval  time.Duration
+	fixupInterval time.Duration
+	syncTicker    *time.Ticker
+	fixupTicker   *time.Ticker
+	syncBreaker   *retry.Breaker
+	fixupBreaker  *retry.Breaker
+	nextSync      time.Time
+	nextFixup     time.Time
+	lastSync      *control.RunInfo
+	lastFixup     *control.RunInfo
+	lastSnapshot  *control.RunInfo
+
+	requests chan daemonRequest
+	// status と historyLog はループの外（制御ソケットや HTTP API の goroutine）から参照する。
+	status     atomic.Pointer[control.Status]
+	historyLog atomic.Pointer[history.Log]
+	ctx        context.Context
+	stop       context.CancelFunc
+}
+
+// daemonRequest はループで処理する制御要求と、応答の送り先。
+type daemonRequest struct {
+	req   control.Request
+	reply chan control.Response
+}
+
+func newDaemon(cfg *config.Config, args *RunArgs) *daemon {
+	d := &daemon{
+		args:      args,
+		startedAt: time.Now(),
+		requests:  make(chan daemonRequest),
+		ctx:       context.Background(),
+	}
+	d.applyConfig(cfg)
+	return d
+}
+
+// applyConfig は設定を反映し、同期間隔と fixup 間隔を求める。連続失敗の状態は引き継ぐ。
+func (d *daemon) applyConfig(cfg *config.Config) {
+	d.cfg = cfg
+	d.historyLog.Store(history.NewLog(git.NewConfiguredRunner(cfg, cfg.DevRepoPath)))
+
+	syncInterval, err := cfg.GetSyncIntervalDuration()
+	if err != nil || syncInterval <= 0 {
+		syncInterval = 5 * time.Minute // デフォルト値。
+		log.Println("同期間隔の解析に失敗しました。デフォルト値 5m を使用します")
+	}
+	fixupInterval, err := cfg.GetFixupIntervalDuration()
+	if err != nil || fixupInterval <= 0 {
+		fixupInterval = 1 * time.Hour // デフォルト値。
+		log.Println("fixup間隔の解析に失敗しました。デフォルト値 1h を使用します")
+	}
+	d.syncInterval = syncInterval
+	d.fixupInterval = fixupInterval
+
+	syncBreaker := retry.NewBreaker(cfg, control.CommandSync, syncInterval)
+	syncBreaker.Probe = func(ctx context.Context) error {
+		return sync.NewFileSyncer(engineConfig(d.cfg, d.args)).Probe(ctx)
+	}
+	syncBreaker.Inherit(d.syncBreaker)
+	d.syncBreaker = syncBreaker
+
+	fixupBreaker := retry.NewBreaker(cfg, control.CommandFixup, fixupInterval)
+	fixupBreaker.Probe = func(ctx context.Context) error {
+		return fixup.NewFixupManager(engineConfig(d.cfg, d.args)).Probe(ctx)
+	}
+	fixupBreaker.Inherit(d.fixupBreaker)
+	d.fixupBreaker = fixupBreaker
+}
+
+// run は ctx が終了するか stop 要求を受けるまで定期実行を行う。
+func (d *daemon) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.ctx = ctx
+	d.stop = cancel
+
+	// タイマーの作成。待ち受けを始める前に状態を公開し、status 要求に空の状態を返さないようにする。
+	d.syncTicker = time.NewTicker(d.syncInterval)
+	d.fixupTicker = time.NewTicker(d.fixupInterval)
+	defer d.syncTicker.Stop()
+	defer d.fixupTicker.Stop()
+	d.nextSync = time.Now().Add(d.syncInterval)
+	d.nextFixup = time.Now().Add(d.fixupInterval)
+	d.publish()
+
+	if server := d.listen(); server != nil {
+		defer server.Close()
+	}
+	stopMetrics := startMetricsExport(d.cfg)
+	defer stopMetrics()
+	if server := d.serveHTTP(); server != nil {
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+	}
+
+	log.Printf("同期間隔: %v, fixup間隔: %v", d.syncInterval, d.fixupInterval)
+	reloads := d.watchConfig(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("定期実行を終了します")
+			return nil
+
+		case cfg, ok := <-reloads:
+			if !ok {
+				reloads = nil
+				continue
+			}
+			log.Println("設定ファイルの変更を検出しました")
+			d.swapConfig(cfg)
+			d.publish()
+
+		case <-d.syncTicker.C:
+			if d.paused {
+				d.nextSync = time.Now().Add(d.syncInterval)
+				log.Println("一時停止中のため定期同期をスキップします")
+				d.publish()
+				continue
+			}
+			log.Println("定期同期を実行します")
+			err := d.runSync(true)
+			switch {
+			case errors.Is(err, retry.ErrOpen):
+				log.Printf("失敗が続いているため定期同期を停止しています: %v", err)
+			case err != nil:
+				log.Printf("定期同期でエラーが発生しました: %v", err)
+			}
+
+		case <-d.fixupTicker.C:
+			if d.paused {
+				d.nextFixup = time.Now().Add(d.fixupInterval)
+				log.Println("一時停止中のため定期fixupをスキップします")
+				d.publish()
+				continue
+			}
+			log.Println("定期fixupを実行します")
+			err := d.runFixup(true)
+			switch {
+			case errors.Is(err, retry.ErrOpen):
+				log.Printf("失敗が続いているため定期fixupを停止しています: %v", err)
+			case err != nil:
+				log.Printf("定期fixupでエラーが発生しました: %v", err)
+			}
+
+		case request := <-d.requests:
+			request.reply <- d.execute(request.req)
+			d.publish()
+		}
+	}
+}
+
+// runSync は同期を 1 回実行して結果を記録し、連続失敗の状態から次の定期同期の時刻を決める。
+// 定期実行（scheduled）では、回路が開いている間は確認のみ行う。明示的な要求は回路の状態に関わらず実行する。
+func (d *daemon) runSync(scheduled bool) error {
+	run := func(ctx context.Context) error {
+		started := time.Now()
+		err := executePeriodicSync(ctx, d.cfg, d.args)
+		d.lastSync = newRunInfo(control.CommandSync, started, err)
+		return err
+	}
+
+	var err error
+	if scheduled {
+		err = d.syncBreaker.Do(d.ctx, run)
+	} else {
+		err = run(d.ctx)
+		d.syncBreaker.Record(err)
+	}
+	d.nextSync = reschedule(d.syncTicker, d.syncBreaker.Delay())
+	d.publish()
+	return err
+}
+
+// runFixup は fixup を 1 回実行して結果を記録し、連続失敗の状態から次の定期 fixup の時刻を決める。
+// 定期実行（scheduled）では、回路が開いている間は確認のみ行う。明示的な要求は回路の状態に関わらず実行する。
+func (d *daemon) runFixup(scheduled bool) error {
+	run := func(ctx context.Context) error {
+		started := time.Now()
+		err := executePeriodicFixup(ctx, d.cfg, d.args)
+		d.lastFixup = newRunInfo(control.CommandFixup, started, err)
+		return err
+	}
+
+	var err error
+	if scheduled {
+		err = d.fixupBreaker.Do(d.ctx, run)
+	} else {
+		err = run(d.ctx)
+		d.fixupBreaker.Record(err)
+	}
+	d.nextFixup = reschedule(d.fixupTicker, d.fixupBreaker.Delay())
+	d.publish()
+	return err
+}
+
+// reschedule は ticker の次の発火を delay 後にし、その時刻を返す。ループの開始前（ticker が nil）は時刻のみ返す。
+func reschedule(ticker *time.Ticker, delay time.Duration) time.Time {
+	if ticker != nil {
+		ticker.Reset(delay)
+	}
+	return time.Now().Add(delay)
+}
+
+// runSnapshot は VHDX のスナップショットを 1 回作成し、結果を記録する。
+func (d *daemon) runSnapshot() error {
+	started := time.Now()
+	err := createManualSnapshot(d.cfg, d.args)
+	d.lastSnapshot = newRunInfo(control.CommandSnapshot, started, err)
+	d.publish()
+	return err
+}
+
+// newRunInfo は started に開始した operation の実行結果を返す。
+func newRunInfo(operation string, started time.Time, err error) *control.RunInfo {
+	info := &control.RunInfo{Operation: operation, Time: started, Duration: time.Since(started)}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	return info
+}
+
+// createManualSnapshot は要求に応じて VHDX のスナップショットを作成する。
+func createManualSnapshot(cfg *config.Config, args *RunArgs) error {
+	if cfg.VHDXPath == "" {
+		return fmt.Errorf("vhdxPath is not configured")
+	}
+
+	snapshotName := fmt.Sprintf("manual-%s", time.Now().Format("20060102-150405"))
+	log.Printf("スナップショット作成: %s", snapshotName)
+
+	if args.DryRun {
+		log.Println("プレビューモードのためスナップショット作成をスキップします")
+		return nil
+	}
+
+	vhdxManager := newSnapshotManager(cfg)
+	if err := vhdxManager.CreateSnapshot(snapshotName); err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	return nil
+}
+
+// execute は制御要求を処理する。ループの goroutine から呼ばれる。
+func (d *daemon) execute(req control.Request) control.Response {
+	log.Printf("制御要求を受信しました: %s", req.Command)
+
+	switch req.Command {
+	case control.CommandSync:
+		if err := d.runSync(false); err != nil {
+			return control.Response{Error: fmt.Sprintf("sync failed: %v", err)}
+		}
+		return control.Response{OK: true, Message: "sync completed"}
+
+	case control.CommandFixup:
+		if err := d.runFixup(false); err != nil {
+			return control.Response{Error: fmt.Sprintf("fixup failed: %v", err)}
+		}
+		return control.Response{OK: true, Message: "fixup completed"}
+
+	case control.CommandSnapshot:
+		if err := d.runSnapshot(); err != nil {
+			return control.Response{Error: fmt.Sprintf("snapshot failed: %v", err)}
+		}
+		return control.Response{OK: true, Message: "snapshot created"}
+
+	case control.CommandPause:
+		if d.paused {
+			return control.Response{OK: true, Message: "already paused"}
+		}
+		d.paused = true
+		log.Println("定期実行を一時停止しました")
+		return control.Response{OK: true, Message: "paused periodic sync and fixup"}
+
+	case control.CommandResume:
+		if !d.paused {
+			return control.Response{OK: true, Message: "not paused"}
+		}
+		d.paused = false
+		log.Println("定期実行を再開しました")
+		return control.Response{OK: true, Message: "resumed periodic sync and fixup"}
+
+	case control.CommandReload:
+		if err := d.reload(); err != nil {
+			return control.Response{Error: fmt.Sprintf("reload failed: %v", err)}
+		}
+		return control.Response{OK: true, Message: "configuration reloaded"}
+
+	case control.CommandStop:
+		log.Println("停止要求を受けたため終了処理を開始します")
+		d.stop()
+		return control.Response{OK: true, Message: "stopping"}
+
+	default:
+		return control.Response{Error: fmt.Sprintf("unknown command: %s", req.Command)}
+	}
+}
+
+// reload は設定ファイルを読み直して検証し、問題が無ければ反映する。
+func (d *daemon) reload() error {
+	cfg, err := loadConfiguration(d.args.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := checkReloadable(d.cfg, cfg); err != nil {
+		return err
+	}
+
+	d.swapConfig(cfg)
+	return nil
+}
+
+// swapConfig は検証済みの設定 cfg に切り替え、間隔が変わった場合はタイマーを設定し直す。
+// ループの goroutine から sync / fixup の合間に呼ばれるため、実行中の処理の設定が途中で変わることはない。
+func (d *daemon) swapConfig(cfg *config.Config) {
+	if settings := restartRequiredSettings(d.cfg, cfg); len(settings) > 0 {
+		log.Printf("警告: %s の変更は run の再起動後に反映されます", strings.Join(settings, ", "))
+	}
+
+	syncInterval, fixupInterval := d.syncInterval, d.fixupInterval
+	d.applyConfig(cfg)
+	if d.syncInterval != syncInterval {
+		d.nextSync = reschedule(d.syncTicker, d.syncBreaker.Delay())
+	}
+	if d.fixupInterval != fixupInterval {
+		d.nextFixup = reschedule(d.fixupTicker, d.fixupBreaker.Delay())
+	}
+
+	log.Printf("設定を再読み込みしました。同期間隔: %v, fixup間隔: %v", d.syncInterval, d.fixupInterval)
+}
+
+// watchConfig は設定ファイルの監視を開始し、変更された設定を返すチャネルを返す。
+// 不正な変更はログに記録して無視し、現在の設定を維持する。
+func (d *daemon) watchConfig(ctx context.Context) <-chan *config.Config {
+	path := d.args.ConfigPath
+	if path == "" {
+		path = "config.hjson"
+	}
+
+	watcher := config.NewWatcher(path, d.cfg)
+	watcher.Check = checkReloadable
+	watcher.OnError = func(err error) {
+		log.Printf("設定ファイルの変更を無視し、現在の設定を維持します: %v", err)
+	}
+	return watcher.Watch(ctx)
+}
+
+// publish は制御ソケットから参照する状態を更新する。
+func (d *daemon) publish() {
+	d.status.Store(&control.Status{
+		PID:              os.Getpid(),
+		StartedAt:        d.startedAt,
+		ConfigPath:       d.args.ConfigPath,
+		DevRepoPath:      d.cfg.DevRepoPath,
+		OpsRepoPath:      resolveOpsRepoPath(d.cfg),
+		Paused:           d.paused,
+		DryRun:           d.args.DryRun,
+		SyncInterval:     d.syncInterval.String(),
+		FixupInterval:    d.fixupInterval.String(),
+		LastSync:         d.lastSync,
+		LastFixup:        d.lastFixup,
+		LastSnapshot:     d.lastSnapshot,
+		NextSync:         d.nextSync,
+		NextFixup:        d.nextFixup,
+		SyncFailures:     d.syncBreaker.Failures(),
+		SyncCircuitOpen:  d.syncBreaker.IsOpen(),
+		FixupFailures:    d.fixupBreaker.Failures(),
+		FixupCircuitOpen: d.fixupBreaker.IsOpen(),
+	})
+}
+
+// Status は現在の状態を返す。
+func (d *daemon) Status() *control.Status {
+	return d.status.Load()
+}
+
+// History は Dev リポジトリの実行履歴から filter に一致する記録を古い順に返す。
+func (d *daemon) History(ctx context.Context, filter history.Filter) ([]history.Entry, error) {
+	return d.historyLog.Load().Read(ctx, filter)
+}
+
+// listen は制御ソケットの待ち受けを開始する。使用できない場合は制御なしで定期実行を続ける。
+func (d *daemon) listen() *control.Server {
+	path, err := controlSocketPath(d.cfg)
+	if err != nil {
+		log.Printf("警告: 制御ソケットを使用できません: %v", err)
+		return nil
+	}
+
+	server, err := control.Listen(path, d.Handle)
+	if err != nil {
+		log.Printf("警告: 制御ソケットを使用できません: %v", err)
+		return nil
+	}
+
+	log.Printf("制御ソケットで待ち受けています: %s", path)
+	return server
+}
+
+// serveHTTP は設定されていれば HTTP API の待ち受けを開始する。使用できない場合は API なしで定期実行を続ける。
+func (d *daemon) serveHTTP() *httpapi.Server {
+	if d.cfg.HTTPAPIAddr == "" {
+		return nil
+	}
+
+	server, err := httpapi.Start(d.cfg.HTTPAPIAddr, d.cfg.HTTPAPIToken, d)
+	if err != nil {
+		log.Printf("警告: HTTP API を使用できません: %v", err)
+		return nil
+	}
+
+	log.Printf("HTTP API で待ち受けています: http://%s%s", server.Addr(), httpapi.PathPrefix)
+	return server
+}
+
+// Handle は制御要求をループへ渡して応答を待つ。status はループを待たずに応答する。
+// 制御ソケットと HTTP API の goroutine から呼ばれる。
+func (d *daemon) Handle(req control.Request) control.Response {
+	if req.Command == control.CommandStatus {
+		return control.Response{OK: true, Status: d.status.Load()}
+	}
+
+	request := daemonRequest{req: req, reply: make(chan control.Response, 1)}
+	select {
+	case d.requests <- request:
+	case <-d.ctx.Done():
+		return control.Response{Error: "daemon is stopping"}
+	}
+	return <-request.reply
+}
+
+// controlSocketPath は設定の Ops リポジトリに対応する制御ソケットのパスを返す。
+func controlSocketPath(cfg *config.Config) (string, error) {
+	return control.SocketPath(context.Background(), git.NewConfiguredRunner(cfg, resolveOpsRepoPath(cfg)))
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/retry"
+)
+
+// TestDaemonControl は制御ソケット経由で run の定期実行を操作できることをテストする。
+func TestDaemonControl(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping daemon control test")
+	}
+
+	tempDir := t.TempDir()
+	opsRepo := filepath.Join(tempDir, "ops")
+	if err := exec.Command("git", "init", opsRepo).Run(); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+
+	configPath := filepath.Join(tempDir, "config.hjson")
+	writeConfig := func(syncInterval string) {
+		content := `{
+  "devRepoPath": "` + filepath.ToSlash(filepath.Join(tempDir, "dev")) + `",
+  "opsRepoPath": "` + filepath.ToSlash(opsRepo) + `",
+  "syncInterval": "` + syncInterval + `"
+}`
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+	writeConfig("1h")
+
+	cfg, err := loadConfiguration(configPath)
+	if err != nil {
+		t.Fatalf("loadConfiguration() failed: %v", err)
+	}
+	args := &RunArgs{ConfigPath: configPath, DryRun: true}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runPeriodicExecution(context.Background(), cfg, args)
+	}()
+
+	socketPath, err := controlSocketPath(cfg)
+	if err != nil {
+		t.Fatalf("controlSocketPath() failed: %v", err)
+	}
+	send := func(command string) *control.Response {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		resp, err := control.Send(ctx, socketPath, control.Request{Command: command})
+		if err != nil {
+			t.Fatalf("%s: Send() failed: %v", command, err)
+		}
+		if !resp.OK {
+			t.Fatalf("%s: unexpected error response: %s", command, resp.Error)
+		}
+		return resp
+	}
+
+	// デーモンが待ち受けを開始するまで待つ。
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := control.Send(context.Background(), socketPath, control.Request{Command: control.CommandStatus}); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Daemon did not start listening on the control socket")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	status := send(control.CommandStatus).Status
+	if status == nil || status.Paused || status.PID != os.Getpid() || status.SyncInterval != "1h0m0s" {
+		t.Fatalf("Unexpected initial status: %+v", status)
+	}
+
+	send(control.CommandPause)
+	if status := send(control.CommandStatus).Status; !status.Paused {
+		t.Error("Daemon should be paused")
+	}
+
+	// 一時停止中でも明示的な要求は実行する。
+	if resp := send(control.CommandSync); resp.Message != "sync completed" {
+		t.Errorf("Unexpected sync response: %+v", resp)
+	}
+	if status := send(control.CommandStatus).Status; status.LastSync == nil || status.LastSync.Error != "" {
+		t.Errorf("Last sync should be recorded: %+v", status.LastSync)
+	}
+
+	send(control.CommandResume)
+	writeConfig("2h")
+	send(control.CommandReload)
+
+	status = send(control.CommandStatus).Status
+	if status.Paused || status.SyncInterval != "2h0m0s" {
+		t.Errorf("Expected resumed daemon with reloaded interval, got %+v", status)
+	}
+
+	var buf bytes.Buffer
+	writeDaemonStatus(&buf, status)
+	if !strings.Contains(buf.String(), "Daemon: running (dry run)") || !strings.Contains(buf.String(), "Sync:  every 2h0m0s") {
+		t.Errorf("Unexpected status output:\n%s", buf.String())
+	}
+
+	send(control.CommandStop)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("runPeriodicExecution() failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Daemon did not stop")
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Error("Control socket should be removed after stop")
+	}
+}
+
+// TestDaemonRejectsInvalidReload は不正な設定の再読み込みで現在の設定が維持されることをテストする。
+func TestDaemonRejectsInvalidReload(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.hjson")
+	os.WriteFile(configPath, []byte(`{ "devRepoPath": "/tmp/dev" }`), 0644)
+
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = "/tmp/dev"
+	cfg.OpsRepoPath = "/tmp/ops"
+	d := newDaemon(cfg, &RunArgs{ConfigPath: configPath})
+
+	resp := d.execute(control.Request{Command: control.CommandReload})
+	if resp.OK || !strings.Contains(resp.Error, "opsRepoPath") {
+		t.Errorf("Reload of an invalid config should fail, got %+v", resp)
+	}
+	if d.cfg != cfg {
+		t.Error("Current configuration should be kept")
+	}
+
+	if resp := d.execute(control.Request{Command: "restart"}); resp.OK {
+		t.Error("Unknown command should be rejected")
+	}
+}
+
+// TestDaemonSnapshot はスナップショット要求の結果が直近の結果に記録されることをテストする。
+func TestDaemonSnapshot(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = "/tmp/dev"
+	cfg.OpsRepoPath = "/tmp/ops"
+	d := newDaemon(cfg, &RunArgs{DryRun: true})
+
+	resp := d.execute(control.Request{Command: control.CommandSnapshot})
+	if resp.OK || !strings.Contains(resp.Error, "vhdxPath") {
+		t.Errorf("Snapshot without vhdxPath should fail, got %+v", resp)
+	}
+	if status := d.Status(); status.LastSnapshot == nil || status.LastSnapshot.Error == "" {
+		t.Errorf("Failed snapshot should be recorded: %+v", status.LastSnapshot)
+	}
+
+	cfg.VHDXPath = "/tmp/dev.vhdx"
+	if resp := d.execute(control.Request{Command: control.CommandSnapshot}); !resp.OK {
+		t.Errorf("Dry run snapshot should succeed, got %+v", resp)
+	}
+
+	status := d.Status()
+	if status.LastSnapshot == nil || status.LastSnapshot.Operation != control.CommandSnapshot || status.LastSnapshot.Error != "" {
+		t.Errorf("Last snapshot should be recorded: %+v", status.LastSnapshot)
+	}
+}
+
+// TestDaemonCircuitBreaker は定期同期の失敗が続くと実行を止めて確認のみ行い、状態に表示されることをテストする。
+func TestDaemonCircuitBreaker(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = filepath.Join(tempDir, "dev")
+	cfg.OpsRepoPath = filepath.Join(tempDir, "ops")
+	cfg.SyncInterval = "1m"
+	cfg.CircuitBreakerThreshold = 2
+	cfg.CircuitProbeInterval = "10m"
+	d := newDaemon(cfg, &RunArgs{})
+
+	for i := 0; i < 2; i++ {
+		if err := d.runSync(true); err == nil || errors.Is(err, retry.ErrOpen) {
+			t.Fatalf("runSync() #%d should fail with the sync error, got %v", i+1, err)
+		}
+	}
+	lastSync := d.Status().LastSync
+	if err := d.runSync(true); !errors.Is(err, retry.ErrOpen) {
+		t.Fatalf("Scheduled sync should be skipped while the circuit is open, got %v", err)
+	}
+	if d.Status().LastSync != lastSync {
+		t.Errorf("Skipped sync should not be recorded, got %+v", d.Status().LastSync)
+	}
+
+	status := d.Status()
+	if !status.SyncCircuitOpen || status.SyncFailures != 2 {
+		t.Errorf("Status should report the open circuit: %+v", status)
+	}
+	if status.NextSync.Before(time.Now().Add(7 * time.Minute)) {
+		t.Errorf("Next sync should wait for the probe interval, got %v", status.NextSync)
+	}
+
+	var buf bytes.Buffer
+	writeDaemonStatus(&buf, status)
+	if !strings.Contains(buf.String(), "stopped after 2 consecutive failures") {
+		t.Errorf("Unexpected status output:\n%s", buf.String())
+	}
+
+	// 明示的な要求は回路が開いていても実行する。
+	if resp := d.execute(control.Request{Command: control.CommandSync}); resp.OK {
+		t.Errorf("Explicit sync should run and fail, got %+v", resp)
+	}
+	if d.Status().LastSync == lastSync {
+		t.Error("Explicit sync should be recorded")
+	}
+}
+
+// TestDaemonSwapConfig は設定の切り替えで、間隔が変わった場合のみ次の実行時刻を設定し直すことをテストする。
+func TestDaemonSwapConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = "/tmp/dev"
+	cfg.OpsRepoPath = "/tmp/ops"
+	d := newDaemon(cfg, &RunArgs{DryRun: true})
+	nextSync := time.Now().Add(time.Minute)
+	nextFixup := time.Now().Add(time.Hour)
+	d.nextSync, d.nextFixup = nextSync, nextFixup
+
+	next := *cfg
+	next.IncludeExtensions = []string{".go"}
+	d.swapConfig(&next)
+	if d.cfg != &next || !d.nextSync.Equal(nextSync) || !d.nextFixup.Equal(nextFixup) {
+		t.Errorf("Unchanged intervals should keep the schedule, next sync %v, next fixup %v", d.nextSync, d.nextFixup)
+	}
+
+	changed := next
+	changed.SyncInterval = "10m"
+	d.swapConfig(&changed)
+	if d.syncInterval != 10*time.Minute || d.nextSync.Before(time.Now().Add(9*time.Minute)) {
+		t.Errorf("Changed sync interval should reschedule the next sync, got %v at %v", d.syncInterval, d.nextSync)
+	}
+	if !d.nextFixup.Equal(nextFixup) {
+		t.Error("Unchanged fixup interval should keep the fixup schedule")
+	}
+}
+
+// TestDaemonReloadRejectsRepositoryChange はリポジトリのパスを変更した設定を再読み込みせず、現在の設定を維持することをテストする。
+func TestDaemonReloadRejectsRepositoryChange(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.hjson")
+	content := `{
+  "devRepoPath": "` + filepath.ToSlash(filepath.Join(tempDir, "dev")) + `",
+  "opsRepoPath": "` + filepath.ToSlash(filepath.Join(tempDir, "other-ops")) + `",
+  "syncInterval": "10m"
+}`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = filepath.ToSlash(filepath.Join(tempDir, "dev"))
+	cfg.OpsRepoPath = filepath.ToSlash(filepath.Join(tempDir, "ops"))
+	d := newDaemon(cfg, &RunArgs{ConfigPath: configPath, DryRun: true})
+
+	err := d.reload()
+	if err == nil || !strings.Contains(err.Error(), "opsRepoPath") {
+		t.Fatalf("reload() should reject the repository change, got %v", err)
+	}
+	if d.cfg != cfg || d.syncInterval == 10*time.Minute {
+		t.Error("Rejected reload should keep the current configuration")
+	}
+}
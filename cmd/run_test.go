@@ -4,49 +4,47 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 	"time"
+
+	"fixup-commit-sync-manager/internal/config"
 )
 
-// TestConfig はConfig構造体のテスト。
-func TestConfig(t *testing.T) {
-	t.Run("valid config", func(t *testing.T) {
-		cfg := &Config{
-			DevRepoPath: "/path/to/dev",
-			OpsRepoPath: "/path/to/ops",
-		}
-		
-		if err := cfg.Validate(); err != nil {
-			t.Errorf("Validate() failed: %v", err)
-		}
-	})
-	
-	t.Run("missing dev repo path", func(t *testing.T) {
-		cfg := &Config{
-			OpsRepoPath: "/path/to/ops",
-		}
-		
-		if err := cfg.Validate(); err == nil {
-			t.Error("Validate() should fail for missing dev repo path")
-		}
-	})
-	
-	t.Run("missing ops repo path", func(t *testing.T) {
-		cfg := &Config{
-			DevRepoPath: "/path/to/dev",
-		}
-		
-		if err := cfg.Validate(); err == nil {
-			t.Error("Validate() should fail for missing ops repo path")
-		}
-	})
+// TestEngineConfig はengineConfig関数のテスト。
+func TestEngineConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = "/path/to/dev"
+	cfg.OpsRepoPath = "/path/to/ops"
+
+	engineCfg := engineConfig(cfg, &RunArgs{DryRun: true, Verbose: true})
+
+	if engineCfg == cfg {
+		t.Fatal("engineConfig() should return a copy")
+	}
+	if engineCfg.OpsRepoPath != "/path/to/ops" {
+		t.Errorf("OpsRepoPath = %s, want /path/to/ops", engineCfg.OpsRepoPath)
+	}
+	if !engineCfg.DryRun || !engineCfg.Verbose {
+		t.Error("Run flags should be applied to the engine config")
+	}
+	if cfg.DryRun || cfg.Verbose {
+		t.Error("Original config should not be modified")
+	}
+
+	// マウントされていない VHDX 上のパスはローカルフォールバックパスに置き換えられる。
+	cfg.OpsRepoPath = "Q:/dev"
+	cfg.MountPoint = "Q:"
+	if got := engineConfig(cfg, &RunArgs{}).OpsRepoPath; !strings.HasSuffix(got, "ops-dev") {
+		t.Errorf("OpsRepoPath = %s, want local fallback path", got)
+	}
 }
 
-// TestLoadConfigFromFile はloadConfigFromFile関数のテスト。
-func TestLoadConfigFromFile(t *testing.T) {
+// TestLoadConfigurationFromFile はloadConfiguration関数による HJSON 設定ファイルの読み込みテスト。
+func TestLoadConfigurationFromFile(t *testing.T) {
 	t.Run("valid config file", func(t *testing.T) {
 		tempDir := t.TempDir()
 		configPath := filepath.Join(tempDir, "config.hjson")
@@ -75,9 +73,9 @@ func TestLoadConfigFromFile(t *testing.T) {
 		}
 		
 		// 設定ファイルを読み込み。
-		cfg, err := loadConfigFromFile(configPath)
+		cfg, err := loadConfiguration(configPath)
 		if err != nil {
-			t.Fatalf("loadConfigFromFile() failed: %v", err)
+			t.Fatalf("loadConfiguration() failed: %v", err)
 		}
 		
 		// 設定値の確認。
@@ -115,9 +113,9 @@ func TestLoadConfigFromFile(t *testing.T) {
 		}
 		
 		// 設定ファイルを読み込み。
-		cfg, err := loadConfigFromFile(configPath)
+		cfg, err := loadConfiguration(configPath)
 		if err != nil {
-			t.Fatalf("loadConfigFromFile() failed: %v", err)
+			t.Fatalf("loadConfiguration() failed: %v", err)
 		}
 		
 		// 設定値の確認。
@@ -127,12 +125,16 @@ func TestLoadConfigFromFile(t *testing.T) {
 		if cfg.SyncInterval != "5m" {
 			t.Errorf("SyncInterval = %s, want 5m", cfg.SyncInterval)
 		}
+		// 指定の無い項目には既定値が使われる。
+		if cfg.GitExecutable != "git" || cfg.FixupInterval != "1h" {
+			t.Errorf("Defaults should be applied: gitExecutable=%q fixupInterval=%q", cfg.GitExecutable, cfg.FixupInterval)
+		}
 	})
 	
 	t.Run("non-existent file", func(t *testing.T) {
-		_, err := loadConfigFromFile("/non/existent/path.hjson")
+		_, err := loadConfiguration("/non/existent/path.hjson")
 		if err == nil {
-			t.Error("loadConfigFromFile() should fail for non-existent file")
+			t.Error("loadConfiguration() should fail for non-existent file")
 		}
 	})
 	
@@ -148,9 +150,9 @@ func TestLoadConfigFromFile(t *testing.T) {
 			t.Fatalf("Failed to write invalid config file: %v", err)
 		}
 		
-		_, err = loadConfigFromFile(configPath)
+		_, err = loadConfiguration(configPath)
 		if err == nil {
-			t.Error("loadConfigFromFile() should fail for invalid JSON")
+			t.Error("loadConfiguration() should fail for invalid JSON")
 		}
 	})
 }
@@ -378,7 +380,7 @@ func TestCloneRepositorySimple(t *testing.T) {
 		destPath := filepath.Join(tempDir, "dest-repo")
 		
 		// クローンを実行。
-		err = cloneRepositorySimple(srcPath, destPath)
+		err = cloneRepositorySimple(srcPath, destPath, "git")
 		if err != nil {
 			t.Errorf("cloneRepositorySimple() failed: %v", err)
 		}
@@ -416,7 +418,7 @@ func TestCloneRepositorySimple(t *testing.T) {
 		}
 		
 		// 既存ディレクトリへのクローンはエラーになることを確認。
-		err = cloneRepositorySimple(srcPath, destPath)
+		err = cloneRepositorySimple(srcPath, destPath, "git")
 		if err == nil {
 			t.Error("cloneRepositorySimple() should fail for existing directory")
 		}
@@ -428,7 +430,7 @@ func TestCloneRepositorySimple(t *testing.T) {
 		destPath := filepath.Join(tempDir, "dest")
 		
 		// 存在しないリポジトリのクローンはエラーになることを確認。
-		err := cloneRepositorySimple(srcPath, destPath)
+		err := cloneRepositorySimple(srcPath, destPath, "git")
 		if err == nil {
 			t.Error("cloneRepositorySimple() should fail for non-existent repository")
 		}
@@ -444,7 +446,7 @@ func TestCloneRepositorySimple(t *testing.T) {
 		
 		// Windowsドライブルートの場合の動作確認。
 		// (実際のクローンは失敗するが、ディレクトリ処理が正しく動作することを確認)
-		err := cloneRepositorySimple(srcPath, destPath)
+		err := cloneRepositorySimple(srcPath, destPath, "git")
 		// エラーは期待される（ソースが存在しないため）
 		if err == nil {
 			t.Log("Windows drive root clone succeeded (unexpected but okay)")
@@ -583,16 +585,16 @@ func TestRunArgsValidation(t *testing.T) {
 
 // TestConfigJSONMarshaling はConfig構造体のJSONマーシャリングテスト。
 func TestConfigJSONMarshaling(t *testing.T) {
-	originalConfig := &Config{
+	originalConfig := &config.Config{
 		DevRepoPath:         "/tmp/dev",
 		OpsRepoPath:         "/tmp/ops",
 		SyncInterval:        "5m",
 		FixupInterval:       "1h",
 		IncludeExtensions:   []string{".cpp", ".h"},
 		ExcludePatterns:     []string{"*.obj"},
-		VhdxPath:            "/tmp/test.vhdx",
+		VHDXPath:            "/tmp/test.vhdx",
 		MountPoint:          "X:",
-		VhdxSize:            "10GB",
+		VHDXSize:            "10GB",
 		EncryptionEnabled:   false,
 		AutosquashEnabled:   true,
 		LogLevel:            "INFO",
@@ -607,7 +609,7 @@ func TestConfigJSONMarshaling(t *testing.T) {
 	}
 	
 	// JSONからアンマーシャル。
-	var unmarshaledConfig Config
+	var unmarshaledConfig config.Config
 	err = json.Unmarshal(data, &unmarshaledConfig)
 	if err != nil {
 		t.Fatalf("JSON unmarshal failed: %v", err)
@@ -718,16 +720,16 @@ func TestVHDXOpsRepoPathGenerationInRun(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cfg := &Config{
+			cfg := &config.Config{
 				DevRepoPath: tt.devRepoPath,
 				MountPoint:  tt.mountPoint,
-				VhdxPath:    tt.vhdxPath,
+				VHDXPath:    tt.vhdxPath,
 				OpsRepoPath: "/default/ops", // 初期値
 			}
 
 			// run.goの処理をシミュレート。
 			opsRepoPath := cfg.OpsRepoPath
-			if cfg.VhdxPath != "" && cfg.MountPoint != "" {
+			if cfg.VHDXPath != "" && cfg.MountPoint != "" {
 				// Windowsドライブレター形式のマウントポイントに対応（例: "Q:" → "Q:\\devBaseName"）
 				devBaseName := filepath.Base(cfg.DevRepoPath)
 				opsRepoPath, _ = filepath.Abs(filepath.Join(cfg.MountPoint, devBaseName))
@@ -742,7 +744,7 @@ func TestVHDXOpsRepoPathGenerationInRun(t *testing.T) {
 			}
 
 			// VHDXが有効な場合、パスにマウントポイントとベース名が含まれることを確認。
-			if cfg.VhdxPath != "" && cfg.MountPoint != "" {
+			if cfg.VHDXPath != "" && cfg.MountPoint != "" {
 				if !strings.Contains(normalizedPath, tt.mountPoint) {
 					t.Errorf("OpsRepoPath should contain mount point %q: %q", tt.mountPoint, normalizedPath)
 				}
@@ -757,7 +759,7 @@ func TestVHDXOpsRepoPathGenerationInRun(t *testing.T) {
 // TestPeriodicExecution は定期実行のテスト。
 func TestPeriodicExecution(t *testing.T) {
 	t.Run("periodic execution with short timeout", func(t *testing.T) {
-		cfg := &Config{
+		cfg := &config.Config{
 			DevRepoPath:   "/tmp/dev",
 			OpsRepoPath:   "/tmp/ops",
 			SyncInterval:  "100ms",  // 短い間隔でテスト。
@@ -778,4 +780,80 @@ func TestPeriodicExecution(t *testing.T) {
 			t.Errorf("runPeriodicExecution() failed: %v", err)
 		}
 	})
-}
\ No newline at end of file
+}
+// TestPeriodicSyncAndFixup は定期同期と定期fixupが実際の同期・fixup処理を行うことをテストする。
+func TestPeriodicSyncAndFixup(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping periodic execution test")
+	}
+
+	t.Setenv("GIT_AUTHOR_NAME", "Test User")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test User")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := os.MkdirAll(devRepo, 0755); err != nil {
+		t.Fatalf("Failed to create dev directory: %v", err)
+	}
+	for _, command := range []string{"git init -b main", "git commit --allow-empty -m Initial_commit"} {
+		if err := runCommandInDir(command, devRepo); err != nil {
+			t.Fatalf("Failed to prepare dev repository: %v", err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = devRepo
+	cfg.OpsRepoPath = opsRepo
+	args := &RunArgs{}
+
+	// Ops リポジトリが無い場合はクローンしてから同期する。
+	if err := os.WriteFile(filepath.Join(devRepo, "main.cpp"), []byte("int main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write dev file: %v", err)
+	}
+	if err := performInitialSync(context.Background(), cfg, args); err != nil {
+		t.Fatalf("performInitialSync() failed: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(opsRepo, "main.cpp")); err != nil || string(content) != "int main() {}\n" {
+		t.Fatalf("main.cpp should be synced to ops: %q, %v", content, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(devRepo, "util.cpp"), []byte("void util() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write dev file: %v", err)
+	}
+	if err := executePeriodicSync(context.Background(), cfg, args); err != nil {
+		t.Fatalf("executePeriodicSync() failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(opsRepo, "util.cpp")); err != nil {
+		t.Errorf("util.cpp should be synced to ops: %v", err)
+	}
+
+	// Ops 側の修正は fixup され、autosquash により同期コミットへ取り込まれる。
+	if err := os.WriteFile(filepath.Join(opsRepo, "main.cpp"), []byte("int main() { return 1; }\n"), 0644); err != nil {
+		t.Fatalf("Failed to write ops file: %v", err)
+	}
+	if err := executePeriodicFixup(context.Background(), cfg, args); err != nil {
+		t.Fatalf("executePeriodicFixup() failed: %v", err)
+	}
+
+	output, err := exec.Command("git", "-C", opsRepo, "log", "--format=%s").Output()
+	if err != nil {
+		t.Fatalf("git log failed: %v", err)
+	}
+	subjects := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(subjects) != 3 {
+		t.Errorf("Expected initial commit and two sync commits after autosquash, got %q", subjects)
+	}
+	for _, subject := range subjects {
+		if strings.HasPrefix(subject, "fixup! ") {
+			t.Errorf("Fixup commit should be squashed: %q", subjects)
+		}
+	}
+	status, _ := exec.Command("git", "-C", opsRepo, "status", "--porcelain").Output()
+	if len(status) != 0 {
+		t.Errorf("Ops working tree should be clean after fixup:\n%s", status)
+	}
+}
@@ -0,0 +1,14 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// shutdownContext は SIGINT / SIGTERM を受けると終了する context を返す。
+// 実行中の sync / fixup はこの context の終了で中断し、rebase 中であれば元の状態に戻してから返る。
+func shutdownContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
 	"fixup-commit-sync-manager/internal/vhdx"
 
 	"github.com/spf13/cobra"
@@ -61,6 +63,13 @@ func NewRollbackSnapshotCmd() *cobra.Command {
 	return cmd
 }
 
+// newSnapshotManager はスナップショットの作成結果を Dev リポジトリの実行履歴に記録する VHDXManager を返す。
+func newSnapshotManager(cfg *config.Config) *vhdx.VHDXManager {
+	manager := vhdx.NewVHDXManager(cfg.VHDXPath, cfg.MountPoint, cfg.VHDXSize, cfg.EncryptionEnabled)
+	manager.History = history.NewLog(git.NewConfiguredRunner(cfg, cfg.DevRepoPath))
+	return manager
+}
+
 func runCreateSnapshot(cmd *cobra.Command, args []string) error {
 	configPath, _ := cmd.Flags().GetString("config")
 	if configPath == "" {
@@ -84,7 +93,7 @@ func runCreateSnapshot(cmd *cobra.Command, args []string) error {
 		snapshotName = args[0]
 	}
 
-	vhdxManager := vhdx.NewVHDXManager(cfg.VHDXPath, cfg.MountPoint, cfg.VHDXSize, cfg.EncryptionEnabled)
+	vhdxManager := newSnapshotManager(cfg)
 
 	if verbose {
 		fmt.Printf("Creating snapshot of VHDX: %s\n", cfg.VHDXPath)
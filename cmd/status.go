@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/status"
+
+	"github.com/spf13/cobra"
+)
+
+func NewStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "現在の同期の状態を表示",
+		Long: `Dev と Ops のカレントブランチと一致しているか、今同期される変更、直近の sync / fixup の時刻と結果、
+一時停止の状態、ロックの保持者、未処理の fixup! コミット、VHDX のマウント状態を表示します。
+run デーモンが動いていれば直近の実行結果をデーモンから取得し、動いていなければ実行履歴（history コマンド）から、
+履歴も無ければ Ops リポジトリのコミットから推定します。
+
+--format prompt はシェルのプロンプトに埋め込むための 1 行の要約を出力します（例: main +3 f2 paused）。`,
+		RunE: runStatus,
+	}
+	cmd.Flags().String("format", "text", "出力形式（text / json / prompt）")
+	return cmd
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	configPath, _ := cmd.Flags().GetString("config")
+	if configPath == "" {
+		configPath = "config.hjson"
+	}
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "json" && format != "prompt" {
+		return fmt.Errorf("unsupported format: %s (use text, json or prompt)", format)
+	}
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	statusCfg := *cfg
+	statusCfg.OpsRepoPath = resolveOpsRepoPath(cfg)
+
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	return writeStatusReport(cmd.OutOrStdout(), status.Collect(ctx, &statusCfg), format)
+}
+
+// writeStatusReport は同期の状態を format（text / json / prompt）で出力する。
+func writeStatusReport(w io.Writer, report *status.Report, format string) error {
+	switch format {
+	case "", "text":
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "prompt":
+		fmt.Fprintln(w, promptSummary(report))
+		return nil
+	default:
+		return fmt.Errorf("unsupported format: %s (use text, json or prompt)", format)
+	}
+
+	match := "match"
+	if !report.BranchesMatch {
+		match = "MISMATCH"
+	}
+	fmt.Fprintf(w, "Branch: dev %s, ops %s (%s)\n", orDash(report.DevBranch), orDash(report.OpsBranch), match)
+
+	if pending := report.PendingChanges; pending != nil {
+		fmt.Fprintf(w, "Pending sync: %d files\n", pending.Count())
+		for _, group := range []struct {
+			mark  string
+			files []string
+		}{{"+", pending.Added}, {"~", pending.Modified}, {"-", pending.Deleted}} {
+			for _, path := range group.files {
+				fmt.Fprintf(w, "  %s %s\n", group.mark, path)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Pending fixups: %d\n", len(report.PendingFixups))
+	for _, commit := range report.PendingFixups {
+		fmt.Fprintf(w, "  %s %s\n", shortHash(commit.Hash), commit.Subject)
+	}
+
+	source := "inferred from ops repository"
+	switch report.Source {
+	case status.SourceDaemon:
+		source = fmt.Sprintf("from run daemon pid %d", report.DaemonPID)
+	case status.SourceHistory:
+		source = "from run history"
+	}
+	fmt.Fprintf(w, "Last sync:  %s\n", describeLastRun(report.LastSync))
+	fmt.Fprintf(w, "Last fixup: %s\n", describeLastRun(report.LastFixup))
+	fmt.Fprintf(w, "  (%s)\n", source)
+
+	if report.Paused {
+		fmt.Fprintf(w, "Paused: yes (%s)\n", report.PausedBy)
+	} else {
+		fmt.Fprintf(w, "Paused: no\n")
+	}
+
+	if lock := report.Lock; lock != nil {
+		stale := ""
+		if lock.Stale {
+			stale = ", stale"
+		}
+		fmt.Fprintf(w, "Lock: held by pid %d on %s (%s since %s%s)\n",
+			lock.PID, lock.Host, lock.Operation, lock.AcquiredAt.Local().Format("15:04:05"), stale)
+	} else {
+		fmt.Fprintf(w, "Lock: free\n")
+	}
+
+	switch {
+	case report.VHDX == nil:
+		fmt.Fprintf(w, "VHDX: not configured\n")
+	case report.VHDX.Mounted:
+		fmt.Fprintf(w, "VHDX: mounted at %s\n", report.VHDX.MountPoint)
+	default:
+		fmt.Fprintf(w, "VHDX: not mounted (%s)\n", report.VHDX.Path)
+	}
+
+	for _, msg := range report.Errors {
+		fmt.Fprintf(w, "Warning: %s\n", msg)
+	}
+	return nil
+}
+
+// promptSummary はシェルのプロンプト用の 1 行の要約を返す。
+// ブランチ名（不一致の場合は dev≠ops）に続けて、同期待ちのファイル数、未処理の fixup! コミット数、状態を並べる。
+func promptSummary(report *status.Report) string {
+	parts := []string{orDash(report.DevBranch)}
+	if !report.BranchesMatch {
+		parts[0] += "≠" + orDash(report.OpsBranch)
+	}
+	if n := report.PendingChanges.Count(); n > 0 {
+		parts = append(parts, fmt.Sprintf("+%d", n))
+	}
+	if n := len(report.PendingFixups); n > 0 {
+		parts = append(parts, fmt.Sprintf("f%d", n))
+	}
+	if report.LastSync != nil && report.LastSync.Error != "" {
+		parts = append(parts, "sync-failed")
+	}
+	if report.LastFixup != nil && report.LastFixup.Error != "" {
+		parts = append(parts, "fixup-failed")
+	}
+	if report.Paused {
+		parts = append(parts, "paused")
+	}
+	if report.Lock != nil {
+		parts = append(parts, "locked")
+	}
+	if report.VHDX != nil && !report.VHDX.Mounted {
+		parts = append(parts, "unmounted")
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeLastRun は直近の実行の時刻と経過時間、結果を返す。
+func describeLastRun(info *control.RunInfo) string {
+	if info == nil {
+		return "never"
+	}
+	result := "ok"
+	if info.Error != "" {
+		result = "failed: " + info.Error
+	}
+	return fmt.Sprintf("%s (%v ago, %s)", info.Time.Local().Format("2006-01-02 15:04:05"),
+		time.Since(info.Time).Round(time.Second), result)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/fixup"
+	"fixup-commit-sync-manager/internal/status"
+)
+
+func TestPromptSummary(t *testing.T) {
+	tests := []struct {
+		name   string
+		report *status.Report
+		want   string
+	}{
+		{
+			name:   "clean",
+			report: &status.Report{DevBranch: "main", OpsBranch: "main", BranchesMatch: true, PendingChanges: &status.PendingChanges{}},
+			want:   "main",
+		},
+		{
+			name: "busy",
+			report: &status.Report{
+				DevBranch:      "feature",
+				OpsBranch:      "main",
+				PendingChanges: &status.PendingChanges{Added: []string{"a.cpp"}, Modified: []string{"b.cpp", "c.cpp"}},
+				PendingFixups:  []fixup.PlannedCommit{{Hash: "abc", Subject: "fixup! A"}},
+				LastSync:       &control.RunInfo{Operation: "sync", Time: time.Now(), Error: "failed"},
+				Paused:         true,
+				VHDX:           &status.VHDXState{Mounted: false},
+			},
+			want: "feature≠main +3 f1 sync-failed paused unmounted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promptSummary(tt.report); got != tt.want {
+				t.Errorf("promptSummary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteStatusReport(t *testing.T) {
+	report := &status.Report{
+		DevBranch:      "main",
+		OpsBranch:      "other",
+		PendingChanges: &status.PendingChanges{Deleted: []string{"old.cpp"}},
+		Source:         status.SourceRepository,
+	}
+
+	var buf bytes.Buffer
+	if err := writeStatusReport(&buf, report, "text"); err != nil {
+		t.Fatalf("writeStatusReport() failed: %v", err)
+	}
+	for _, want := range []string{"Branch: dev main, ops other (MISMATCH)", "Pending sync: 1 files", "  - old.cpp", "Last sync:  never", "Lock: free", "VHDX: not configured"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Output should contain %q:\n%s", want, buf.String())
+		}
+	}
+
+	if err := writeStatusReport(&buf, report, "xml"); err == nil {
+		t.Error("Unsupported format should be rejected")
+	}
+}
@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/retry"
 	"fixup-commit-sync-manager/internal/sync"
 
 	"github.com/spf13/cobra"
@@ -38,23 +41,31 @@ func runSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	if dryRun {
-		cfg.DryRun = true
-	}
-	if verbose {
-		cfg.Verbose = true
+	applyFlags := func(cfg *config.Config) {
+		if dryRun {
+			cfg.DryRun = true
+		}
+		if verbose {
+			cfg.Verbose = true
+		}
 	}
+	applyFlags(cfg)
 
 	syncer := sync.NewFileSyncer(cfg)
 
+	ctx, stop := shutdownContext()
+	defer stop()
+
 	if continuous {
-		return runContinuousSync(syncer, cfg)
+		stopMetrics := startMetricsExport(cfg)
+		defer stopMetrics()
+		return runContinuousSync(ctx, syncer, cfg, watchConfig(ctx, configPath, cfg, applyFlags))
 	}
 
-	return runSingleSync(syncer, cfg)
+	return runSingleSync(ctx, syncer, cfg)
 }
 
-func runSingleSync(syncer *sync.FileSyncer, cfg *config.Config) error {
+func runSingleSync(ctx context.Context, syncer *sync.FileSyncer, cfg *config.Config) error {
 	if cfg.Verbose {
 		fmt.Println("Starting single sync operation...")
 		fmt.Printf("Dev Repository: %s\n", cfg.DevRepoPath)
@@ -66,11 +77,13 @@ func runSingleSync(syncer *sync.FileSyncer, cfg *config.Config) error {
 		return nil
 	}
 
-	result, err := syncer.Sync()
+	result, err := syncer.Sync(ctx)
 	if err != nil {
 		return fmt.Errorf("sync failed: %w", err)
 	}
 
+	printUnrelatedChanges(result.UnrelatedChanges)
+
 	if len(result.FilesAdded)+len(result.FilesModified)+len(result.FilesDeleted) == 0 {
 		if cfg.Verbose {
 			fmt.Println("No changes detected - sync skipped")
@@ -111,7 +124,9 @@ func runSingleSync(syncer *sync.FileSyncer, cfg *config.Config) error {
 	return nil
 }
 
-func runContinuousSync(syncer *sync.FileSyncer, cfg *config.Config) error {
+// runContinuousSync は ctx が終了するまで設定の間隔で同期を繰り返す。
+// reloads から新しい設定を受け取ると、同期の合間に切り替えて間隔を設定し直す。
+func runContinuousSync(ctx context.Context, syncer *sync.FileSyncer, cfg *config.Config, reloads <-chan *config.Config) error {
 	interval, err := cfg.GetSyncIntervalDuration()
 	if err != nil {
 		return fmt.Errorf("invalid sync interval: %w", err)
@@ -122,11 +137,37 @@ func runContinuousSync(syncer *sync.FileSyncer, cfg *config.Config) error {
 	fmt.Printf("Ops Repository: %s\n", cfg.OpsRepoPath)
 	fmt.Println("Press Ctrl+C to stop")
 
+	breaker := retry.NewBreaker(cfg, "sync", interval)
+	breaker.Probe = syncer.Probe
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			fmt.Println("Stopping continuous sync")
+			return nil
+
+		case next, ok := <-reloads:
+			if !ok {
+				reloads = nil
+				continue
+			}
+			cfg = next
+			syncer = sync.NewFileSyncer(cfg)
+
+			nextInterval, _ := cfg.GetSyncIntervalDuration()
+			nextBreaker := retry.NewBreaker(cfg, "sync", nextInterval)
+			nextBreaker.Probe = syncer.Probe
+			nextBreaker.Inherit(breaker)
+			breaker = nextBreaker
+			if nextInterval != interval {
+				interval = nextInterval
+				ticker.Reset(breaker.Delay())
+			}
+			fmt.Printf("[%s] Configuration reloaded (interval: %v)\n", time.Now().Format("15:04:05"), interval)
+
 		case <-ticker.C:
 			if cfg.Verbose {
 				fmt.Printf("\n[%s] Starting sync operation...\n", time.Now().Format("15:04:05"))
@@ -137,12 +178,28 @@ func runContinuousSync(syncer *sync.FileSyncer, cfg *config.Config) error {
 				continue
 			}
 
-			result, err := syncer.Sync()
+			var result *sync.SyncResult
+			err := breaker.Do(ctx, func(ctx context.Context) error {
+				var err error
+				result, err = syncer.Sync(ctx)
+				return err
+			})
+			// 失敗が続く間は間隔を延ばし、回路が開いている間は確認の間隔で待つ。
+			delay := breaker.Delay()
+			ticker.Reset(delay)
+			if errors.Is(err, retry.ErrOpen) {
+				fmt.Printf("[%s] Sync skipped: %v (next probe in %v)\n", time.Now().Format("15:04:05"), err, delay.Round(time.Second))
+				continue
+			}
 			if err != nil {
-				fmt.Printf("[%s] Sync failed: %v\n", time.Now().Format("15:04:05"), err)
+				fmt.Printf("[%s] Sync failed: %v (next attempt in %v)\n", time.Now().Format("15:04:05"), err, delay.Round(time.Second))
 				continue
 			}
 
+			if cfg.Verbose {
+				printUnrelatedChanges(result.UnrelatedChanges)
+			}
+
 			if len(result.FilesAdded)+len(result.FilesModified)+len(result.FilesDeleted) == 0 {
 				if cfg.Verbose {
 					fmt.Printf("[%s] No changes detected\n", time.Now().Format("15:04:05"))
@@ -163,3 +220,14 @@ func runContinuousSync(syncer *sync.FileSyncer, cfg *config.Config) error {
 		}
 	}
 }
+
+// printUnrelatedChanges は同期対象外のためコミットしなかった Ops 側の変更を表示する。
+func printUnrelatedChanges(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Printf("Unrelated ops changes (not committed): %d\n", len(paths))
+	for _, path := range paths {
+		fmt.Printf("  ? %s\n", path)
+	}
+}
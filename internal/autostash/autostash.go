@@ -0,0 +1,152 @@
+package autostash
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// stashMarker はツールが作成した自動 stash を識別するメッセージ接頭辞。
+const stashMarker = "fcsm-autostash/"
+
+// detachedKey は detached HEAD 状態の作業内容を退避する際のキー。
+const detachedKey = "(detached)"
+
+// Stasher は Ops リポジトリの未コミット作業をブランチ単位で退避・復元する。
+type Stasher struct {
+	git git.Runner
+}
+
+// Entry は自動 stash の一件を表す。
+type Entry struct {
+	Ref    string
+	Branch string
+}
+
+func NewStasher(runner git.Runner) *Stasher {
+	return &Stasher{git: runner}
+}
+
+// Save は作業ツリーが dirty な場合に branch 用の自動 stash を作成する。
+// 未追跡ファイルも含めて退避し、退避を行った場合は true を返す。
+func (s *Stasher) Save(ctx context.Context, branch string) (bool, error) {
+	dirty, err := s.isDirty(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !dirty {
+		return false, nil
+	}
+
+	if _, err := s.git.Run(ctx, "stash", "push", "--include-untracked", "-m", stashMessage(branch)); err != nil {
+		return false, fmt.Errorf("git stash push failed: %w", err)
+	}
+
+	// stash 後も dirty な場合は退避しきれていないため切り替えを中止させる。
+	dirty, err = s.isDirty(ctx)
+	if err != nil {
+		return true, err
+	}
+	if dirty {
+		return true, fmt.Errorf("working tree is still dirty after stashing changes of branch %s", branchKey(branch))
+	}
+
+	return true, nil
+}
+
+// Restore は branch 用の自動 stash が存在すれば作業ツリーに復元する。
+// 復元に失敗した場合は作業ツリーを元のクリーンな状態に戻し、stash は残したままエラーを返す。
+func (s *Stasher) Restore(ctx context.Context, branch string) (bool, error) {
+	entries, err := s.List(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	key := branchKey(branch)
+	var target *Entry
+	for i := range entries {
+		if entries[i].Branch == key {
+			target = &entries[i]
+			break
+		}
+	}
+	if target == nil {
+		return false, nil
+	}
+
+	dirty, err := s.isDirty(ctx)
+	if err != nil {
+		return false, err
+	}
+	if dirty {
+		return false, fmt.Errorf("cannot restore %s: working tree is not clean", target.Ref)
+	}
+
+	if _, err := s.git.Run(ctx, "stash", "apply", "--index", target.Ref); err != nil {
+		s.cleanWorkingTree(ctx)
+		return false, fmt.Errorf("git stash apply %s failed (stash kept): %w", target.Ref, err)
+	}
+
+	if _, err := s.git.Run(ctx, "stash", "drop", target.Ref); err != nil {
+		return true, fmt.Errorf("git stash drop %s failed: %w", target.Ref, err)
+	}
+
+	return true, nil
+}
+
+// List はツールが作成した自動 stash を新しい順に返す。
+func (s *Stasher) List(ctx context.Context) ([]Entry, error) {
+	output, err := s.git.Run(ctx, "stash", "list", "--format=%gd%x00%gs")
+	if err != nil {
+		return nil, fmt.Errorf("git stash list failed: %w", err)
+	}
+
+	var entries []Entry
+	for _, line := range strings.Split(string(output), "\n") {
+		ref, subject, ok := strings.Cut(line, "\x00")
+		if !ok {
+			continue
+		}
+		// stash のサブジェクトは "On <branch>: <message>" 形式。
+		idx := strings.Index(subject, ": "+stashMarker)
+		if idx < 0 {
+			continue
+		}
+		entries = append(entries, Entry{
+			Ref:    ref,
+			Branch: subject[idx+len(": "+stashMarker):],
+		})
+	}
+
+	return entries, nil
+}
+
+// isDirty は作業ツリーまたはインデックスに未コミットの変更があるかを返す。
+func (s *Stasher) isDirty(ctx context.Context) (bool, error) {
+	entries, err := git.Status(ctx, s.git, "--untracked-files=all")
+	if err != nil {
+		return false, fmt.Errorf("git status failed: %w", err)
+	}
+	return len(entries) > 0, nil
+}
+
+// cleanWorkingTree は失敗した stash apply の途中結果を破棄する。
+// 呼び出し前の作業ツリーはクリーンであることが前提。ctx が終了していても破棄する。
+func (s *Stasher) cleanWorkingTree(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	s.git.Run(ctx, "reset", "--hard", "HEAD")
+	s.git.Run(ctx, "clean", "-fd")
+}
+
+func stashMessage(branch string) string {
+	return stashMarker + branchKey(branch)
+}
+
+func branchKey(branch string) string {
+	if branch == "" {
+		return detachedKey
+	}
+	return branch
+}
@@ -0,0 +1,165 @@
+package autostash
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func TestSaveCleanWorkingTree(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := createTestRepository(t)
+	stasher := NewStasher(git.NewRunner("git", repo))
+
+	stashed, err := stasher.Save(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if stashed {
+		t.Error("Save() should not stash a clean working tree")
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := createTestRepository(t)
+	stasher := NewStasher(git.NewRunner("git", repo))
+
+	os.WriteFile(filepath.Join(repo, "tracked.txt"), []byte("modified"), 0644)
+	os.WriteFile(filepath.Join(repo, "untracked.txt"), []byte("new"), 0644)
+
+	stashed, err := stasher.Save(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if !stashed {
+		t.Fatal("Save() should stash a dirty working tree")
+	}
+
+	if _, err := os.Stat(filepath.Join(repo, "untracked.txt")); !os.IsNotExist(err) {
+		t.Error("Untracked file should be stashed away")
+	}
+
+	entries, err := stasher.List(context.Background())
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Branch != "main" {
+		t.Fatalf("Expected one autostash entry for main, got %+v", entries)
+	}
+
+	// 別ブランチ用の stash は復元されない。
+	restored, err := stasher.Restore(context.Background(), "feature")
+	if err != nil {
+		t.Fatalf("Restore(feature) failed: %v", err)
+	}
+	if restored {
+		t.Error("Restore() should not apply a stash of another branch")
+	}
+
+	restored, err = stasher.Restore(context.Background(), "main")
+	if err != nil {
+		t.Fatalf("Restore(main) failed: %v", err)
+	}
+	if !restored {
+		t.Fatal("Restore() should apply the stash of main")
+	}
+
+	content, _ := os.ReadFile(filepath.Join(repo, "tracked.txt"))
+	if string(content) != "modified" {
+		t.Errorf("Expected restored content 'modified', got %q", string(content))
+	}
+	if _, err := os.Stat(filepath.Join(repo, "untracked.txt")); err != nil {
+		t.Error("Untracked file should be restored")
+	}
+
+	entries, _ = stasher.List(context.Background())
+	if len(entries) != 0 {
+		t.Errorf("Restored stash should be dropped, got %+v", entries)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := createTestRepository(t)
+	runner := git.NewRunner("git", repo)
+	stasher := NewStasher(runner)
+	ctx := context.Background()
+	initial, _ := git.Output(ctx, runner, "branch", "--show-current")
+
+	os.WriteFile(filepath.Join(repo, "tracked.txt"), []byte("modified"), 0644)
+	if err := stasher.Switch(ctx, "feature"); err != nil {
+		t.Fatalf("Switch(feature) failed: %v", err)
+	}
+	if branch, _ := git.Output(ctx, runner, "branch", "--show-current"); branch != "feature" {
+		t.Fatalf("Expected to be on feature, got %s", branch)
+	}
+	if content, _ := os.ReadFile(filepath.Join(repo, "tracked.txt")); string(content) != "initial" {
+		t.Errorf("Changes of %s should be stashed away, got %q", initial, string(content))
+	}
+
+	// 切り替えに失敗した場合は元のブランチと退避した作業を戻す。
+	os.WriteFile(filepath.Join(repo, "feature.txt"), []byte("feature"), 0644)
+	if err := stasher.Switch(ctx, "bad..name"); err == nil {
+		t.Fatal("Switch() to an invalid branch name should fail")
+	}
+	if branch, _ := git.Output(ctx, runner, "branch", "--show-current"); branch != "feature" {
+		t.Errorf("Expected to stay on feature, got %s", branch)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "feature.txt")); err != nil {
+		t.Error("Changes of feature should be restored after a failed switch")
+	}
+	os.Remove(filepath.Join(repo, "feature.txt"))
+
+	if err := stasher.Switch(ctx, initial); err != nil {
+		t.Fatalf("Switch(%s) failed: %v", initial, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(repo, "tracked.txt")); string(content) != "modified" {
+		t.Errorf("Changes of %s should be restored, got %q", initial, string(content))
+	}
+
+	// 既に目的のブランチにいても、残っている自動 stash をクリーンな作業ツリーに復元する。
+	if _, err := stasher.Save(context.Background(), initial); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := stasher.Switch(ctx, initial); err != nil {
+		t.Fatalf("Switch(%s) on the same branch failed: %v", initial, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(repo, "tracked.txt")); string(content) != "modified" {
+		t.Errorf("Leftover stash of %s should be restored, got %q", initial, string(content))
+	}
+	if entries, _ := stasher.List(context.Background()); len(entries) != 0 {
+		t.Errorf("Expected no autostash entries left, got %+v", entries)
+	}
+}
+
+func TestBranchKey(t *testing.T) {
+	if branchKey("") != detachedKey {
+		t.Errorf("Empty branch should map to %q", detachedKey)
+	}
+	if stashMessage("feature/x") != "fcsm-autostash/feature/x" {
+		t.Errorf("Unexpected stash message: %s", stashMessage("feature/x"))
+	}
+}
+
+// createTestRepository は tracked.txt をコミットしたリポジトリを作成する。
+func createTestRepository(t *testing.T) string {
+	repo := testutil.CreateRepository(t)
+	os.WriteFile(filepath.Join(repo, "tracked.txt"), []byte("initial"), 0644)
+	testutil.Git(t, repo, "add", "tracked.txt")
+	testutil.Git(t, repo, "commit", "-m", "Add tracked.txt")
+	return repo
+}
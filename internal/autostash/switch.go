@@ -0,0 +1,118 @@
+package autostash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// Switch は作業ツリーを branch に切り替える。
+// 切り替え前のブランチの未コミット作業を退避し、切り替え先のブランチで以前退避した作業があれば復元する。
+// branch がローカルに無い場合は origin のブランチから、それも無い場合は現在の HEAD から作成する。
+// 切り替えに失敗した場合は元のブランチと退避した作業を戻し、戻せなかった理由もエラーに含める。
+func (s *Stasher) Switch(ctx context.Context, branch string) error {
+	current, err := git.Output(ctx, s.git, "branch", "--show-current")
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	// 既に目的のブランチにいる場合も、以前の切り替えで復元できずに残った作業があれば復元する。
+	// 作業ツリーが dirty な場合は作業中とみなして復元しない。
+	if current == branch {
+		dirty, err := s.isDirty(ctx)
+		if err != nil || dirty {
+			return err
+		}
+		if _, err := s.Restore(ctx, branch); err != nil {
+			return fmt.Errorf("failed to restore stashed changes of branch %s: %w", branch, err)
+		}
+		return nil
+	}
+
+	stashed, err := s.Save(ctx, current)
+	if err != nil {
+		// stash は作成したが退避しきれなかった場合は、退避した分を作業ツリーに戻す。
+		if stashed {
+			err = errors.Join(err, s.undoSave(ctx, current))
+		}
+		return fmt.Errorf("failed to stash changes on branch %s: %w", branchKey(current), err)
+	}
+
+	if err := s.checkout(ctx, branch); err != nil {
+		return errors.Join(err, s.rollback(ctx, current, stashed))
+	}
+
+	if _, err := s.Restore(ctx, branch); err != nil {
+		return fmt.Errorf("failed to restore stashed changes of branch %s: %w", branch, err)
+	}
+	return nil
+}
+
+// checkout は branch に切り替える。ローカルに無い場合は origin のブランチ、または現在の HEAD から作成する。
+func (s *Stasher) checkout(ctx context.Context, branch string) error {
+	args := []string{"checkout", branch}
+	exists, err := git.Succeeds(ctx, s.git, "show-ref", "--verify", "--quiet", "refs/heads/"+branch)
+	if err != nil {
+		return fmt.Errorf("failed to check branch %s: %w", branch, err)
+	}
+	if !exists {
+		args = []string{"checkout", "-b", branch}
+		remote, err := git.Succeeds(ctx, s.git, "show-ref", "--verify", "--quiet", "refs/remotes/origin/"+branch)
+		if err != nil {
+			return fmt.Errorf("failed to check branch origin/%s: %w", branch, err)
+		}
+		if remote {
+			args = append(args, "origin/"+branch)
+		}
+	}
+
+	if _, err := s.git.Run(ctx, args...); err != nil {
+		return fmt.Errorf("failed to checkout branch %s: %w", branch, err)
+	}
+	return nil
+}
+
+// rollback は切り替えに失敗した際、元のブランチと退避した作業を戻す。ctx が終了していても戻す。
+func (s *Stasher) rollback(ctx context.Context, original string, stashed bool) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var errs []error
+	if original != "" {
+		current, err := git.Output(ctx, s.git, "branch", "--show-current")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get current branch: %w", err))
+		} else if current != original {
+			if _, err := s.git.Run(ctx, "checkout", original); err != nil {
+				errs = append(errs, fmt.Errorf("failed to switch back to branch %s: %w", original, err))
+			}
+		}
+	}
+	if stashed {
+		if _, err := s.Restore(ctx, original); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore stashed changes of branch %s: %w", branchKey(original), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// undoSave は退避しきれなかった Save の stash を作業ツリーに戻す。
+// 作業ツリーには退避しきれなかった変更が残っているため、Restore と異なりクリーンであることを求めない。
+func (s *Stasher) undoSave(ctx context.Context, branch string) error {
+	ctx = context.WithoutCancel(ctx)
+	entries, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Branch != branchKey(branch) {
+			continue
+		}
+		if _, err := s.git.Run(ctx, "stash", "pop", "--index", entry.Ref); err != nil {
+			return fmt.Errorf("git stash pop %s failed (stash kept): %w", entry.Ref, err)
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// RefPrefix はバックアップ ref の名前空間。refs/fcsm/backup/<branch>/<timestamp> の形式で作成する。
+const RefPrefix = "refs/fcsm/backup/"
+
+// timestampFormat は ref 名に使用するタイムスタンプの形式。辞書順が時系列順になる。
+const timestampFormat = "20060102T150405.000000000Z"
+
+// detachedKey は detached HEAD 状態でのバックアップに使うブランチ名。
+const detachedKey = "detached"
+
+// Backup は rebase 前の状態を保持するバックアップ ref。
+type Backup struct {
+	Ref    string
+	Branch string
+	Commit string
+	Time   time.Time
+}
+
+// Name は ref 名からタイムスタンプ部分のみを返す。--to の指定に使用できる。
+func (b Backup) Name() string {
+	return b.Ref[strings.LastIndex(b.Ref, "/")+1:]
+}
+
+// Manager はバックアップ ref の作成・一覧・復元を行う。
+type Manager struct {
+	git git.Runner
+	now func() time.Time
+	// keep はブランチごとに残すバックアップの数。0 の場合は数で削除しない。
+	keep int
+	// maxAge はこれより古いバックアップを削除する経過時間。0 の場合は経過時間で削除しない。
+	maxAge time.Duration
+}
+
+// NewManager は cfg の保持設定（backupKeep / backupMaxAge）で runner のバックアップを管理する Manager を返す。
+func NewManager(cfg *config.Config, runner git.Runner) *Manager {
+	maxAge, _ := cfg.GetBackupMaxAgeDuration()
+	return &Manager{git: runner, now: time.Now, keep: cfg.BackupKeep, maxAge: maxAge}
+}
+
+// Create は HEAD を指すバックアップ ref を作成して ref 名を返す。
+// 作成後、保持設定を超えた古いバックアップを削除する。削除に失敗した場合は作成した ref 名とともにエラーを返す。
+func (m *Manager) Create(ctx context.Context, branch string) (string, error) {
+	ref, err := m.createAt(ctx, branch, "HEAD", "fcsm: backup before rebase")
+	if err != nil {
+		return "", err
+	}
+	if _, err := m.Prune(ctx, branch); err != nil {
+		return ref, err
+	}
+	return ref, nil
+}
+
+func (m *Manager) createAt(ctx context.Context, branch, commit, reason string) (string, error) {
+	ref := RefPrefix + branchKey(branch) + "/" + m.now().UTC().Format(timestampFormat)
+
+	// 旧値に空文字列を指定し、既存の ref を上書きしないようにする。
+	if _, err := m.git.Run(ctx, "update-ref", "-m", reason, ref, commit, ""); err != nil {
+		return "", fmt.Errorf("failed to create backup ref %s: %w", ref, err)
+	}
+	return ref, nil
+}
+
+// Prune は branch のバックアップのうち、新しい方から keep 個を超えたものと maxAge より古いものを削除し、
+// 削除した ref 名を返す。最新のバックアップは常に残す。
+func (m *Manager) Prune(ctx context.Context, branch string) ([]string, error) {
+	if m.keep <= 0 && m.maxAge <= 0 {
+		return nil, nil
+	}
+	backups, err := m.List(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+	var stdin strings.Builder
+	for i, b := range backups {
+		overflow := m.keep > 0 && i >= m.keep
+		expired := m.maxAge > 0 && m.now().Sub(b.Time) > m.maxAge
+		if i == 0 || !overflow && !expired {
+			continue
+		}
+		// 旧値を指定し、削除までの間に更新された ref は削除しない。
+		fmt.Fprintf(&stdin, "delete %s %s\n", b.Ref, b.Commit)
+		pruned = append(pruned, b.Ref)
+	}
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+
+	if _, err := m.git.RunWithOptions(ctx, git.RunOptions{Stdin: strings.NewReader(stdin.String())}, "update-ref", "--stdin"); err != nil {
+		return nil, fmt.Errorf("failed to prune backup refs of branch %s: %w", branchKey(branch), err)
+	}
+	return pruned, nil
+}
+
+// List は branch のバックアップを新しい順に返す。
+func (m *Manager) List(ctx context.Context, branch string) ([]Backup, error) {
+	prefix := RefPrefix + branchKey(branch) + "/"
+	output, err := m.git.Run(ctx, "for-each-ref", "--format=%(refname)%00%(objectname)", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backup refs: %w", err)
+	}
+
+	var backups []Backup
+	for _, line := range strings.Split(string(output), "\n") {
+		ref, commit, ok := strings.Cut(line, "\x00")
+		if !ok {
+			continue
+		}
+		name := strings.TrimPrefix(ref, prefix)
+		// サブブランチ（feature と feature/x など）のバックアップは含めない。
+		if strings.Contains(name, "/") {
+			continue
+		}
+		timestamp, err := time.Parse(timestampFormat, name)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Ref: ref, Branch: branchKey(branch), Commit: commit, Time: timestamp})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// Resolve は name に対応するバックアップを返す。
+// name は完全な ref 名かタイムスタンプ部分。空の場合は最新のバックアップを返す。
+func (m *Manager) Resolve(ctx context.Context, branch, name string) (*Backup, error) {
+	backups, err := m.List(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+	if len(backups) == 0 {
+		return nil, fmt.Errorf("no backups found for branch %s", branchKey(branch))
+	}
+	if name == "" {
+		return &backups[0], nil
+	}
+	for i := range backups {
+		if backups[i].Ref == name || backups[i].Name() == name {
+			return &backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("backup %s not found for branch %s", name, branchKey(branch))
+}
+
+// Restore は branch をバックアップの状態に戻す。
+// 復元前の状態も新たなバックアップとして保存するため、復元自体も取り消せる。
+// branch がチェックアウト中の場合、作業ツリーの未コミット変更は保持される。
+func (m *Manager) Restore(ctx context.Context, branch string, b *Backup) (string, error) {
+	current, err := git.Output(ctx, m.git, "branch", "--show-current")
+	if err != nil {
+		return "", fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	if current == branch {
+		saved, err := m.createAt(ctx, branch, "HEAD", "fcsm: backup before undo")
+		if err != nil {
+			return "", err
+		}
+		if _, err := m.git.Run(ctx, "reset", "--keep", b.Commit); err != nil {
+			return saved, fmt.Errorf("failed to reset %s to %s: %w", branch, b.Ref, err)
+		}
+		return saved, nil
+	}
+
+	if branch == "" {
+		return "", fmt.Errorf("cannot restore a detached HEAD backup while another branch is checked out")
+	}
+
+	// チェックアウトされていないブランチは ref のみを更新する。
+	head, err := git.Output(ctx, m.git, "rev-parse", "refs/heads/"+branch)
+	if err != nil {
+		return "", fmt.Errorf("branch %s not found: %w", branch, err)
+	}
+	saved, err := m.createAt(ctx, branch, head, "fcsm: backup before undo")
+	if err != nil {
+		return "", err
+	}
+	if _, err := m.git.Run(ctx, "update-ref", "-m", "fcsm: undo to "+b.Ref, "refs/heads/"+branch, b.Commit, head); err != nil {
+		return saved, fmt.Errorf("failed to update %s to %s: %w", branch, b.Ref, err)
+	}
+	return saved, nil
+}
+
+func branchKey(branch string) string {
+	if branch == "" {
+		return detachedKey
+	}
+	return branch
+}
@@ -0,0 +1,206 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func TestCreateAndList(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	m := newTestManager(repo)
+	ctx := context.Background()
+
+	first, err := m.Create(ctx, "feature/x")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if !strings.HasPrefix(first, RefPrefix+"feature/x/") {
+		t.Errorf("Unexpected backup ref: %s", first)
+	}
+
+	commit(t, repo, "second")
+	second, err := m.Create(ctx, "feature/x")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// 別ブランチのバックアップは一覧に含まれない。
+	if _, err := m.Create(ctx, "feature"); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	backups, err := m.List(ctx, "feature/x")
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(backups) != 2 || backups[0].Ref != second || backups[1].Ref != first {
+		t.Fatalf("Expected backups newest first, got %+v", backups)
+	}
+
+	latest, err := m.Resolve(ctx, "feature/x", "")
+	if err != nil || latest.Ref != second {
+		t.Errorf("Resolve(\"\") should return the latest backup, got %+v, %v", latest, err)
+	}
+	byName, err := m.Resolve(ctx, "feature/x", backups[1].Name())
+	if err != nil || byName.Ref != first {
+		t.Errorf("Resolve(name) should match the timestamp, got %+v, %v", byName, err)
+	}
+	if _, err := m.Resolve(ctx, "feature/x", "missing"); err == nil {
+		t.Error("Resolve() should fail for an unknown backup")
+	}
+}
+
+func TestRestoreCheckedOutBranch(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	m := newTestManager(repo)
+	ctx := context.Background()
+	branch := currentBranch(t, repo)
+
+	original := revParse(t, repo, "HEAD")
+	if _, err := m.Create(ctx, branch); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	commit(t, repo, "rewritten")
+
+	// 未コミットの変更は復元後も保持される。
+	os.WriteFile(filepath.Join(repo, "local.txt"), []byte("local"), 0644)
+
+	target, err := m.Resolve(ctx, branch, "")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	saved, err := m.Restore(ctx, branch, target)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+
+	if head := revParse(t, repo, "HEAD"); head != original {
+		t.Errorf("Expected HEAD %s after restore, got %s", original, head)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "local.txt")); err != nil {
+		t.Error("Untracked work should be kept")
+	}
+
+	// 復元前の状態もバックアップされている。
+	backups, _ := m.List(ctx, branch)
+	if len(backups) != 2 || backups[0].Ref != saved {
+		t.Errorf("Restore() should back up the previous state, got %+v", backups)
+	}
+}
+
+func TestRestoreOtherBranch(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	m := newTestManager(repo)
+	ctx := context.Background()
+
+	testutil.Git(t, repo, "branch", "other")
+	original := revParse(t, repo, "other")
+	if _, err := m.createAt(ctx, "other", "other", "test"); err != nil {
+		t.Fatalf("createAt() failed: %v", err)
+	}
+	testutil.Git(t, repo, "commit", "--allow-empty", "-m", "advance")
+	testutil.Git(t, repo, "branch", "-f", "other", "HEAD")
+
+	target, err := m.Resolve(ctx, "other", "")
+	if err != nil {
+		t.Fatalf("Resolve() failed: %v", err)
+	}
+	if _, err := m.Restore(ctx, "other", target); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if head := revParse(t, repo, "other"); head != original {
+		t.Errorf("Expected other at %s, got %s", original, head)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	m := newTestManager(repo)
+	m.keep = 3
+	m.maxAge = 0
+	ctx := context.Background()
+
+	var refs []string
+	for i := 0; i < 4; i++ {
+		ref, err := m.Create(ctx, "main")
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		refs = append(refs, ref)
+	}
+	if _, err := m.Create(ctx, "other"); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// 数を超えた最も古いバックアップのみ削除し、別ブランチのバックアップは残す。
+	backups, _ := m.List(ctx, "main")
+	if len(backups) != 3 || backups[2].Ref != refs[1] {
+		t.Fatalf("Expected the 3 newest backups to be kept, got %+v", backups)
+	}
+	if others, _ := m.List(ctx, "other"); len(others) != 1 {
+		t.Errorf("Backups of another branch should be kept, got %+v", others)
+	}
+
+	// 経過時間を超えたバックアップは削除するが、最新のバックアップは常に残す。
+	m.keep = 0
+	m.maxAge = time.Minute
+	now := m.now()
+	m.now = func() time.Time { return now.Add(time.Hour) }
+	pruned, err := m.Prune(ctx, "main")
+	if err != nil {
+		t.Fatalf("Prune() failed: %v", err)
+	}
+	if len(pruned) != 2 {
+		t.Errorf("Expected 2 expired backups to be pruned, got %v", pruned)
+	}
+	if backups, _ := m.List(ctx, "main"); len(backups) != 1 || backups[0].Ref != refs[3] {
+		t.Errorf("Expected only the latest backup to be kept, got %+v", backups)
+	}
+}
+
+// newTestManager は呼び出しごとに異なる時刻を返す Manager を作成する。
+func newTestManager(repo string) *Manager {
+	m := NewManager(config.DefaultConfig(), git.NewRunner("git", repo))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	return m
+}
+
+func commit(t *testing.T, repo, message string) {
+	testutil.Git(t, repo, "commit", "--allow-empty", "-m", message)
+}
+
+func currentBranch(t *testing.T, repo string) string {
+	return testutil.Git(t, repo, "branch", "--show-current")
+}
+
+func revParse(t *testing.T, repo, rev string) string {
+	return testutil.Git(t, repo, "rev-parse", rev)
+}
@@ -3,12 +3,40 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/hjson/hjson-go/v4"
 )
 
+// fixupTarget の設定値。
+const (
+	// FixupTargetBase は変更全体を一つの基準コミットに fixup する。
+	FixupTargetBase = "base"
+	// FixupTargetAbsorb はハンクごとに、その行を最後に変更したコミットへ fixup する。
+	FixupTargetAbsorb = "absorb"
+)
+
+// fixupStrategy の設定値。
+const (
+	// FixupStrategyFixup は fixup! コミットを作成する。autosquash で対象コミットのメッセージはそのまま残る。
+	FixupStrategyFixup = "fixup"
+	// FixupStrategyAmend は amend! コミットを作成する。autosquash で対象コミットのメッセージも書き換える。
+	FixupStrategyAmend = "amend!"
+	// FixupStrategySquash は squash! コミットを作成する。autosquash で両方のメッセージを残す。
+	FixupStrategySquash = "squash!"
+	// FixupStrategyAmendLast は HEAD が未公開の同期コミットであれば、rebase せずに直接 amend する。
+	FixupStrategyAmendLast = "amend-last"
+)
+
+// compactionWindow の設定値。
+const (
+	CompactionWindowHour = "hour"
+	CompactionWindowDay  = "day"
+)
+
 type NotifyConfig struct {
 	SlackWebhookURL string `json:"slackWebhookUrl,omitempty"`
 }
@@ -22,19 +50,64 @@ type Config struct {
 	SyncInterval      string        `json:"syncInterval"`
 	PauseLockFile     string        `json:"pauseLockFile"`
 	GitExecutable     string        `json:"gitExecutable"`
+	GitTimeout        string        `json:"gitTimeout"`
+	// IndexLockStaleAfter は Ops の index.lock を放置されたとみなして除去するまでの経過時間。
+	IndexLockStaleAfter string      `json:"indexLockStaleAfter"`
+	// RepoLockWait は他のプロセスが Ops リポジトリのロックを保持している場合に待つ時間。0 の場合は待たずに失敗する。
+	RepoLockWait        string      `json:"repoLockWait"`
+	// RepoLockStaleAfter は heartbeat が更新されないロックを放置されたとみなして引き継ぐまでの経過時間。
+	RepoLockStaleAfter  string      `json:"repoLockStaleAfter"`
 	CommitTemplate    string        `json:"commitTemplate"`
 	AuthorName        string        `json:"authorName,omitempty"`
 	AuthorEmail       string        `json:"authorEmail,omitempty"`
 	FixupInterval     string        `json:"fixupInterval"`
 	FixupMsgPrefix    string        `json:"fixupMessagePrefix"`
+	FixupTarget       string        `json:"fixupTarget"`
+	FixupStrategy     string        `json:"fixupStrategy"`
+	// FixupUpstream は fixup と autosquash の範囲の起点とする upstream（例: origin/main）。
+	// HEAD とのマージベースより前のコミットは書き換えない。
+	FixupUpstream     string            `json:"fixupUpstream,omitempty"`
+	// BranchUpstreams はブランチごとに FixupUpstream を上書きする。
+	BranchUpstreams   map[string]string `json:"branchUpstreams,omitempty"`
+	// ProtectedRefs は autosquash で書き換えてはならないコミットを示す ref のパターン（例: refs/heads/main, refs/tags/）。
+	// リモート追跡ブランチ（refs/remotes/）は常に保護される。
+	ProtectedRefs     []string          `json:"protectedRefs,omitempty"`
+	// RewriteNotesRefs は履歴の書き換え時に、書き換え後のコミットへ移す git notes の ref（例: refs/notes/commits）。
+	RewriteNotesRefs  []string          `json:"rewriteNotesRefs,omitempty"`
+	// CompactionWindow は連続する同期コミットをまとめる時間枠（hour / day）。空の場合はまとめない。
+	CompactionWindow  string            `json:"compactionWindow,omitempty"`
 	AutosquashEnabled bool          `json:"autosquashEnabled"`
+	// FixupAllBranches は現在のブランチだけでなく、fixup! コミットや退避された作業が残るすべての Ops ブランチを処理する。
+	FixupAllBranches  bool          `json:"fixupAllBranches,omitempty"`
+	// BackupKeep は rebase などの書き換え前に作成するバックアップ ref を、ブランチごとに新しい方から残す数。0 の場合は数で削除しない。
+	BackupKeep        int           `json:"backupKeep"`
+	// BackupMaxAge はこれより古いバックアップ ref を削除する経過時間。空の場合は経過時間で削除しない。
+	BackupMaxAge      string        `json:"backupMaxAge,omitempty"`
 	// TargetBranch      string        `json:"targetBranch"`  // 削除: Dev側のカレントブランチを動的に使用
 	// BaseBranch        string        `json:"baseBranch"`   // 削除: 動的なブランチ追従により不要
 	MaxRetries        int           `json:"maxRetries"`
 	RetryDelay        string        `json:"retryDelay"`
+	// FailureBackoffMax は継続実行で失敗が続いた場合に、次の実行まで空ける間隔の上限。
+	FailureBackoffMax string        `json:"failureBackoffMax"`
+	// CircuitBreakerThreshold は継続実行を止めて確認のみに切り替えるまでの連続失敗回数。0 の場合は切り替えない。
+	CircuitBreakerThreshold int     `json:"circuitBreakerThreshold"`
+	// CircuitProbeInterval は継続実行を止めている間に、失敗の原因が解消したかを確認する間隔。
+	CircuitProbeInterval    string  `json:"circuitProbeInterval"`
 	LogLevel          string        `json:"logLevel"`
 	LogFilePath       string        `json:"logFilePath"`
 	NotifyOnError     *NotifyConfig `json:"notifyOnError,omitempty"`
+	// HTTPAPIAddr は run が状態の参照と sync/fixup/snapshot の実行を受け付ける HTTP API の待ち受けアドレス（例: 127.0.0.1:8787）。
+	// ループバックアドレスのみ指定でき、空の場合は API を起動しない。
+	HTTPAPIAddr       string        `json:"httpApiAddr,omitempty"`
+	// HTTPAPIToken は HTTP API の認証トークン。Authorization: Bearer ヘッダーで送る。
+	HTTPAPIToken      string        `json:"httpApiToken,omitempty"`
+	// MetricsAddr は Prometheus 形式のメトリクスを /metrics で提供する待ち受けアドレス（例: :9464）。空の場合は提供しない。
+	MetricsAddr       string        `json:"metricsAddr,omitempty"`
+	// MetricsTextfilePath はメトリクスを node_exporter の textfile collector 形式で書き出すファイル（*.prom）。
+	// ポートを開けられないホスト向け。空の場合は書き出さない。
+	MetricsTextfilePath     string  `json:"metricsTextfilePath,omitempty"`
+	// MetricsTextfileInterval は MetricsTextfilePath へ書き出す間隔。
+	MetricsTextfileInterval string  `json:"metricsTextfileInterval"`
 	DryRun            bool          `json:"dryRun"`
 	Verbose           bool          `json:"verbose"`
 	VHDXPath          string        `json:"vhdxPath,omitempty"`
@@ -51,14 +124,25 @@ func DefaultConfig() *Config {
 		SyncInterval:      "5m",
 		PauseLockFile:     ".sync-paused",
 		GitExecutable:     "git",
+		GitTimeout:        "10m",
+		IndexLockStaleAfter: "10m",
+		RepoLockWait:        "30s",
+		RepoLockStaleAfter:  "2m",
+		MetricsTextfileInterval: "15s",
 		CommitTemplate:    "Auto-sync: ${timestamp} @ ${hash}",
 		FixupInterval:     "1h",
 		FixupMsgPrefix:    "fixup! ",
+		FixupTarget:       FixupTargetBase,
+		FixupStrategy:     FixupStrategyFixup,
 		AutosquashEnabled: true,
+		BackupKeep:        20,
 		// TargetBranch:      "sync-branch",  // 削除: 動的ブランチ追従
 		// BaseBranch:        "main",        // 削除: 動的ブランチ追従
 		MaxRetries:        3,
 		RetryDelay:        "30s",
+		FailureBackoffMax: "30m",
+		CircuitBreakerThreshold: 5,
+		CircuitProbeInterval:    "5m",
 		LogLevel:          "INFO",
 		LogFilePath:       "./sync.log",
 		DryRun:            false,
@@ -104,6 +188,46 @@ func (c *Config) GetRetryDelayDuration() (time.Duration, error) {
 	return time.ParseDuration(c.RetryDelay)
 }
 
+func (c *Config) GetFailureBackoffMaxDuration() (time.Duration, error) {
+	return time.ParseDuration(c.FailureBackoffMax)
+}
+
+func (c *Config) GetCircuitProbeIntervalDuration() (time.Duration, error) {
+	return time.ParseDuration(c.CircuitProbeInterval)
+}
+
+func (c *Config) GetGitTimeoutDuration() (time.Duration, error) {
+	return time.ParseDuration(c.GitTimeout)
+}
+
+func (c *Config) GetIndexLockStaleAfterDuration() (time.Duration, error) {
+	return time.ParseDuration(c.IndexLockStaleAfter)
+}
+
+func (c *Config) GetRepoLockWaitDuration() (time.Duration, error) {
+	return time.ParseDuration(c.RepoLockWait)
+}
+
+func (c *Config) GetRepoLockStaleAfterDuration() (time.Duration, error) {
+	return time.ParseDuration(c.RepoLockStaleAfter)
+}
+
+func (c *Config) GetBackupMaxAgeDuration() (time.Duration, error) {
+	return time.ParseDuration(c.BackupMaxAge)
+}
+
+func (c *Config) GetMetricsTextfileIntervalDuration() (time.Duration, error) {
+	return time.ParseDuration(c.MetricsTextfileInterval)
+}
+
+// GetFixupUpstream は branch に適用する upstream を返す。未設定の場合は空文字列。
+func (c *Config) GetFixupUpstream(branch string) string {
+	if upstream, ok := c.BranchUpstreams[branch]; ok {
+		return upstream
+	}
+	return c.FixupUpstream
+}
+
 func (c *Config) Validate() error {
 	if c.DevRepoPath == "" {
 		return fmt.Errorf("devRepoPath is required")
@@ -112,15 +236,104 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("opsRepoPath is required")
 	}
 
-	if _, err := c.GetSyncIntervalDuration(); err != nil {
+	if interval, err := c.GetSyncIntervalDuration(); err != nil {
 		return fmt.Errorf("invalid syncInterval: %w", err)
+	} else if interval <= 0 {
+		return fmt.Errorf("invalid syncInterval: must be a positive duration")
 	}
-	if _, err := c.GetFixupIntervalDuration(); err != nil {
+	if interval, err := c.GetFixupIntervalDuration(); err != nil {
 		return fmt.Errorf("invalid fixupInterval: %w", err)
+	} else if interval <= 0 {
+		return fmt.Errorf("invalid fixupInterval: must be a positive duration")
 	}
 	if _, err := c.GetRetryDelayDuration(); err != nil {
 		return fmt.Errorf("invalid retryDelay: %w", err)
 	}
+	if c.FailureBackoffMax != "" {
+		if backoff, err := c.GetFailureBackoffMaxDuration(); err != nil || backoff <= 0 {
+			return fmt.Errorf("invalid failureBackoffMax: must be a positive duration")
+		}
+	}
+	if c.CircuitBreakerThreshold < 0 {
+		return fmt.Errorf("invalid circuitBreakerThreshold: must not be negative")
+	}
+	if c.CircuitProbeInterval != "" {
+		if probe, err := c.GetCircuitProbeIntervalDuration(); err != nil || probe <= 0 {
+			return fmt.Errorf("invalid circuitProbeInterval: must be a positive duration")
+		}
+	}
+	if c.GitTimeout != "" {
+		if _, err := c.GetGitTimeoutDuration(); err != nil {
+			return fmt.Errorf("invalid gitTimeout: %w", err)
+		}
+	}
+	if c.IndexLockStaleAfter != "" {
+		if _, err := c.GetIndexLockStaleAfterDuration(); err != nil {
+			return fmt.Errorf("invalid indexLockStaleAfter: %w", err)
+		}
+	}
+	if c.RepoLockWait != "" {
+		if _, err := c.GetRepoLockWaitDuration(); err != nil {
+			return fmt.Errorf("invalid repoLockWait: %w", err)
+		}
+	}
+	if c.RepoLockStaleAfter != "" {
+		if _, err := c.GetRepoLockStaleAfterDuration(); err != nil {
+			return fmt.Errorf("invalid repoLockStaleAfter: %w", err)
+		}
+	}
+
+	if c.BackupKeep < 0 {
+		return fmt.Errorf("invalid backupKeep: must not be negative")
+	}
+	if c.BackupMaxAge != "" {
+		if age, err := c.GetBackupMaxAgeDuration(); err != nil || age <= 0 {
+			return fmt.Errorf("invalid backupMaxAge: must be a positive duration")
+		}
+	}
+
+	switch c.FixupTarget {
+	case "", FixupTargetBase, FixupTargetAbsorb:
+	default:
+		return fmt.Errorf("invalid fixupTarget: must be one of %s, %s", FixupTargetBase, FixupTargetAbsorb)
+	}
+
+	switch c.FixupStrategy {
+	case "", FixupStrategyFixup, FixupStrategyAmend, FixupStrategySquash, FixupStrategyAmendLast:
+	default:
+		return fmt.Errorf("invalid fixupStrategy: must be one of %s, %s, %s, %s",
+			FixupStrategyFixup, FixupStrategyAmend, FixupStrategySquash, FixupStrategyAmendLast)
+	}
+
+	switch c.CompactionWindow {
+	case "", CompactionWindowHour, CompactionWindowDay:
+	default:
+		return fmt.Errorf("invalid compactionWindow: must be one of %s, %s", CompactionWindowHour, CompactionWindowDay)
+	}
+
+	if c.HTTPAPIAddr != "" {
+		if err := validateLoopbackAddr(c.HTTPAPIAddr); err != nil {
+			return fmt.Errorf("invalid httpApiAddr: %w", err)
+		}
+		if c.HTTPAPIToken == "" {
+			return fmt.Errorf("httpApiToken is required when httpApiAddr is set")
+		}
+	}
+
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			return fmt.Errorf("invalid metricsAddr: %w", err)
+		}
+	}
+	if c.MetricsTextfilePath != "" {
+		// node_exporter の textfile collector は拡張子 .prom のファイルのみを読む。
+		if !strings.HasSuffix(c.MetricsTextfilePath, ".prom") {
+			return fmt.Errorf("invalid metricsTextfilePath: must end with .prom")
+		}
+		if interval, err := c.GetMetricsTextfileIntervalDuration(); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid metricsTextfileInterval: must be a positive duration")
+		}
+	}
 
 	validLogLevels := map[string]bool{
 		"DEBUG": true,
@@ -134,3 +347,18 @@ func (c *Config) Validate() error {
 
 	return nil
 }
+
+// validateLoopbackAddr は addr がループバックアドレスの host:port であることを確認する。
+func validateLoopbackAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("must be a loopback address: %s", addr)
+	}
+	return nil
+}
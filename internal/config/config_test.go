@@ -155,6 +155,178 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid fixup strategy",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "5m",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+				FixupStrategy: "rebase",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid compaction window",
+			cfg: &Config{
+				DevRepoPath:      "/path/to/dev",
+				OpsRepoPath:      "/path/to/ops",
+				SyncInterval:     "5m",
+				FixupInterval:    "1h",
+				RetryDelay:       "30s",
+				LogLevel:         "INFO",
+				CompactionWindow: "week",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid repo lock wait",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "5m",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+				RepoLockWait:  "forever",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid backup max age",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "5m",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+				BackupMaxAge:  "-1h",
+			},
+			wantErr: true,
+		},
+		{
+			name: "http api on non-loopback address",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "5m",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+				HTTPAPIAddr:   "0.0.0.0:8787",
+				HTTPAPIToken:  "secret",
+			},
+			wantErr: true,
+		},
+		{
+			name: "http api without token",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "5m",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+				HTTPAPIAddr:   "127.0.0.1:8787",
+			},
+			wantErr: true,
+		},
+		{
+			name: "http api on localhost",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "5m",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+				HTTPAPIAddr:   "localhost:8787",
+				HTTPAPIToken:  "secret",
+			},
+			wantErr: false,
+		},
+		{
+			name: "metrics textfile without prom extension",
+			cfg: &Config{
+				DevRepoPath:             "/path/to/dev",
+				OpsRepoPath:             "/path/to/ops",
+				SyncInterval:            "5m",
+				FixupInterval:           "1h",
+				RetryDelay:              "30s",
+				LogLevel:                "INFO",
+				MetricsTextfilePath:     "/var/lib/node_exporter/fcsm.txt",
+				MetricsTextfileInterval: "15s",
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics textfile with zero interval",
+			cfg: &Config{
+				DevRepoPath:             "/path/to/dev",
+				OpsRepoPath:             "/path/to/ops",
+				SyncInterval:            "5m",
+				FixupInterval:           "1h",
+				RetryDelay:              "30s",
+				LogLevel:                "INFO",
+				MetricsTextfilePath:     "/var/lib/node_exporter/fcsm.prom",
+				MetricsTextfileInterval: "0s",
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics on all interfaces",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "5m",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+				MetricsAddr:   ":9464",
+			},
+			wantErr: false,
+		},
+		{
+			name: "zero sync interval",
+			cfg: &Config{
+				DevRepoPath:   "/path/to/dev",
+				OpsRepoPath:   "/path/to/ops",
+				SyncInterval:  "0s",
+				FixupInterval: "1h",
+				RetryDelay:    "30s",
+				LogLevel:      "INFO",
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative circuit breaker threshold",
+			cfg: &Config{
+				DevRepoPath:             "/path/to/dev",
+				OpsRepoPath:             "/path/to/ops",
+				SyncInterval:            "5m",
+				FixupInterval:           "1h",
+				RetryDelay:              "30s",
+				LogLevel:                "INFO",
+				CircuitBreakerThreshold: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero circuit probe interval",
+			cfg: &Config{
+				DevRepoPath:          "/path/to/dev",
+				OpsRepoPath:          "/path/to/ops",
+				SyncInterval:         "5m",
+				FixupInterval:        "1h",
+				RetryDelay:           "30s",
+				LogLevel:             "INFO",
+				CircuitProbeInterval: "0s",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid log level",
 			cfg: &Config{
@@ -210,3 +382,24 @@ func TestConfigDurationMethods(t *testing.T) {
 		t.Errorf("Expected 30 seconds, got %v", retryDuration)
 	}
 }
+
+func TestGetFixupUpstream(t *testing.T) {
+	cfg := &Config{
+		FixupUpstream: "origin/main",
+		BranchUpstreams: map[string]string{
+			"release/1.0": "origin/release/1.0",
+			"scratch":     "",
+		},
+	}
+
+	tests := map[string]string{
+		"feature-x":   "origin/main",
+		"release/1.0": "origin/release/1.0",
+		"scratch":     "",
+	}
+	for branch, expected := range tests {
+		if got := cfg.GetFixupUpstream(branch); got != expected {
+			t.Errorf("GetFixupUpstream(%q) = %q, want %q", branch, got, expected)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"time"
+)
+
+// DefaultWatchInterval は設定ファイルの変更を確認する既定の間隔。
+const DefaultWatchInterval = 2 * time.Second
+
+// Watcher は設定ファイルを定期的に確認し、変更された設定を検証して送る。
+// エディタが一時ファイルからの置き換えで保存しても検出できるよう、通知ではなく更新時刻とサイズを確認する。
+type Watcher struct {
+	path     string
+	current  *Config
+	Interval time.Duration
+	// Prepare は読み込んだ設定を検証する前に呼ぶ。コマンドラインフラグによる上書きの適用に使う。
+	Prepare func(*Config)
+	// Check は検証に成功した新しい設定 next を直前の設定 old と比べ、実行中に切り替えられない変更であればエラーを返す。
+	Check func(old, next *Config) error
+	// OnError は変更された設定を読み込めないか検証に失敗した場合、または Check がエラーを返した場合に呼ぶ。
+	// この場合は現在の設定を維持する。
+	OnError func(error)
+	// OnChange は検証に成功した新しい設定 next を送る前に、直前の設定 old とともに呼ぶ。
+	OnChange func(old, next *Config)
+}
+
+// NewWatcher は現在 current が適用されている設定ファイル path の Watcher を作成する。
+func NewWatcher(path string, current *Config) *Watcher {
+	return &Watcher{path: path, current: current, Interval: DefaultWatchInterval}
+}
+
+// Watch は ctx が終了するまで設定ファイルを監視し、内容が変わって検証に成功した設定を返すチャネルへ送る。
+// 受け取る側は処理の合間にチャネルを読み、受け取った設定へまとめて切り替える。
+// 受け取られる前に次の変更があった場合は新しい設定のみを残す。ctx の終了でチャネルを閉じる。
+func (w *Watcher) Watch(ctx context.Context) <-chan *Config {
+	changes := make(chan *Config, 1)
+	// seen は直前の確認で見た状態、loaded は最後に読み込んだ状態。
+	seenTime, seenSize := w.stat()
+	loadedTime, loadedSize := seenTime, seenSize
+
+	go func() {
+		defer close(changes)
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// 書き込み途中の内容を読まないよう、変化が 1 間隔のあいだ止まってから読み込む。
+			m, s := w.stat()
+			if !m.Equal(seenTime) || s != seenSize {
+				seenTime, seenSize = m, s
+				continue
+			}
+			if m.Equal(loadedTime) && s == loadedSize {
+				continue
+			}
+			loadedTime, loadedSize = m, s
+
+			cfg, err := w.load()
+			if err != nil {
+				if w.OnError != nil {
+					w.OnError(err)
+				}
+				continue
+			}
+			if reflect.DeepEqual(cfg, w.current) {
+				continue
+			}
+			if w.Check != nil {
+				if err := w.Check(w.current, cfg); err != nil {
+					if w.OnError != nil {
+						w.OnError(err)
+					}
+					continue
+				}
+			}
+			if w.OnChange != nil {
+				w.OnChange(w.current, cfg)
+			}
+			w.current = cfg
+
+			select {
+			case <-changes:
+			default:
+			}
+			changes <- cfg
+		}
+	}()
+	return changes
+}
+
+func (w *Watcher) stat() (time.Time, int64) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+func (w *Watcher) load() (*Config, error) {
+	cfg, err := LoadConfig(w.path)
+	if err != nil {
+		return nil, err
+	}
+	if w.Prepare != nil {
+		w.Prepare(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.hjson")
+	modified := time.Now()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		// 更新時刻の分解能が粗いファイルシステムでも変更として検出されるようにする。
+		modified = modified.Add(time.Second)
+		os.Chtimes(path, modified, modified)
+	}
+	write(`{ "devRepoPath": "/dev", "opsRepoPath": "/ops", "syncInterval": "5m" }`)
+
+	current, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() failed: %v", err)
+	}
+	current.Verbose = true
+
+	var mu sync.Mutex
+	var errs []error
+	watcher := NewWatcher(path, current)
+	watcher.Interval = 10 * time.Millisecond
+	watcher.Prepare = func(cfg *Config) { cfg.Verbose = true }
+	watcher.Check = func(old, next *Config) error {
+		if old.OpsRepoPath != next.OpsRepoPath {
+			return errors.New("opsRepoPath cannot be changed")
+		}
+		return nil
+	}
+	watcher.OnError = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := watcher.Watch(ctx)
+
+	receive := func() *Config {
+		t.Helper()
+		select {
+		case cfg := <-changes:
+			return cfg
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected a configuration change")
+			return nil
+		}
+	}
+	expectNone := func() {
+		t.Helper()
+		select {
+		case cfg := <-changes:
+			t.Fatalf("Unexpected configuration change: %+v", cfg)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	write(`{ "devRepoPath": "/dev", "opsRepoPath": "/ops", "syncInterval": "1m" }`)
+	cfg := receive()
+	if cfg.SyncInterval != "1m" || !cfg.Verbose {
+		t.Errorf("Unexpected reloaded config: interval=%s verbose=%v", cfg.SyncInterval, cfg.Verbose)
+	}
+
+	// 内容が同じであれば送らない。
+	write("{\n  devRepoPath: /dev\n  opsRepoPath: /ops\n  syncInterval: 1m\n}")
+	expectNone()
+
+	// 検証に失敗する変更は報告して送らない。
+	write(`{ "devRepoPath": "/dev", "opsRepoPath": "/ops", "syncInterval": "0s" }`)
+	expectNone()
+	mu.Lock()
+	if len(errs) != 1 {
+		t.Errorf("Expected one error for the invalid change, got %v", errs)
+	}
+	mu.Unlock()
+
+	// Check が拒否した変更も報告して送らない。
+	write(`{ "devRepoPath": "/dev", "opsRepoPath": "/other", "syncInterval": "1m" }`)
+	expectNone()
+	mu.Lock()
+	if len(errs) != 2 {
+		t.Errorf("Expected an error for the rejected change, got %v", errs)
+	}
+	mu.Unlock()
+
+	write(`{ "devRepoPath": "/dev", "opsRepoPath": "/ops", "syncInterval": "2m" }`)
+	if cfg := receive(); cfg.SyncInterval != "2m" {
+		t.Errorf("Expected interval 2m after fixing the config, got %s", cfg.SyncInterval)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Error("Expected the channel to be closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watcher did not stop")
+	}
+}
@@ -0,0 +1,274 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// SocketFile は制御ソケットの、Ops リポジトリの git ディレクトリからの相対パス。
+const SocketFile = "fcsm/control.sock"
+
+// requestTimeout は接続から要求の 1 行を受け取るまで、および応答を書き込むまでに待つ最大時間。
+// 要求を送らずに接続を保持し続けるクライアントによって、デーモンの終了が妨げられないようにする。
+const requestTimeout = 10 * time.Second
+
+// maxSocketPathLength は Unix ドメインソケットのパスとして使える長さ。sun_path の上限（104〜108 バイト）より余裕を持たせる。
+const maxSocketPathLength = 100
+
+// 制御コマンド。
+const (
+	CommandStatus   = "status"
+	CommandSync     = "sync"
+	CommandFixup    = "fixup"
+	CommandSnapshot = "snapshot"
+	CommandPause    = "pause"
+	CommandResume   = "resume"
+	CommandReload   = "reload"
+	CommandStop     = "stop"
+)
+
+// Commands は受け付ける制御コマンドの一覧。
+var Commands = []string{CommandStatus, CommandSync, CommandFixup, CommandSnapshot, CommandPause, CommandResume, CommandReload, CommandStop}
+
+// ErrNotRunning は制御ソケットで待ち受けているデーモンが無いことを表す。
+var ErrNotRunning = errors.New("run daemon is not running")
+
+// Request はクライアントからデーモンへの要求。1 接続につき 1 行の JSON として送る。
+type Request struct {
+	Command string `json:"command"`
+}
+
+// Response はデーモンからの応答。
+type Response struct {
+	OK      bool    `json:"ok"`
+	Message string  `json:"message,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Status  *Status `json:"status,omitempty"`
+}
+
+// Status は実行中のデーモンの状態。
+type Status struct {
+	PID           int       `json:"pid"`
+	StartedAt     time.Time `json:"startedAt"`
+	ConfigPath    string    `json:"configPath"`
+	DevRepoPath   string    `json:"devRepoPath"`
+	OpsRepoPath   string    `json:"opsRepoPath"`
+	Paused        bool      `json:"paused"`
+	DryRun        bool      `json:"dryRun"`
+	SyncInterval  string    `json:"syncInterval"`
+	FixupInterval string    `json:"fixupInterval"`
+	LastSync      *RunInfo  `json:"lastSync,omitempty"`
+	LastFixup     *RunInfo  `json:"lastFixup,omitempty"`
+	LastSnapshot  *RunInfo  `json:"lastSnapshot,omitempty"`
+	NextSync      time.Time `json:"nextSync"`
+	NextFixup     time.Time `json:"nextFixup"`
+	// SyncFailures と FixupFailures は定期実行の連続失敗回数。
+	SyncFailures  int `json:"syncFailures,omitempty"`
+	FixupFailures int `json:"fixupFailures,omitempty"`
+	// SyncCircuitOpen と FixupCircuitOpen は失敗が続いたため定期実行を止め、原因の解消を確認しているかを表す。
+	SyncCircuitOpen  bool `json:"syncCircuitOpen,omitempty"`
+	FixupCircuitOpen bool `json:"fixupCircuitOpen,omitempty"`
+}
+
+// RunInfo は sync / fixup / snapshot の 1 回の実行結果。
+type RunInfo struct {
+	// Operation は実行した処理（sync / fixup / snapshot）。
+	Operation string        `json:"operation"`
+	Time      time.Time     `json:"time"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Handler は要求を処理して応答を返す。接続ごとに別の goroutine から呼ばれる。
+type Handler func(req Request) Response
+
+// SocketPath は Ops リポジトリの制御ソケットのパスを返す。
+// git ディレクトリ配下のパスが Unix ドメインソケットの上限を超える場合は、一時ディレクトリ配下の固定名を使う。
+func SocketPath(ctx context.Context, runner git.Runner) (string, error) {
+	path, err := git.Output(ctx, runner, "rev-parse", "--git-path", SocketFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve control socket path: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(runner.Dir(), path)
+	}
+	if len(path) <= maxSocketPathLength {
+		return path, nil
+	}
+
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(os.TempDir(), "fcsm-"+hex.EncodeToString(sum[:8])+".sock"), nil
+}
+
+// Server は制御ソケットで要求を待ち受ける。
+// Windows 10 (1803) 以降は AF_UNIX をサポートするため、すべての環境で Unix ドメインソケットを使う。
+type Server struct {
+	path     string
+	listener net.Listener
+	handler  Handler
+	wg       sync.WaitGroup
+
+	mu sync.Mutex
+	// idle は要求の受信を待っている接続。Close で閉じる。
+	idle   map[net.Conn]struct{}
+	closed bool
+}
+
+// Listen は path で待ち受けを開始する。応答の無いソケットファイルは前回の異常終了の残骸とみなして削除する。
+func Listen(path string, handler Handler) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create control socket directory: %w", err)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("control socket is already in use: %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	// 同じユーザー以外からの操作を受け付けない。
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to restrict control socket permissions: %w", err)
+	}
+
+	s := &Server{path: path, listener: listener, handler: handler, idle: make(map[net.Conn]struct{})}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Path は待ち受けているソケットのパスを返す。
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Close は待ち受けを終了し、要求を待っている接続を閉じてから、処理中の要求の応答を待つ。
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.idle {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		if !s.track(conn) {
+			conn.Close()
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// track は要求を待っている接続として conn を登録する。既に Close が呼ばれている場合は false を返す。
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.idle[conn] = struct{}{}
+	return true
+}
+
+// untrack は要求を受け取った conn を Close で閉じる対象から外す。
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.idle, conn)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var resp Response
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	s.untrack(conn)
+	var req Request
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		resp = Response{Error: fmt.Sprintf("malformed request: %v", err)}
+	} else {
+		resp = s.handler(req)
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	// sync などの処理に時間がかかっても応答できるよう、書き込みの期限はハンドラーの終了後に設定する。
+	conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	conn.Write(append(data, '\n'))
+}
+
+// Send は path のデーモンへ要求を送り、応答を待つ。待ち受けているデーモンが無い場合は ErrNotRunning を返す。
+func Send(ctx context.Context, path string, req Request) (*Response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s): %v", ErrNotRunning, path, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to send control request: %w", err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control response: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("malformed control response: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,137 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func TestServeAndSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	server, err := Listen(path, func(req Request) Response {
+		if req.Command == CommandStatus {
+			return Response{OK: true, Status: &Status{PID: 42, Paused: true}}
+		}
+		return Response{Error: "unknown command: " + req.Command}
+	})
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := Send(ctx, path, Request{Command: CommandStatus})
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if !resp.OK || resp.Status == nil || resp.Status.PID != 42 || !resp.Status.Paused {
+		t.Errorf("Unexpected status response: %+v", resp)
+	}
+
+	resp, err = Send(ctx, path, Request{Command: "bogus"})
+	if err != nil {
+		t.Fatalf("Send() failed: %v", err)
+	}
+	if resp.OK || !strings.Contains(resp.Error, "bogus") {
+		t.Errorf("Expected error response, got %+v", resp)
+	}
+}
+
+func TestListenSocketFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fcsm", "control.sock")
+	handler := func(Request) Response { return Response{OK: true} }
+
+	// 異常終了したデーモンが残したソケットファイルは置き換える。
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, nil, 0600)
+
+	server, err := Listen(path, handler)
+	if err != nil {
+		t.Fatalf("Listen() should replace a stale socket file: %v", err)
+	}
+
+	// 応答するデーモンがいる場合は二重に起動しない。
+	if _, err := Listen(path, handler); err == nil {
+		t.Error("Listen() should fail while another daemon is listening")
+	}
+
+	server.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Socket file should be removed on close")
+	}
+}
+
+func TestCloseWithIdleConnection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	server, err := Listen(path, func(Request) Response { return Response{OK: true} })
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+
+	// 要求を送らないクライアントがいても Close は待ち続けない。
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		server.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(requestTimeout / 2):
+		t.Fatal("Close() should not wait for an idle connection")
+	}
+}
+
+func TestSendWithoutDaemon(t *testing.T) {
+	_, err := Send(context.Background(), filepath.Join(t.TempDir(), "control.sock"), Request{Command: CommandStatus})
+	if !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestSocketPath(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := t.TempDir()
+	longRepo := filepath.Join(repo, strings.Repeat("a", maxSocketPathLength))
+	for _, dir := range []string{repo, longRepo} {
+		testutil.InitRepository(t, dir)
+	}
+
+	path, err := SocketPath(context.Background(), git.NewRunner("git", repo))
+	if err != nil {
+		t.Fatalf("SocketPath() failed: %v", err)
+	}
+	if path != filepath.Join(repo, ".git", SocketFile) {
+		t.Errorf("Unexpected socket path: %s", path)
+	}
+
+	// 長すぎるパスは一時ディレクトリ配下の固定名に置き換え、同じリポジトリからは同じパスになる。
+	first, err := SocketPath(context.Background(), git.NewRunner("git", longRepo))
+	if err != nil {
+		t.Fatalf("SocketPath() failed: %v", err)
+	}
+	second, _ := SocketPath(context.Background(), git.NewRunner("git", longRepo))
+	if first != second || len(first) > maxSocketPathLength || !strings.HasPrefix(filepath.Base(first), "fcsm-") {
+		t.Errorf("Unexpected fallback socket path: %s, %s", first, second)
+	}
+}
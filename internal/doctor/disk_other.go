@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package doctor
+
+import "syscall"
+
+// freeSpace は path を含むファイルシステムで、一般ユーザーが使用できる空き容量を返す。
+func freeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
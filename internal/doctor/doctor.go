@@ -0,0 +1,370 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/repolock"
+)
+
+// Status は検査の結果。
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+)
+
+// MinGitVersion は必要な git のバージョン。branch --show-current は 2.22 で追加された。
+var MinGitVersion = [2]int{2, 22}
+
+// 空き容量の閾値。これを下回ると警告、または失敗とする。
+const (
+	LowDiskSpace      = 1 << 30   // 1 GiB
+	CriticalDiskSpace = 100 << 20 // 100 MiB
+)
+
+// Check は 1 項目の検査結果。
+type Check struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	// Fix は警告・失敗の場合の対処方法。
+	Fix string `json:"fix,omitempty"`
+}
+
+// Report はすべての検査結果。
+type Report struct {
+	Checks   []Check `json:"checks"`
+	Passed   int     `json:"passed"`
+	Warnings int     `json:"warnings"`
+	Failed   int     `json:"failed"`
+}
+
+func (r *Report) add(check Check) {
+	r.Checks = append(r.Checks, check)
+	switch check.Status {
+	case StatusPass:
+		r.Passed++
+	case StatusWarn:
+		r.Warnings++
+	case StatusFail:
+		r.Failed++
+	}
+}
+
+func pass(name, format string, args ...interface{}) Check {
+	return Check{Name: name, Status: StatusPass, Message: fmt.Sprintf(format, args...)}
+}
+
+func warn(name, fix, format string, args ...interface{}) Check {
+	return Check{Name: name, Status: StatusWarn, Message: fmt.Sprintf(format, args...), Fix: fix}
+}
+
+func fail(name, fix, format string, args ...interface{}) Check {
+	return Check{Name: name, Status: StatusFail, Message: fmt.Sprintf(format, args...), Fix: fix}
+}
+
+// Doctor は実行環境と設定を検査する。検査ではリポジトリやファイルを変更しない。
+type Doctor struct {
+	configPath string
+	// freeSpace はパスを含むファイルシステムの空き容量を返す。テストで差し替える。
+	freeSpace func(path string) (uint64, error)
+}
+
+func New(configPath string) *Doctor {
+	return &Doctor{configPath: configPath, freeSpace: freeSpace}
+}
+
+// Run はすべての検査を行う。設定ファイルを読み込めない場合は、git 以外の検査を行わない。
+func (d *Doctor) Run(ctx context.Context) *Report {
+	report := &Report{}
+
+	cfg, err := config.LoadConfig(d.configPath)
+	if err != nil {
+		report.add(checkGit(ctx, config.DefaultConfig().GitExecutable))
+		report.add(fail("config", "Create the file with init-config or pass --config", "%v", err))
+		return report
+	}
+
+	report.add(checkGit(ctx, cfg.GitExecutable))
+	if err := cfg.Validate(); err != nil {
+		report.add(fail("config", "Fix the setting in "+d.configPath+" and run validate-config", "%v", err))
+	} else {
+		report.add(pass("config", "%s is valid", d.configPath))
+	}
+
+	devTop, devCheck := checkRepository(ctx, cfg, "dev-repo", cfg.DevRepoPath)
+	report.add(devCheck)
+	opsTop, opsCheck := checkRepository(ctx, cfg, "ops-repo", cfg.OpsRepoPath)
+	report.add(opsCheck)
+	if devTop != "" && opsTop != "" {
+		report.add(checkLayout(devTop, opsTop))
+	}
+	if opsTop != "" {
+		ops := git.NewConfiguredRunner(cfg, opsTop)
+		report.add(checkOpsState(ctx, ops))
+		report.add(checkRepoLock(ctx, cfg, ops))
+		report.add(checkIndexLock(ctx, ops))
+	}
+
+	report.add(checkPause(cfg))
+	report.add(checkVHDX(cfg))
+	report.add(d.checkDiskSpace(cfg))
+	report.add(checkLogPath(cfg))
+	return report
+}
+
+var gitVersionPattern = regexp.MustCompile(`git version (\d+)\.(\d+)`)
+
+func checkGit(ctx context.Context, executable string) Check {
+	output, err := git.Output(ctx, git.NewRunner(executable, ""), "version")
+	if err != nil {
+		return fail("git", "Install git 2.22 or later, or set gitExecutable", "failed to run %s: %v", executable, err)
+	}
+
+	m := gitVersionPattern.FindStringSubmatch(output)
+	if m == nil {
+		return warn("git", "Check that gitExecutable points to git", "cannot parse version: %s", output)
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	if major < MinGitVersion[0] || (major == MinGitVersion[0] && minor < MinGitVersion[1]) {
+		return fail("git", fmt.Sprintf("Upgrade git to %d.%d or later", MinGitVersion[0], MinGitVersion[1]),
+			"%s is too old (branch --show-current requires %d.%d)", output, MinGitVersion[0], MinGitVersion[1])
+	}
+	return pass("git", "%s", output)
+}
+
+// checkRepository は path が git リポジトリの最上位であることを確認し、その絶対パスを返す。
+func checkRepository(ctx context.Context, cfg *config.Config, name, path string) (string, Check) {
+	if path == "" {
+		return "", fail(name, "Set the repository path in the config", "path is not configured")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fail(name, "Create or clone the repository, or mount the VHDX", "%v", err)
+	}
+
+	top, err := git.Output(ctx, git.NewConfiguredRunner(cfg, path), "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", fail(name, "Run git init or clone the repository", "%s is not a git repository: %v", path, err)
+	}
+	top = filepath.Clean(filepath.FromSlash(top))
+
+	abs, _ := filepath.Abs(path)
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	if resolvedTop, err := filepath.EvalSymlinks(top); err == nil && !sameFile(resolvedTop, abs) {
+		return top, warn(name, "Point the path at the repository root", "%s is inside the repository %s", path, top)
+	}
+	return top, pass(name, "%s", top)
+}
+
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
+
+// checkLayout は Dev と Ops が同じリポジトリでなく、一方が他方の中に無いことを確認する。
+func checkLayout(devTop, opsTop string) Check {
+	const fix = "Place opsRepoPath and devRepoPath in separate directories"
+	switch {
+	case sameFile(devTop, opsTop):
+		return fail("repo-layout", fix, "dev and ops are the same repository: %s", devTop)
+	case within(devTop, opsTop):
+		return fail("repo-layout", fix, "ops repository %s is nested inside dev repository %s", opsTop, devTop)
+	case within(opsTop, devTop):
+		return fail("repo-layout", fix, "dev repository %s is nested inside ops repository %s", devTop, opsTop)
+	}
+	return pass("repo-layout", "dev and ops are separate repositories")
+}
+
+// within は path が dir の中にあるかを返す。
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// checkOpsState は Ops で rebase / merge / cherry-pick が途中で止まっていないか、競合が残っていないかを確認する。
+func checkOpsState(ctx context.Context, ops git.Runner) Check {
+	inProgress := []struct {
+		path      string
+		operation string
+		fix       string
+	}{
+		{"rebase-merge", "rebase", "Run git rebase --abort in the ops repository, or fixup undo"},
+		{"rebase-apply", "rebase", "Run git rebase --abort in the ops repository, or fixup undo"},
+		{"MERGE_HEAD", "merge", "Run git merge --abort in the ops repository"},
+		{"CHERRY_PICK_HEAD", "cherry-pick", "Run git cherry-pick --abort in the ops repository"},
+	}
+	for _, state := range inProgress {
+		path, err := git.Output(ctx, ops, "rev-parse", "--git-path", state.path)
+		if err != nil {
+			return fail("ops-state", "Check the ops repository with git status", "%v", err)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ops.Dir(), path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return fail("ops-state", state.fix, "a %s is in progress", state.operation)
+		}
+	}
+
+	conflicts, err := git.Lines(ctx, ops, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return fail("ops-state", "Check the ops repository with git status", "%v", err)
+	}
+	if len(conflicts) > 0 {
+		return fail("ops-state", "Resolve or reset the conflicted files in the ops repository",
+			"%d conflicted files: %s", len(conflicts), strings.Join(conflicts, ", "))
+	}
+	return pass("ops-state", "no rebase, merge or conflict in progress")
+}
+
+func checkRepoLock(ctx context.Context, cfg *config.Config, ops git.Runner) Check {
+	locker := repolock.NewLocker(cfg, ops)
+	holder, stale, err := locker.Holder(ctx)
+	if err != nil {
+		return warn("repo-lock", "Check the lock file under .git/fcsm", "cannot read the repository lock: %v", err)
+	}
+	if holder == nil {
+		return pass("repo-lock", "not locked")
+	}
+	if stale {
+		path, _ := locker.Path(ctx)
+		return warn("repo-lock", "The next sync or fixup takes it over; remove "+path+" if it persists",
+			"stale lock left by pid %d on %s (%s, heartbeat %v ago)",
+			holder.PID, holder.Host, holder.Operation, time.Since(holder.Heartbeat).Round(time.Second))
+	}
+	return pass("repo-lock", "held by pid %d on %s (%s)", holder.PID, holder.Host, holder.Operation)
+}
+
+func checkIndexLock(ctx context.Context, ops git.Runner) Check {
+	path, err := git.Output(ctx, ops, "rev-parse", "--git-path", "index.lock")
+	if err != nil {
+		return warn("index-lock", "Check the ops repository with git status", "%v", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ops.Dir(), path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return pass("index-lock", "no index.lock")
+	}
+	return warn("index-lock", "Remove "+path+" if no git process is running (sync removes it after indexLockStaleAfter)",
+		"index.lock exists (age %v)", time.Since(info.ModTime()).Round(time.Second))
+}
+
+func checkPause(cfg *config.Config) Check {
+	if cfg.PauseLockFile == "" || cfg.DevRepoPath == "" {
+		return pass("pause", "no pause file configured")
+	}
+	path := filepath.Join(cfg.DevRepoPath, cfg.PauseLockFile)
+	if _, err := os.Stat(path); err == nil {
+		return warn("pause", "Remove "+path+" to resume sync", "sync is paused by %s", path)
+	}
+	return pass("pause", "sync is not paused")
+}
+
+func checkVHDX(cfg *config.Config) Check {
+	if cfg.VHDXPath == "" {
+		return pass("vhdx", "VHDX is not configured")
+	}
+	if _, err := os.Stat(cfg.VHDXPath); err != nil {
+		return fail("vhdx", "Run init-vhdx", "VHDX file not found: %s", cfg.VHDXPath)
+	}
+	if cfg.MountPoint == "" {
+		return fail("vhdx", "Set mountPoint in the config", "mountPoint is not configured")
+	}
+	if _, err := os.Stat(cfg.MountPoint); err != nil {
+		return fail("vhdx", "Run mount-vhdx", "mount point %s is not available", cfg.MountPoint)
+	}
+	return pass("vhdx", "mounted at %s", cfg.MountPoint)
+}
+
+// checkDiskSpace は Ops リポジトリ（無ければ存在する親ディレクトリ）のファイルシステムの空き容量を確認する。
+func (d *Doctor) checkDiskSpace(cfg *config.Config) Check {
+	path := existingParent(cfg.OpsRepoPath)
+	if path == "" {
+		return warn("disk", "Check opsRepoPath", "cannot determine the ops filesystem")
+	}
+
+	free, err := d.freeSpace(path)
+	if err != nil {
+		return warn("disk", "Check the ops filesystem", "cannot get free space of %s: %v", path, err)
+	}
+	switch {
+	case free < CriticalDiskSpace:
+		return fail("disk", "Free up space or enlarge the VHDX", "only %s free on %s", formatBytes(free), path)
+	case free < LowDiskSpace:
+		return warn("disk", "Free up space or enlarge the VHDX", "only %s free on %s", formatBytes(free), path)
+	}
+	return pass("disk", "%s free on %s", formatBytes(free), path)
+}
+
+func existingParent(path string) string {
+	if path == "" {
+		return ""
+	}
+	path, _ = filepath.Abs(path)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return ""
+		}
+		path = parent
+	}
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// checkLogPath はログファイルに書き込めることを確認する。ログファイルの内容は変更しない。
+func checkLogPath(cfg *config.Config) Check {
+	if cfg.LogFilePath == "" {
+		return pass("log", "file logging is disabled")
+	}
+	const fix = "Create the directory or change logFilePath"
+
+	if _, err := os.Stat(cfg.LogFilePath); err == nil {
+		file, err := os.OpenFile(cfg.LogFilePath, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fail("log", fix, "cannot write %s: %v", cfg.LogFilePath, err)
+		}
+		file.Close()
+		return pass("log", "%s is writable", cfg.LogFilePath)
+	}
+
+	dir := filepath.Dir(cfg.LogFilePath)
+	probe, err := os.CreateTemp(dir, ".fcsm-doctor-*")
+	if err != nil {
+		return fail("log", fix, "cannot create %s: %v", cfg.LogFilePath, err)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return pass("log", "%s can be created", cfg.LogFilePath)
+}
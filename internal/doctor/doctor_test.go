@@ -0,0 +1,126 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+// newTestDoctor は dev と ops の設定ファイルを書き、空き容量 free を返す Doctor を作成する。
+func newTestDoctor(t *testing.T, dir, dev, ops string, free uint64) *Doctor {
+	t.Helper()
+	configPath := filepath.Join(dir, "config.hjson")
+	content := fmt.Sprintf(`{ "devRepoPath": %q, "opsRepoPath": %q, "logFilePath": %q }`,
+		dev, ops, filepath.Join(dir, "logs", "fcsm.log"))
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	os.MkdirAll(filepath.Join(dir, "logs"), 0755)
+
+	d := New(configPath)
+	d.freeSpace = func(string) (uint64, error) { return free, nil }
+	return d
+}
+
+func findCheck(t *testing.T, report *Report, name string) Check {
+	t.Helper()
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("Check %s not found in %+v", name, report.Checks)
+	return Check{}
+}
+
+func TestRunHealthy(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	dir := t.TempDir()
+	dev, ops := filepath.Join(dir, "dev"), filepath.Join(dir, "ops")
+	testutil.InitRepository(t, dev)
+	testutil.InitRepository(t, ops)
+
+	report := newTestDoctor(t, dir, dev, ops, 10<<30).Run(context.Background())
+	if report.Failed != 0 || report.Warnings != 0 {
+		t.Fatalf("Expected all checks to pass, got %+v", report.Checks)
+	}
+	if report.Passed != len(report.Checks) {
+		t.Errorf("Passed = %d, want %d", report.Passed, len(report.Checks))
+	}
+	for _, name := range []string{"git", "config", "dev-repo", "ops-repo", "repo-layout", "ops-state", "repo-lock", "index-lock", "pause", "vhdx", "disk", "log"} {
+		findCheck(t, report, name)
+	}
+}
+
+func TestRunDetectsProblems(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	dir := t.TempDir()
+	dev := filepath.Join(dir, "dev")
+	ops := filepath.Join(dev, "ops")
+	testutil.InitRepository(t, dev)
+	testutil.InitRepository(t, ops)
+
+	// 途中で止まった rebase と一時停止ファイルを用意する。
+	os.MkdirAll(filepath.Join(ops, ".git", "rebase-merge"), 0755)
+	os.WriteFile(filepath.Join(dev, ".sync-paused"), nil, 0644)
+
+	report := newTestDoctor(t, dir, dev, ops, 500<<20).Run(context.Background())
+
+	tests := []struct {
+		name   string
+		status Status
+	}{
+		{"repo-layout", StatusFail},
+		{"ops-state", StatusFail},
+		{"pause", StatusWarn},
+		{"disk", StatusWarn},
+		{"git", StatusPass},
+	}
+	for _, tt := range tests {
+		check := findCheck(t, report, tt.name)
+		if check.Status != tt.status {
+			t.Errorf("%s: status = %s, want %s (%s)", tt.name, check.Status, tt.status, check.Message)
+		}
+		if check.Status != StatusPass && check.Fix == "" {
+			t.Errorf("%s: expected a suggested fix", tt.name)
+		}
+	}
+}
+
+func TestRunWithoutConfig(t *testing.T) {
+	report := New(filepath.Join(t.TempDir(), "missing.hjson")).Run(context.Background())
+	if check := findCheck(t, report, "config"); check.Status != StatusFail {
+		t.Errorf("Missing config should fail, got %+v", check)
+	}
+	if len(report.Checks) != 2 {
+		t.Errorf("Only git and config should be checked without a config, got %+v", report.Checks)
+	}
+}
+
+func TestWithin(t *testing.T) {
+	base := filepath.Join("/", "work")
+	tests := []struct {
+		dir, path string
+		want      bool
+	}{
+		{base, filepath.Join(base, "ops"), true},
+		{base, base, false},
+		{base, filepath.Join("/", "workspace"), false},
+		{filepath.Join(base, "ops"), base, false},
+	}
+	for _, tt := range tests {
+		if got := within(tt.dir, tt.path); got != tt.want {
+			t.Errorf("within(%s, %s) = %v, want %v", tt.dir, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package fixup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// FixupCommit は作成した fixup コミットとその対象を表す。
+type FixupCommit struct {
+	Target string
+	Commit string
+	Files  []string
+}
+
+// hunk は -U0 差分の 1 ハンク。oldLines が 0 の場合は oldStart 行目の直後への挿入を表す。
+type hunk struct {
+	oldStart int
+	oldLines int
+	// newLines は置き換え後の行。各行は改行を含む（ファイル末尾で改行が無い場合を除く）。
+	newLines []string
+	target   string
+}
+
+// fileChange はステージ済みの 1 ファイル分の変更。
+type fileChange struct {
+	path    string
+	status  byte
+	oldMode string
+	newMode string
+	oldBlob string
+	newBlob string
+	// hunks が nil の場合はファイル全体を一つの単位として wholeTarget に割り当てる。
+	hunks       []hunk
+	baseLines   []string
+	wholeTarget string
+}
+
+// absorb はステージ済みの変更をハンク単位で、その行を最後に変更したコミットに割り当て、
+// 対象コミットごとに fixup! コミットを作成する。作成したコミットを古い対象から順に返す。
+func (f *FixupManager) absorb(ctx context.Context, paths []string, rng *fixupRange) ([]FixupCommit, error) {
+	changes, targets, err := f.absorbTargets(ctx, paths, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := git.Output(ctx, f.ops, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+
+	indexPath, err := git.Output(ctx, f.ops, "rev-parse", "--git-path", "fcsm-absorb.index")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve temporary index path: %w", err)
+	}
+	if !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(f.ops.Dir(), indexPath)
+	}
+	defer os.Remove(indexPath)
+
+	applied := make(map[string][]bool)
+	for _, change := range changes {
+		applied[change.path] = make([]bool, len(change.hunks))
+	}
+
+	tip := head
+	var commits []FixupCommit
+	for _, target := range targets {
+		var touched []*fileChange
+		for _, change := range changes {
+			hit := change.hunks == nil && change.wholeTarget == target
+			for i, h := range change.hunks {
+				if h.target == target {
+					applied[change.path][i] = true
+					hit = true
+				}
+			}
+			if hit {
+				touched = append(touched, change)
+			}
+		}
+
+		commit, err := f.commitAbsorbed(ctx, indexPath, tip, target, touched, applied)
+		if err != nil {
+			return nil, err
+		}
+
+		files := make([]string, 0, len(touched))
+		for _, change := range touched {
+			files = append(files, change.path)
+		}
+		commits = append(commits, FixupCommit{Target: target, Commit: commit, Files: files})
+		tip = commit
+	}
+
+	if _, err := f.ops.Run(ctx, "update-ref", "-m", "fixup: absorb", "HEAD", tip, head); err != nil {
+		return nil, fmt.Errorf("failed to update HEAD: %w", err)
+	}
+
+	return commits, nil
+}
+
+// absorbTargets はステージされた変更をハンクごとに対象コミットへ割り当て、変更と対象コミット（古い順）を返す。
+func (f *FixupManager) absorbTargets(ctx context.Context, paths []string, rng *fixupRange) ([]*fileChange, []string, error) {
+	candidates, err := f.absorbCandidates(ctx, rng)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fallback, err := f.getBaseCommit(ctx, rng)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get base commit: %w", err)
+	}
+
+	changes, err := f.stagedChanges(ctx, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, change := range changes {
+		if err := f.assignTargets(ctx, change, candidates, fallback); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// fixup コミットは対象コミットの古い順に積むため、その順に並べる。
+	targetSet := make(map[string]bool)
+	for _, change := range changes {
+		if change.hunks == nil {
+			targetSet[change.wholeTarget] = true
+		}
+		for _, h := range change.hunks {
+			targetSet[h.target] = true
+		}
+	}
+	targets := make([]string, 0, len(targetSet))
+	for target := range targetSet {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return commitIndex(candidates, targets[i]) > commitIndex(candidates, targets[j])
+	})
+
+	return changes, targets, nil
+}
+
+// absorbCandidates は割り当て先となり得る範囲内のコミットを新しい順に返す。
+func (f *FixupManager) absorbCandidates(ctx context.Context, rng *fixupRange) ([]string, error) {
+	commits, err := git.Lines(ctx, f.ops, "rev-list", rng.revision())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list candidate commits: %w", err)
+	}
+	return commits, nil
+}
+
+// stagedChanges は HEAD とインデックスの差分をファイル単位で取得する。
+func (f *FixupManager) stagedChanges(ctx context.Context, paths []string) ([]*fileChange, error) {
+	// git diff は --pathspec-from-file に対応しないため、全体の差分から対象パスを抽出する。
+	output, err := f.ops.Run(ctx, "diff", "--cached", "--raw", "-z", "--no-abbrev", "--no-renames", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged changes: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		wanted[path] = true
+	}
+
+	records := git.SplitNUL(output)
+	var changes []*fileChange
+	for i := 0; i+1 < len(records); i += 2 {
+		// :<旧モード> <新モード> <旧 blob> <新 blob> <状態>
+		fields := strings.Fields(strings.TrimPrefix(records[i], ":"))
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("malformed raw diff entry: %q", records[i])
+		}
+		if !wanted[records[i+1]] {
+			continue
+		}
+		change := &fileChange{
+			path:    records[i+1],
+			status:  fields[4][0],
+			oldMode: fields[0],
+			newMode: fields[1],
+			oldBlob: fields[2],
+			newBlob: fields[3],
+		}
+		if change.status == 'M' && change.oldMode == change.newMode {
+			if err := f.loadHunks(ctx, change); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// loadHunks は変更をハンクに分解する。バイナリや再構成できない変更はファイル全体として扱う。
+func (f *FixupManager) loadHunks(ctx context.Context, change *fileChange) error {
+	base, err := f.ops.Run(ctx, "cat-file", "blob", change.oldBlob)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", change.path, err)
+	}
+
+	patch, err := f.ops.Run(ctx, "--literal-pathspecs", "diff", "--cached", "-U0", "--no-color",
+		"--no-ext-diff", "--no-textconv", "HEAD", "--", change.path)
+	if err != nil {
+		return fmt.Errorf("failed to diff %s: %w", change.path, err)
+	}
+
+	hunks, ok := parseZeroContextHunks(patch)
+	if !ok || len(hunks) == 0 {
+		return nil
+	}
+
+	baseLines := splitLinesKeepEOL(base)
+	all := make([]bool, len(hunks))
+	for i := range all {
+		all[i] = true
+	}
+
+	// 全ハンクを適用した結果がステージ済みの内容と一致しなければハンク単位の分割は行わない。
+	rebuilt := applyHunks(baseLines, hunks, all)
+	blob, err := f.ops.RunWithOptions(ctx, git.RunOptions{Stdin: bytes.NewReader(rebuilt)},
+		"hash-object", "--no-filters", "--stdin")
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", change.path, err)
+	}
+	if strings.TrimSpace(string(blob)) != change.newBlob {
+		return nil
+	}
+
+	change.hunks = hunks
+	change.baseLines = baseLines
+	return nil
+}
+
+// assignTargets は各ハンクに対象コミットを割り当てる。
+// 該当行を変更したコミットのうち最も新しいものを選び、候補外であれば fallback を使う。
+func (f *FixupManager) assignTargets(ctx context.Context, change *fileChange, candidates []string, fallback string) error {
+	if change.hunks == nil {
+		change.wholeTarget = fallback
+		if change.status == 'D' {
+			target, err := f.blameNewest(ctx, change.path, 0, 0, candidates)
+			if err != nil {
+				return err
+			}
+			if target != "" {
+				change.wholeTarget = target
+			}
+		}
+		return nil
+	}
+
+	for i := range change.hunks {
+		h := &change.hunks[i]
+		start, end := h.oldStart, h.oldStart+h.oldLines-1
+		if h.oldLines == 0 {
+			// 挿入は前後の行を変更したコミットに割り当てる。
+			start, end = h.oldStart, h.oldStart+1
+			if start < 1 {
+				start = 1
+			}
+			if end > len(change.baseLines) {
+				end = len(change.baseLines)
+			}
+		}
+
+		h.target = fallback
+		if start > end {
+			continue
+		}
+		target, err := f.blameNewest(ctx, change.path, start, end, candidates)
+		if err != nil {
+			return err
+		}
+		if target != "" {
+			h.target = target
+		}
+	}
+
+	return nil
+}
+
+// blameNewest は指定行を最後に変更したコミットのうち、候補の中で最も新しいものを返す。
+// start が 0 の場合はファイル全体を対象とする。該当が無い場合は空文字列を返す。
+func (f *FixupManager) blameNewest(ctx context.Context, path string, start, end int, candidates []string) (string, error) {
+	args := []string{"--literal-pathspecs", "blame", "--porcelain"}
+	if start > 0 {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", start, end))
+	}
+	args = append(args, "HEAD", "--", path)
+
+	output, err := f.ops.Run(ctx, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to blame %s: %w", path, err)
+	}
+
+	newest := ""
+	newestIndex := len(candidates)
+	for _, commit := range parseBlameCommits(output) {
+		if idx := commitIndex(candidates, commit); idx < newestIndex {
+			newest, newestIndex = commit, idx
+		}
+	}
+	return newest, nil
+}
+
+// commitAbsorbed は一時インデックス上で tip に対象ハンクを適用したツリーを作り、fixup! コミットを作成する。
+func (f *FixupManager) commitAbsorbed(ctx context.Context, indexPath, tip, target string, touched []*fileChange, applied map[string][]bool) (string, error) {
+	indexEnv := git.RunOptions{Env: []string{"GIT_INDEX_FILE=" + indexPath}}
+
+	if _, err := f.ops.RunWithOptions(ctx, indexEnv, "read-tree", tip); err != nil {
+		return "", fmt.Errorf("failed to prepare temporary index: %w", err)
+	}
+
+	var info bytes.Buffer
+	for _, change := range touched {
+		mode, blob := change.newMode, change.newBlob
+		if change.hunks != nil {
+			content := applyHunks(change.baseLines, change.hunks, applied[change.path])
+			hash, err := f.ops.RunWithOptions(ctx, git.RunOptions{Stdin: bytes.NewReader(content)},
+				"hash-object", "-w", "--no-filters", "--stdin")
+			if err != nil {
+				return "", fmt.Errorf("failed to write blob for %s: %w", change.path, err)
+			}
+			blob = strings.TrimSpace(string(hash))
+		}
+		if change.status == 'D' {
+			// モード 0 のエントリはインデックスからの削除を表す。
+			mode, blob = "0", strings.Repeat("0", len(change.oldBlob))
+		}
+		fmt.Fprintf(&info, "%s %s\t%s\x00", mode, blob, change.path)
+	}
+
+	indexEnv.Stdin = &info
+	if _, err := f.ops.RunWithOptions(ctx, indexEnv, "update-index", "-z", "--index-info"); err != nil {
+		return "", fmt.Errorf("failed to update temporary index: %w", err)
+	}
+	indexEnv.Stdin = nil
+
+	tree, err := f.ops.RunWithOptions(ctx, indexEnv, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to write tree: %w", err)
+	}
+
+	// git commit --fixup と同じ形式のメッセージにして autosquash で認識させる。
+	message, err := f.fixupMessage(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	opts := git.RunOptions{Stdin: strings.NewReader(message)}
+	if f.cfg.AuthorName != "" && f.cfg.AuthorEmail != "" {
+		opts.Env = []string{"GIT_AUTHOR_NAME=" + f.cfg.AuthorName, "GIT_AUTHOR_EMAIL=" + f.cfg.AuthorEmail}
+	}
+	commit, err := f.ops.RunWithOptions(ctx, opts, "commit-tree", strings.TrimSpace(string(tree)), "-p", tip, "-F", "-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create fixup commit for %s: %w", target, err)
+	}
+
+	return strings.TrimSpace(string(commit)), nil
+}
+
+// parseZeroContextHunks は git diff -U0 の出力からハンクを抽出する。
+// バイナリ差分の場合は ok に false を返す。
+func parseZeroContextHunks(patch []byte) ([]hunk, bool) {
+	var hunks []hunk
+	var last byte
+	for _, line := range strings.Split(string(patch), "\n") {
+		switch {
+		case strings.HasPrefix(line, "Binary files ") || strings.HasPrefix(line, "GIT binary patch"):
+			return nil, false
+		case strings.HasPrefix(line, "@@ "):
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				return nil, false
+			}
+			hunks = append(hunks, h)
+			last = '@'
+		case len(hunks) == 0:
+			// ファイルヘッダー。
+			continue
+		case strings.HasPrefix(line, "+"):
+			h := &hunks[len(hunks)-1]
+			h.newLines = append(h.newLines, line[1:]+"\n")
+			last = '+'
+		case strings.HasPrefix(line, "-"):
+			last = '-'
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" は直前の行に改行が無いことを示す。
+			if last == '+' {
+				h := &hunks[len(hunks)-1]
+				h.newLines[len(h.newLines)-1] = strings.TrimSuffix(h.newLines[len(h.newLines)-1], "\n")
+			}
+		}
+	}
+	return hunks, true
+}
+
+// parseHunkHeader は "@@ -a[,b] +c[,d] @@" を解析する。
+func parseHunkHeader(line string) (hunk, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") {
+		return hunk{}, false
+	}
+	start, count, ok := parseRange(fields[1][1:])
+	if !ok {
+		return hunk{}, false
+	}
+	return hunk{oldStart: start, oldLines: count}, true
+}
+
+func parseRange(s string) (int, int, bool) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, count, true
+}
+
+// applyHunks は base に applied が true のハンクのみを適用した内容を返す。
+func applyHunks(base []string, hunks []hunk, applied []bool) []byte {
+	var buf bytes.Buffer
+	pos := 0
+	for i, h := range hunks {
+		start := h.oldStart - 1
+		if h.oldLines == 0 {
+			start = h.oldStart
+		}
+		for ; pos < start && pos < len(base); pos++ {
+			buf.WriteString(base[pos])
+		}
+		if applied[i] {
+			for _, line := range h.newLines {
+				buf.WriteString(line)
+			}
+		} else {
+			for j := start; j < start+h.oldLines && j < len(base); j++ {
+				buf.WriteString(base[j])
+			}
+		}
+		pos = start + h.oldLines
+	}
+	for ; pos < len(base); pos++ {
+		buf.WriteString(base[pos])
+	}
+	return buf.Bytes()
+}
+
+// splitLinesKeepEOL は内容を改行を保持したまま行に分割する。
+func splitLinesKeepEOL(content []byte) []string {
+	var lines []string
+	for len(content) > 0 {
+		idx := bytes.IndexByte(content, '\n')
+		if idx < 0 {
+			lines = append(lines, string(content))
+			break
+		}
+		lines = append(lines, string(content[:idx+1]))
+		content = content[idx+1:]
+	}
+	return lines
+}
+
+// parseBlameCommits は git blame --porcelain の出力から各行のコミットを抽出する。
+func parseBlameCommits(output []byte) []string {
+	var commits []string
+	for _, line := range strings.Split(string(output), "\n") {
+		// 行の内容はタブで始まるため、ヘッダー行と誤認しないよう除外する。
+		if strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 || (len(fields[0]) != 40 && len(fields[0]) != 64) {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		if strings.Trim(fields[0], "0123456789abcdef") != "" {
+			continue
+		}
+		commits = append(commits, fields[0])
+	}
+	return commits
+}
+
+// commitIndex は候補一覧（新しい順）におけるコミットの位置を返す。含まれない場合は len(candidates)。
+func commitIndex(candidates []string, commit string) int {
+	for i, c := range candidates {
+		if c == commit {
+			return i
+		}
+	}
+	return len(candidates)
+}
@@ -0,0 +1,214 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+func TestParseZeroContextHunks(t *testing.T) {
+	patch := "diff --git a/a.cpp b/a.cpp\n" +
+		"index 1111111..2222222 100644\n" +
+		"--- a/a.cpp\n" +
+		"+++ b/a.cpp\n" +
+		"@@ -1 +1 @@\n" +
+		"-one\n" +
+		"+ONE\n" +
+		"@@ -3,0 +4,2 @@ context\n" +
+		"+new1\n" +
+		"+new2\n" +
+		"@@ -5,2 +6,0 @@\n" +
+		"-five\n" +
+		"-six\n" +
+		"@@ -8 +8 @@\n" +
+		"-last\n" +
+		"\\ No newline at end of file\n" +
+		"+LAST\n" +
+		"\\ No newline at end of file\n"
+
+	hunks, ok := parseZeroContextHunks([]byte(patch))
+	if !ok {
+		t.Fatal("parseZeroContextHunks() should accept a text patch")
+	}
+	if len(hunks) != 4 {
+		t.Fatalf("Expected 4 hunks, got %d", len(hunks))
+	}
+
+	base := splitLinesKeepEOL([]byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\nlast"))
+
+	all := []bool{true, true, true, true}
+	expected := "ONE\ntwo\nthree\nnew1\nnew2\nfour\nseven\nLAST"
+	if got := string(applyHunks(base, hunks, all)); got != expected {
+		t.Errorf("applyHunks(all) = %q, want %q", got, expected)
+	}
+
+	partial := []bool{false, true, false, false}
+	expected = "one\ntwo\nthree\nnew1\nnew2\nfour\nfive\nsix\nseven\nlast"
+	if got := string(applyHunks(base, hunks, partial)); got != expected {
+		t.Errorf("applyHunks(partial) = %q, want %q", got, expected)
+	}
+}
+
+func TestParseZeroContextHunksBinary(t *testing.T) {
+	patch := "diff --git a/a.bin b/a.bin\nindex 1111111..2222222 100644\nBinary files a/a.bin and b/a.bin differ\n"
+	if _, ok := parseZeroContextHunks([]byte(patch)); ok {
+		t.Error("Binary patches should not be split into hunks")
+	}
+}
+
+func TestParseBlameCommits(t *testing.T) {
+	sha := strings.Repeat("a", 40)
+	output := sha + " 1 1 2\n" +
+		"author Test User\n" +
+		"filename a.cpp\n" +
+		"\t" + strings.Repeat("b", 40) + " 1 1\n" +
+		sha + " 2 2\n" +
+		"\tline\n"
+
+	commits := parseBlameCommits([]byte(output))
+	if len(commits) != 2 || commits[0] != sha || commits[1] != sha {
+		t.Errorf("Unexpected blame commits: %q", commits)
+	}
+}
+
+func TestAbsorbFixupTargetsPerHunk(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping absorb test")
+	}
+
+	devRepo, opsRepo := createAbsorbRepositories(t)
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = opsRepo
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Failed to run git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	addA := run("rev-parse", "HEAD~2")
+	addB := run("rev-parse", "HEAD~1")
+	editA := run("rev-parse", "HEAD")
+
+	os.WriteFile(filepath.Join(opsRepo, "a.cpp"), []byte("A1\na2\na3\na4\nA5-fixed\n"), 0644)
+	os.WriteFile(filepath.Join(opsRepo, "b.cpp"), []byte("b1\nB2\nb3\n"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:    devRepo,
+		OpsRepoPath:    opsRepo,
+		GitExecutable:  "git",
+		FixupMsgPrefix: "fixup! ",
+		FixupTarget:    config.FixupTargetAbsorb,
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+
+	if len(result.Fixups) != 3 {
+		t.Fatalf("Expected 3 fixup commits, got %+v", result.Fixups)
+	}
+	expectedTargets := []string{addA, addB, editA}
+	expectedSubjects := []string{"fixup! Add a", "fixup! Add b", "fixup! Edit a"}
+	for i, fixup := range result.Fixups {
+		if fixup.Target != expectedTargets[i] {
+			t.Errorf("Fixup %d: expected target %s, got %s", i, expectedTargets[i], fixup.Target)
+		}
+		if subject := run("log", "-1", "--format=%s", fixup.Commit); subject != expectedSubjects[i] {
+			t.Errorf("Fixup %d: expected subject %q, got %q", i, expectedSubjects[i], subject)
+		}
+	}
+
+	// 1 件目の fixup は a.cpp の 1 行目のみを変更する。
+	if content := run("show", result.Fixups[0].Commit+":a.cpp"); content != "A1\na2\na3\na4\na5-edited" {
+		t.Errorf("Unexpected a.cpp in first fixup: %q", content)
+	}
+	if status := run("status", "--porcelain"); status != "" {
+		t.Errorf("Ops repository should be clean, got: %s", status)
+	}
+}
+
+func TestAbsorbFixupWithAutosquash(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping absorb autosquash test")
+	}
+
+	devRepo, opsRepo := createAbsorbRepositories(t)
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = opsRepo
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Failed to run git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	os.WriteFile(filepath.Join(opsRepo, "a.cpp"), []byte("A1\na2\na3\na4\na5-edited\n"), 0644)
+	os.WriteFile(filepath.Join(opsRepo, "b.cpp"), []byte("b1\nB2\nb3\n"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		FixupTarget:       config.FixupTargetAbsorb,
+		AutosquashEnabled: true,
+	}
+
+	if _, err := NewFixupManager(cfg).RunFixup(context.Background()); err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+
+	subjects := run("log", "--format=%s")
+	if subjects != "Edit a\nAdd b\nAdd a\nInitial commit" {
+		t.Errorf("Fixups should be squashed into their targets, got:\n%s", subjects)
+	}
+	if content := run("show", "HEAD~2:a.cpp"); content != "A1\na2\na3\na4\na5" {
+		t.Errorf("First line change should be folded into 'Add a', got %q", content)
+	}
+	if content := run("show", "HEAD~1:b.cpp"); content != "b1\nB2\nb3" {
+		t.Errorf("b.cpp change should be folded into 'Add b', got %q", content)
+	}
+}
+
+// createAbsorbRepositories は Add a / Add b / Edit a の 3 コミットを持つ Ops リポジトリを作成する。
+func createAbsorbRepositories(t *testing.T) (string, string) {
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryFixup(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryFixup(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	steps := []struct {
+		file, content, message string
+	}{
+		{"a.cpp", "a1\na2\na3\na4\na5\n", "Add a"},
+		{"b.cpp", "b1\nb2\nb3\n", "Add b"},
+		{"a.cpp", "a1\na2\na3\na4\na5-edited\n", "Edit a"},
+	}
+	for _, step := range steps {
+		os.WriteFile(filepath.Join(opsRepo, step.file), []byte(step.content), 0644)
+		for _, args := range [][]string{{"add", step.file}, {"commit", "-m", step.message}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = opsRepo
+			if err := cmd.Run(); err != nil {
+				t.Fatalf("Failed to run git %v: %v", args, err)
+			}
+		}
+	}
+
+	return devRepo, opsRepo
+}
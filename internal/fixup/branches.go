@@ -0,0 +1,212 @@
+package fixup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fixup-commit-sync-manager/internal/autostash"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/rewrite"
+)
+
+// BranchResult は一つの Ops ブランチに対する fixup の結果。
+type BranchResult struct {
+	Branch string
+	// PendingFixups は処理前にブランチに残っていた未公開の fixup!/squash!/amend! コミットの数。
+	PendingFixups int
+	// Stashed はブランチの作業が自動 stash に退避されていたことを表す。
+	Stashed bool
+	// Dirty はブランチがチェックアウトされており、未コミットの変更があったことを表す。
+	Dirty  bool
+	Result *FixupResult
+	Err    error
+}
+
+// RunAllBranches は fixup! コミットや退避された作業が残っているすべての Ops ブランチを順に fixup する。
+// ブランチごとの失敗は結果に記録して次のブランチへ進み、最後に元のブランチへ戻す。
+// メトリクスには全ブランチの合計を 1 回の fixup として記録する。
+func (f *FixupManager) RunAllBranches(ctx context.Context) ([]BranchResult, error) {
+	started := time.Now()
+	branches, err := f.runAllBranches(ctx)
+
+	results := make([]*FixupResult, 0, len(branches))
+	cycleErr := err
+	for _, branch := range branches {
+		results = append(results, branch.Result)
+		if cycleErr == nil && branch.Err != nil {
+			cycleErr = branch.Err
+		}
+	}
+	observeFixup(started, results, cycleErr)
+	f.recordHistory(ctx, started, branches, err)
+	return branches, err
+}
+
+func (f *FixupManager) runAllBranches(ctx context.Context) ([]BranchResult, error) {
+	lock, err := f.lockOps(ctx, "fixup")
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	ctx = lock.Context()
+
+	original, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return nil, lockLost(lock, fmt.Errorf("failed to get current branch: %w", err))
+	}
+
+	results, err := f.PendingBranches(ctx)
+	if err != nil {
+		return nil, lockLost(lock, err)
+	}
+
+	for i := range results {
+		branch := &results[i]
+		if branch.Err != nil {
+			continue
+		}
+		// 中断された場合は残りのブランチを処理せず、元のブランチへ戻る。
+		if err := ctx.Err(); err != nil {
+			branch.Err = err
+			continue
+		}
+		if err := f.ensureOpsBranch(ctx, branch.Branch); err != nil {
+			branch.Err = fmt.Errorf("failed to switch to branch: %w", err)
+			continue
+		}
+		branch.Result, branch.Err = f.fixupBranch(ctx, branch.Branch, true)
+	}
+	for i := range results {
+		results[i].Err = lockLost(lock, results[i].Err)
+	}
+
+	// detached HEAD から開始した場合は戻す先のブランチが無い。ロックを失った場合は他のプロセスの処理中のため戻さない。
+	if original != "" && lock.Lost() == nil {
+		if err := f.ensureOpsBranch(context.WithoutCancel(ctx), original); err != nil {
+			return results, fmt.Errorf("failed to return to branch %s: %w", original, err)
+		}
+	}
+
+	return results, nil
+}
+
+// PendingBranches は fixup の対象となる作業が残っている Ops のブランチを返す。
+// 未公開の fixup!/squash!/amend! コミットがあるブランチ、自動 stash に作業が退避されているブランチ、
+// 未コミットの変更があるカレントブランチが対象となる。
+func (f *FixupManager) PendingBranches(ctx context.Context) ([]BranchResult, error) {
+	branches, err := git.Lines(ctx, f.ops, "for-each-ref", "--format=%(refname:short)", "refs/heads/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	entries, err := autostash.NewStasher(f.ops).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stashed := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		stashed[entry.Branch] = true
+	}
+
+	current, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+	paths, _, err := f.collectChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for uncommitted changes: %w", err)
+	}
+
+	var pending []BranchResult
+	for _, branch := range branches {
+		tip := "refs/heads/" + branch
+		rng, err := f.resolveRangeAt(ctx, branch, tip)
+		if err != nil {
+			pending = append(pending, BranchResult{Branch: branch, Err: err})
+			continue
+		}
+		commits, err := f.unpublishedCommitsFrom(ctx, tip, rng.MergeBase)
+		if err != nil {
+			return nil, err
+		}
+
+		result := BranchResult{
+			Branch:  branch,
+			Stashed: stashed[branch],
+			Dirty:   branch == current && len(paths) > 0,
+		}
+		for _, commit := range commits {
+			if isFixupSubject(commit.Subject) {
+				result.PendingFixups++
+			}
+		}
+		if result.PendingFixups > 0 || result.Stashed || result.Dirty {
+			pending = append(pending, result)
+		}
+	}
+
+	return pending, nil
+}
+
+// PendingFixups は Ops のカレントブランチに残っている未公開の fixup!/squash!/amend! コミットを古い順に返す。
+func (f *FixupManager) PendingFixups(ctx context.Context) ([]PlannedCommit, error) {
+	branch, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := f.resolveRange(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := f.unpublishedCommits(ctx, rng.MergeBase)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []PlannedCommit
+	for _, commit := range commits {
+		if isFixupSubject(commit.Subject) {
+			pending = append(pending, PlannedCommit{Hash: commit.Hash, Subject: commit.Subject})
+		}
+	}
+	return pending, nil
+}
+
+// squashPending は残っている fixup!/squash!/amend! コミットを autosquash し、その数とバックアップ ref、書き換えの対応を返す。
+// 対象コミットが範囲内に見つからない fixup コミットは数えない。
+func (f *FixupManager) squashPending(ctx context.Context, rng *fixupRange) (int, string, []rewrite.Entry, error) {
+	if !f.cfg.AutosquashEnabled {
+		return 0, "", nil, nil
+	}
+
+	commits, err := f.unpublishedCommits(ctx, rng.MergeBase)
+	if err != nil {
+		return 0, "", nil, err
+	}
+
+	var original []TodoEntry
+	for _, commit := range commits {
+		if len(commit.Parents) <= 1 {
+			original = append(original, TodoEntry{Action: "pick", Commit: commit.Hash, Subject: commit.Subject})
+		}
+	}
+	todo := autosquashTodo(original)
+
+	pending := 0
+	for _, entry := range todo {
+		if entry.Action != "pick" {
+			pending++
+		}
+	}
+	start := rewriteStart(original, todo)
+	if pending == 0 || start >= len(todo) {
+		return 0, "", nil, nil
+	}
+
+	backupRef, rewrites, err := f.autosquash(ctx, rng, todo[start].Commit)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return pending, backupRef, rewrites, nil
+}
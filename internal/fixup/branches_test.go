@@ -0,0 +1,135 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+func TestRunAllBranches(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping all-branches fixup test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1")
+	commit := func(file, content, message string) {
+		os.WriteFile(filepath.Join(opsRepo, file), []byte(content), 0644)
+		run("add", "-A")
+		run("commit", "-m", message)
+	}
+
+	// fixup! コミットが残っているブランチ。
+	run("checkout", "-b", "other", "origin/main")
+	commit("other1.cpp", "1", "Other 1")
+	commit("other2.cpp", "2", "Other 2")
+	commit("other1.cpp", "1 fixed", "fixup! Other 1")
+
+	// 作業が自動 stash に退避されているブランチ。
+	run("checkout", "-b", "stashy", "origin/main")
+	commit("stashy.cpp", "1", "Stashy 1")
+	os.WriteFile(filepath.Join(opsRepo, "stashy.cpp"), []byte("1 fixed"), 0644)
+	run("stash", "push", "--include-untracked", "-m", "fcsm-autostash/stashy")
+
+	// 処理対象の作業が無いブランチ。
+	run("checkout", "-b", "idle", "origin/main")
+	commit("idle.cpp", "1", "Idle 1")
+
+	run("checkout", "feature")
+	os.WriteFile(filepath.Join(opsRepo, "featurea.cpp"), []byte("Feature 1 fixed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		FixupUpstream:     "origin/main",
+		AutosquashEnabled: true,
+	}
+
+	results, err := NewFixupManager(cfg).RunAllBranches(context.Background())
+	if err != nil {
+		t.Fatalf("RunAllBranches() failed: %v", err)
+	}
+
+	var got []string
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("Branch %s failed: %v", result.Branch, result.Err)
+		}
+		got = append(got, result.Branch)
+	}
+	if strings.Join(got, " ") != "feature other stashy" {
+		t.Fatalf("Expected feature, other and stashy to be processed, got %q", got)
+	}
+	if !results[0].Dirty || results[1].PendingFixups != 1 || !results[2].Stashed {
+		t.Errorf("Unexpected pending state: %+v", results)
+	}
+	if results[1].Result.PendingFixups != 1 || results[1].Result.BackupRef == "" {
+		t.Errorf("Pending fixup on other should be squashed with a backup, got %+v", results[1].Result)
+	}
+
+	expected := map[string]string{
+		"feature": "Feature 1",
+		"other":   "Other 2\nOther 1",
+		"stashy":  "Stashy 1",
+	}
+	for branch, subjects := range expected {
+		if got := run("log", "--format=%s", "origin/main.."+branch); got != subjects {
+			t.Errorf("Unexpected history on %s:\n%s", branch, got)
+		}
+	}
+	if content := run("show", "other:other1.cpp"); content != "1 fixed" {
+		t.Errorf("Fixup should be folded into Other 1, got %q", content)
+	}
+	if content := run("show", "stashy:stashy.cpp"); content != "1 fixed" {
+		t.Errorf("Stashed change should be folded into Stashy 1, got %q", content)
+	}
+	if content := run("show", "feature:featurea.cpp"); content != "Feature 1 fixed" {
+		t.Errorf("Dirty change should be folded into Feature 1, got %q", content)
+	}
+
+	if branch := run("branch", "--show-current"); branch != "feature" {
+		t.Errorf("Should return to the original branch, got %s", branch)
+	}
+	if stashes := run("stash", "list"); stashes != "" {
+		t.Errorf("No stashes should remain, got: %s", stashes)
+	}
+	if status := run("status", "--porcelain"); status != "" {
+		t.Errorf("Ops repository should be clean, got: %s", status)
+	}
+}
+
+func TestPendingFixups(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping pending fixups test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+	os.WriteFile(filepath.Join(opsRepo, "featurea.cpp"), []byte("Feature 1 fixed"), 0644)
+	run("commit", "-am", "fixup! Feature 1")
+
+	cfg := &config.Config{
+		DevRepoPath:   devRepo,
+		OpsRepoPath:   opsRepo,
+		GitExecutable: "git",
+		FixupUpstream: "origin/main",
+	}
+
+	pending, err := NewFixupManager(cfg).PendingFixups(context.Background())
+	if err != nil {
+		t.Fatalf("PendingFixups() failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Subject != "fixup! Feature 1" || pending[0].Hash != run("rev-parse", "HEAD") {
+		t.Errorf("Expected the fixup! commit to be pending, got %+v", pending)
+	}
+
+	// 公開済みの fixup! コミットは対象外となる。
+	run("update-ref", "refs/remotes/origin/main", "HEAD")
+	if pending, err := NewFixupManager(cfg).PendingFixups(context.Background()); err != nil || len(pending) != 0 {
+		t.Errorf("Published fixup! commits should not be pending, got %+v (err %v)", pending, err)
+	}
+}
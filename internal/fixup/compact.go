@@ -0,0 +1,284 @@
+package fixup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/backup"
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/rewrite"
+	fcsync "fixup-commit-sync-manager/internal/sync"
+)
+
+// CompactedGroup は一つのコミットにまとめた同期コミットの組。
+type CompactedGroup struct {
+	Window    time.Time
+	Commits   []string
+	NewCommit string
+}
+
+// CompactionResult は同期コミットの圧縮結果。
+type CompactionResult struct {
+	Groups []CompactedGroup
+	// BackupRef は圧縮前の状態を保存したバックアップ ref。圧縮しなかった場合は空。
+	BackupRef string
+	// Rewrites は圧縮と積み直しによる書き換え前後のコミットの対応。
+	Rewrites []rewrite.Entry
+}
+
+// compactionUnit は圧縮時に一つの単位として扱うコミット列。
+type compactionUnit struct {
+	commits []commitInfo
+	window  time.Time
+	compact bool
+}
+
+// RunCompaction は Ops のカレントブランチで、連続する同期コミットを時間枠ごとに一つのコミットにまとめる。
+func (f *FixupManager) RunCompaction(ctx context.Context) (*CompactionResult, error) {
+	lock, err := f.lockOps(ctx, "compact")
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+	ctx = lock.Context()
+
+	branch, err := f.prepareOpsBranch(ctx)
+	if err != nil {
+		return nil, lockLost(lock, err)
+	}
+
+	rng, err := f.resolveRange(ctx, branch)
+	if err != nil {
+		return nil, lockLost(lock, fmt.Errorf("failed to resolve fixup range: %w", err))
+	}
+
+	result, err := f.compact(ctx, rng)
+	return result, lockLost(lock, err)
+}
+
+// compact は範囲内の未公開コミットのうち、同じ時間枠に属する連続した同期コミットを一つにまとめる。
+// 人が作成したコミットは内容・作者・メッセージを保ったまま積み直す。公開済みのコミットは変更しない。
+func (f *FixupManager) compact(ctx context.Context, rng *fixupRange) (*CompactionResult, error) {
+	if f.cfg.CompactionWindow == "" {
+		return &CompactionResult{}, nil
+	}
+
+	commits, err := f.unpublishedCommits(ctx, rng.MergeBase)
+	if err != nil {
+		return nil, err
+	}
+	for _, commit := range commits {
+		// マージを含む履歴は線形に積み直せないため対象外とする。
+		if len(commit.Parents) > 1 {
+			return &CompactionResult{}, nil
+		}
+	}
+
+	units := f.compactionUnits(commits, time.Now())
+	needed := false
+	for _, unit := range units {
+		needed = needed || unit.compact
+	}
+	if !needed {
+		return &CompactionResult{}, nil
+	}
+
+	head, err := git.Output(ctx, f.ops, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	branch, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+	backupRef, err := backup.NewManager(f.cfg, f.ops).Create(ctx, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CompactionResult{BackupRef: backupRef}
+	tip := firstParent(commits[0])
+	rewritten := false
+	for _, unit := range units {
+		if unit.compact {
+			commit, err := f.commitCompacted(ctx, unit, tip)
+			if err != nil {
+				return nil, err
+			}
+			hashes := make([]string, 0, len(unit.commits))
+			for _, c := range unit.commits {
+				hashes = append(hashes, c.Hash)
+				result.Rewrites = append(result.Rewrites, rewrite.Entry{Old: c.Hash, New: commit})
+			}
+			result.Groups = append(result.Groups, CompactedGroup{Window: unit.window, Commits: hashes, NewCommit: commit})
+			tip = commit
+			rewritten = true
+			continue
+		}
+
+		for _, c := range unit.commits {
+			if !rewritten {
+				// 最初の圧縮対象より前のコミットはそのまま残す。
+				tip = c.Hash
+				continue
+			}
+			commit, err := f.recommit(ctx, c, tip)
+			if err != nil {
+				return nil, err
+			}
+			result.Rewrites = append(result.Rewrites, rewrite.Entry{Old: c.Hash, New: commit})
+			tip = commit
+		}
+	}
+
+	// ツリーは変わらないため、作業ツリーとインデックスはそのままでよい。
+	if _, err := f.ops.Run(ctx, "update-ref", "-m", "fcsm: compact sync commits", "HEAD", tip, head); err != nil {
+		return nil, fmt.Errorf("failed to update HEAD: %w", err)
+	}
+
+	if err := f.recordRewrites(ctx, "compact", backupRef, result.Rewrites); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// compactionUnits はコミット列を、同じ時間枠に属する連続した同期コミットごとに分割する。
+// 終了していない時間枠の同期コミットは、後続のコミットが加わる可能性があるため圧縮しない。
+func (f *FixupManager) compactionUnits(commits []commitInfo, now time.Time) []compactionUnit {
+	var units []compactionUnit
+	for _, commit := range commits {
+		if !f.isSyncCommit(commit) {
+			units = append(units, compactionUnit{commits: []commitInfo{commit}})
+			continue
+		}
+
+		window := f.windowStart(commit.Time)
+		if n := len(units); n > 0 && !units[n-1].window.IsZero() && units[n-1].window.Equal(window) {
+			units[n-1].commits = append(units[n-1].commits, commit)
+			continue
+		}
+		units = append(units, compactionUnit{commits: []commitInfo{commit}, window: window})
+	}
+
+	for i := range units {
+		unit := &units[i]
+		unit.compact = !unit.window.IsZero() && len(unit.commits) >= 2 && !f.windowEnd(unit.window).After(now)
+	}
+	return units
+}
+
+// isSyncCommit は commit が同期処理によって作成されたかを返す。
+// trailer の無い以前の同期コミットは、コミットテンプレートの固定部分で判定する。
+func (f *FixupManager) isSyncCommit(commit commitInfo) bool {
+	if commit.Sync {
+		return true
+	}
+	prefix, _, _ := strings.Cut(f.cfg.CommitTemplate, "${")
+	return strings.TrimSpace(prefix) != "" && strings.HasPrefix(commit.Subject, prefix)
+}
+
+func (f *FixupManager) windowStart(t time.Time) time.Time {
+	t = t.Local()
+	if f.cfg.CompactionWindow == config.CompactionWindowDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+func (f *FixupManager) windowEnd(start time.Time) time.Time {
+	if f.cfg.CompactionWindow == config.CompactionWindowDay {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(time.Hour)
+}
+
+func (f *FixupManager) windowLabel(start time.Time) string {
+	if f.cfg.CompactionWindow == config.CompactionWindowDay {
+		return start.Format("2006-01-02")
+	}
+	return start.Format("2006-01-02 15:00") + "-" + f.windowEnd(start).Format("15:00")
+}
+
+// commitCompacted は unit の最後のコミットのツリーを持つ一つのコミットを parent の上に作成する。
+func (f *FixupManager) commitCompacted(ctx context.Context, unit compactionUnit, parent string) (string, error) {
+	first, last := unit.commits[0], unit.commits[len(unit.commits)-1]
+
+	from := firstParent(first)
+	if from == "" {
+		// ルートコミットからの差分は空ツリーと比較する。
+		emptyTree, err := f.ops.RunWithOptions(ctx, git.RunOptions{Stdin: strings.NewReader("")}, "hash-object", "-t", "tree", "--stdin")
+		if err != nil {
+			return "", fmt.Errorf("failed to compute empty tree: %w", err)
+		}
+		from = strings.TrimSpace(string(emptyTree))
+	}
+	files, err := f.ops.Run(ctx, "diff", "--name-status", "--no-renames", from, last.Hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to list compacted files: %w", err)
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "Auto-sync (compacted): %s, %d commits\n\n", f.windowLabel(unit.window), len(unit.commits))
+	for _, c := range unit.commits {
+		fmt.Fprintf(&message, "- %s\n", c.Subject)
+	}
+	if list := strings.TrimSpace(string(files)); list != "" {
+		fmt.Fprintf(&message, "\nFiles:\n%s\n", list)
+	}
+	fmt.Fprintf(&message, "\n%s: compacted\n", fcsync.CommitTrailer)
+
+	env, err := f.authorEnv(ctx, last.Hash)
+	if err != nil {
+		return "", err
+	}
+	return f.commitTree(ctx, last.Hash, parent, message.String(), env)
+}
+
+// recommit は commit と同じツリー・作者・メッセージのコミットを parent の上に作成する。
+func (f *FixupManager) recommit(ctx context.Context, commit commitInfo, parent string) (string, error) {
+	message, err := f.ops.Run(ctx, "log", "-1", "--format=%B", commit.Hash)
+	if err != nil {
+		return "", fmt.Errorf("failed to read message of %s: %w", commit.Hash, err)
+	}
+
+	env, err := f.authorEnv(ctx, commit.Hash)
+	if err != nil {
+		return "", err
+	}
+	return f.commitTree(ctx, commit.Hash, parent, strings.TrimRight(string(message), "\n")+"\n", env)
+}
+
+// authorEnv は commit の作者情報を commit-tree に引き継ぐための環境変数を返す。
+func (f *FixupManager) authorEnv(ctx context.Context, commit string) ([]string, error) {
+	output, err := git.Output(ctx, f.ops, "log", "-1", "--format=%an%x00%ae%x00%ad", "--date=raw", commit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read author of %s: %w", commit, err)
+	}
+	fields := strings.SplitN(output, "\x00", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("malformed author of %s: %q", commit, output)
+	}
+	return []string{
+		"GIT_AUTHOR_NAME=" + fields[0],
+		"GIT_AUTHOR_EMAIL=" + fields[1],
+		"GIT_AUTHOR_DATE=" + fields[2],
+	}, nil
+}
+
+// commitTree は treeish のツリーで parent を親とするコミットを作成する。parent が空の場合はルートコミットとなる。
+func (f *FixupManager) commitTree(ctx context.Context, treeish, parent, message string, env []string) (string, error) {
+	args := []string{"commit-tree", treeish + "^{tree}"}
+	if parent != "" {
+		args = append(args, "-p", parent)
+	}
+	args = append(args, "-F", "-")
+
+	commit, err := f.ops.RunWithOptions(ctx, git.RunOptions{Env: env, Stdin: strings.NewReader(message)}, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create commit: %w", err)
+	}
+	return strings.TrimSpace(string(commit)), nil
+}
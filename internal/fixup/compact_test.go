@@ -0,0 +1,175 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+func TestCompactionUnits(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.Local)
+	}
+	commits := []commitInfo{
+		{Hash: "s1", Time: at(10, 10), Sync: true},
+		{Hash: "s2", Time: at(10, 20), Sync: true},
+		{Hash: "h1", Time: at(10, 30)},
+		{Hash: "s3", Time: at(10, 40), Sync: true},
+		{Hash: "s4", Time: at(11, 5), Sync: true},
+		{Hash: "s5", Time: at(12, 10), Sync: true},
+		{Hash: "s6", Time: at(12, 20), Sync: true},
+	}
+
+	f := NewFixupManager(&config.Config{GitExecutable: "git", CompactionWindow: config.CompactionWindowHour})
+	units := f.compactionUnits(commits, time.Date(2024, 1, 1, 12, 30, 0, 0, time.Local))
+
+	var got []string
+	for _, unit := range units {
+		var hashes []string
+		for _, c := range unit.commits {
+			hashes = append(hashes, c.Hash)
+		}
+		label := strings.Join(hashes, "+")
+		if unit.compact {
+			label = "[" + label + "]"
+		}
+		got = append(got, label)
+	}
+
+	// 12 時台はまだ終わっていないため圧縮しない。
+	want := "[s1+s2] h1 s3 s4 s5+s6"
+	if strings.Join(got, " ") != want {
+		t.Errorf("compactionUnits() = %q, want %q", strings.Join(got, " "), want)
+	}
+
+	f.cfg.CompactionWindow = config.CompactionWindowDay
+	units = f.compactionUnits(commits, time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local))
+	if len(units) != 3 || !units[0].compact || units[1].compact || !units[2].compact || len(units[2].commits) != 4 {
+		t.Errorf("Day window should merge s3..s6 around h1, got %+v", units)
+	}
+}
+
+func TestCompactSyncCommits(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping compaction test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t)
+
+	commit := func(name, message, date string) {
+		os.WriteFile(filepath.Join(opsRepo, name), []byte(message), 0644)
+		run("add", "-A")
+		cmd := exec.Command("git", "commit", "-m", message)
+		cmd.Dir = opsRepo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to commit %q: %v\n%s", message, err, output)
+		}
+	}
+	sync := func(name, date string) {
+		commit(name, "Auto-sync: "+date+"\n\nFcsm-Sync: auto", date)
+	}
+
+	sync("a.cpp", "2024-01-01T10:10:00")
+	sync("b.cpp", "2024-01-01T10:20:00")
+	commit("human.cpp", "Human change", "2024-01-01T10:30:00")
+	sync("a.cpp", "2024-01-01T10:40:00")
+	sync("c.cpp", "2024-01-01T10:50:00")
+	sync("d.cpp", "2024-01-01T11:05:00")
+
+	tree := run("rev-parse", "HEAD^{tree}")
+	humanAuthor := run("log", "-1", "--format=%an %ae %at", "HEAD~3")
+
+	cfg := &config.Config{
+		DevRepoPath:      devRepo,
+		OpsRepoPath:      opsRepo,
+		GitExecutable:    "git",
+		CommitTemplate:   "Auto-sync: ${timestamp} @ ${hash}",
+		FixupUpstream:    "origin/main",
+		CompactionWindow: config.CompactionWindowHour,
+	}
+
+	result, err := NewFixupManager(cfg).RunCompaction(context.Background())
+	if err != nil {
+		t.Fatalf("RunCompaction() failed: %v", err)
+	}
+	if len(result.Groups) != 2 || result.BackupRef == "" {
+		t.Fatalf("Expected two compacted groups with a backup, got %+v", result)
+	}
+
+	if got := run("rev-parse", "HEAD^{tree}"); got != tree {
+		t.Errorf("Compaction must not change the tree: %s != %s", got, tree)
+	}
+	if status := run("status", "--porcelain"); status != "" {
+		t.Errorf("Working tree should stay clean, got: %s", status)
+	}
+
+	subjects := strings.Split(run("log", "--format=%s", "origin/main..HEAD"), "\n")
+	if len(subjects) != 4 {
+		t.Fatalf("Expected 4 commits after compaction, got %q", subjects)
+	}
+	if !strings.HasPrefix(subjects[1], "Auto-sync (compacted): 2024-01-01 10:00-11:00, 2 commits") || subjects[2] != "Human change" || !strings.HasPrefix(subjects[3], "Auto-sync (compacted)") {
+		t.Errorf("Unexpected history after compaction: %q", subjects)
+	}
+	if got := run("log", "-1", "--format=%an %ae %at", "HEAD~2"); got != humanAuthor {
+		t.Errorf("Human commit author should be preserved: %s != %s", got, humanAuthor)
+	}
+
+	body := run("log", "-1", "--format=%B", "HEAD~1")
+	for _, want := range []string{"- Auto-sync: 2024-01-01T10:40:00", "- Auto-sync: 2024-01-01T10:50:00", "M\ta.cpp", "A\tc.cpp", "Fcsm-Sync: compacted"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Compacted message should contain %q:\n%s", want, body)
+		}
+	}
+	if got := run("rev-parse", "origin/main"); got != run("rev-parse", "HEAD~4") {
+		t.Errorf("Published history should be kept")
+	}
+
+	// 圧縮済みの履歴に対して再度実行しても変化しない。
+	head := run("rev-parse", "HEAD")
+	result, err = NewFixupManager(cfg).RunCompaction(context.Background())
+	if err != nil || len(result.Groups) != 0 || run("rev-parse", "HEAD") != head {
+		t.Errorf("Second compaction should be a no-op, got %+v, %v", result, err)
+	}
+}
+
+func TestCompactKeepsPublishedSyncCommits(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping compaction test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t)
+	for _, date := range []string{"2024-01-01T10:10:00", "2024-01-01T10:20:00"} {
+		os.WriteFile(filepath.Join(opsRepo, "a.cpp"), []byte(date), 0644)
+		run("add", "-A")
+		cmd := exec.Command("git", "commit", "-m", "Auto-sync: "+date+"\n\nFcsm-Sync: auto")
+		cmd.Dir = opsRepo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Failed to commit: %v\n%s", err, output)
+		}
+	}
+	run("update-ref", "refs/remotes/origin/feature", "HEAD")
+	head := run("rev-parse", "HEAD")
+
+	cfg := &config.Config{
+		DevRepoPath:      devRepo,
+		OpsRepoPath:      opsRepo,
+		GitExecutable:    "git",
+		CompactionWindow: config.CompactionWindowDay,
+	}
+
+	result, err := NewFixupManager(cfg).RunCompaction(context.Background())
+	if err != nil {
+		t.Fatalf("RunCompaction() failed: %v", err)
+	}
+	if len(result.Groups) != 0 || run("rev-parse", "HEAD") != head {
+		t.Errorf("Published sync commits must not be compacted, got %+v", result)
+	}
+}
@@ -1,17 +1,32 @@
 package fixup
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"fixup-commit-sync-manager/internal/autostash"
+	"fixup-commit-sync-manager/internal/backup"
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/indexlock"
+	"fixup-commit-sync-manager/internal/metrics"
+	"fixup-commit-sync-manager/internal/pathfilter"
+	"fixup-commit-sync-manager/internal/repolock"
+	"fixup-commit-sync-manager/internal/retry"
+	"fixup-commit-sync-manager/internal/rewrite"
+	fcsync "fixup-commit-sync-manager/internal/sync"
 )
 
 type FixupManager struct {
 	cfg *config.Config
+	dev git.Runner
+	ops git.Runner
 }
 
 type FixupResult struct {
@@ -19,320 +34,634 @@ type FixupResult struct {
 	FixupCommitHash string
 	FilesModified   int
 	Success         bool
+	// UnrelatedChanges は同期対象外の Ops 側の未コミット変更。fixup には含めず報告のみ行う。
+	UnrelatedChanges []string
+	// Fixups は作成した fixup コミットの一覧。absorb では対象コミットごとに一件となる。
+	Fixups []FixupCommit
+	// BackupRef は autosquash rebase 前の状態を保存したバックアップ ref。rebase しなかった場合は空。
+	BackupRef string
+	// Compaction は fixup 前に行った同期コミットの圧縮結果。
+	Compaction *CompactionResult
+	// Amended は amend-last 戦略で HEAD を直接 amend したことを表す。この場合 autosquash は行わない。
+	Amended bool
+	// PendingFixups は新しい変更が無いブランチで autosquash した、既存の fixup! コミットの数。
+	PendingFixups int
+	// Rewrites は今回の圧縮・amend・autosquash rebase による書き換え前後のコミットの対応。
+	Rewrites []rewrite.Entry
 }
 
 func NewFixupManager(cfg *config.Config) *FixupManager {
-	return &FixupManager{cfg: cfg}
+	return NewFixupManagerWithRunners(cfg,
+		git.NewConfiguredRunner(cfg, cfg.DevRepoPath),
+		git.NewConfiguredRunner(cfg, cfg.OpsRepoPath))
 }
 
-func (f *FixupManager) RunFixup() (*FixupResult, error) {
-	if err := f.validateRepository(); err != nil {
-		return nil, fmt.Errorf("repository validation failed: %w", err)
+// NewFixupManagerWithRunners は Dev/Ops それぞれの git Runner を指定して FixupManager を作成する。
+func NewFixupManagerWithRunners(cfg *config.Config, dev, ops git.Runner) *FixupManager {
+	return &FixupManager{cfg: cfg, dev: dev, ops: ops}
+}
+
+// RunFixup は Ops のカレントブランチを Dev と揃えて fixup し、結果をメトリクスに記録する。
+func (f *FixupManager) RunFixup(ctx context.Context) (*FixupResult, error) {
+	started := time.Now()
+	result, err := f.runFixup(ctx)
+	observeFixup(started, []*FixupResult{result}, err)
+	f.recordHistory(ctx, started, []BranchResult{{Branch: f.devBranch(ctx), Result: result, Err: err}}, nil)
+	return result, err
+}
+
+func (f *FixupManager) runFixup(ctx context.Context) (*FixupResult, error) {
+	lock, err := f.lockOps(ctx, "fixup")
+	if err != nil {
+		return nil, err
 	}
+	defer lock.Release()
+	ctx = lock.Context()
 
-	// Dev側のカレントブランチを取得してOps側も同じブランチに切り替え。
-	devBranch, err := f.getDevCurrentBranch()
+	devBranch, err := f.prepareOpsBranch(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get dev current branch: %w", err)
+		return nil, lockLost(lock, metrics.WithCause(metrics.CauseBranch, err))
 	}
 
-	if err := f.ensureOpsBranch(devBranch); err != nil {
-		return nil, fmt.Errorf("failed to ensure ops branch: %w", err)
+	result, err := f.fixupBranch(ctx, devBranch, false)
+	return result, lockLost(lock, err)
+}
+
+// fixupBranch は Ops のカレントブランチ branch で fixup を行う。
+// squashPending が true の場合、未コミットの変更が無くても残っている fixup! コミットを autosquash する。
+func (f *FixupManager) fixupBranch(ctx context.Context, devBranch string, squashPending bool) (*FixupResult, error) {
+	rng, err := f.resolveRange(ctx, devBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve fixup range: %w", err)
 	}
 
-	hasChanges, err := f.hasUncommittedChanges()
+	// 同期コミットの圧縮はツリーを変えないため、未コミットの変更の有無に関わらず先に行う。
+	compaction, err := f.compact(ctx, rng)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check for uncommitted changes: %w", err)
+		return nil, metrics.WithCause(metrics.CauseCompaction, fmt.Errorf("failed to compact sync commits: %w", err))
 	}
 
-	if !hasChanges {
-		return &FixupResult{Success: true}, nil
+	result, err := f.fixupChanges(ctx, rng, squashPending)
+	if err != nil {
+		return nil, err
 	}
 
-	baseCommit, err := f.getBaseCommit()
+	result.Compaction = compaction
+	result.Rewrites = rewrite.Compose(compaction.Rewrites, result.Rewrites)
+	return result, nil
+}
+
+// fixupChanges は未コミットの変更を戦略と対象の設定に従って fixup し、autosquash する。
+func (f *FixupManager) fixupChanges(ctx context.Context, rng *fixupRange, squashPending bool) (*FixupResult, error) {
+	paths, unrelated, err := f.collectChanges(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get base commit: %w", err)
+		return nil, fmt.Errorf("failed to check for uncommitted changes: %w", err)
+	}
+
+	if len(paths) == 0 {
+		result := &FixupResult{Success: true, UnrelatedChanges: unrelated}
+		if squashPending {
+			if result.PendingFixups, result.BackupRef, result.Rewrites, err = f.squashPending(ctx, rng); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	if f.strategy() == config.FixupStrategyAmendLast {
+		result, ok, err := f.amendLast(ctx, paths, unrelated, rng)
+		if err != nil {
+			return nil, fmt.Errorf("failed to amend last sync commit: %w", err)
+		}
+		if ok {
+			return result, nil
+		}
 	}
 
-	modifiedFiles, err := f.getModifiedFilesCount()
+	if f.cfg.FixupTarget == config.FixupTargetAbsorb {
+		return f.runAbsorbFixup(ctx, paths, unrelated, rng)
+	}
+
+	baseCommit, err := f.getBaseCommit(ctx, rng)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count modified files: %w", err)
+		return nil, fmt.Errorf("failed to get base commit: %w", err)
 	}
 
-	if err := f.gitAddAll(); err != nil {
+	if err := f.gitAddPaths(ctx, paths); err != nil {
 		return nil, fmt.Errorf("failed to add changes: %w", err)
 	}
 
-	fixupHash, err := f.gitFixupCommit(baseCommit)
+	fixupHash, err := f.gitFixupCommit(ctx, baseCommit, paths)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create fixup commit: %w", err)
 	}
 
-	if f.cfg.AutosquashEnabled {
-		if err := f.gitRebaseAutosquash(); err != nil {
-			return nil, fmt.Errorf("failed to perform autosquash rebase: %w", err)
-		}
+	backupRef, rewrites, err := f.autosquash(ctx, rng, baseCommit)
+	if err != nil {
+		return nil, err
 	}
 
 	return &FixupResult{
-		CommitHash:      baseCommit,
-		FixupCommitHash: fixupHash,
-		FilesModified:   modifiedFiles,
-		Success:         true,
+		CommitHash:       baseCommit,
+		FixupCommitHash:  fixupHash,
+		FilesModified:    len(paths),
+		Success:          true,
+		UnrelatedChanges: unrelated,
+		Fixups:           []FixupCommit{{Target: baseCommit, Commit: fixupHash, Files: paths}},
+		BackupRef:        backupRef,
+		Rewrites:         rewrites,
 	}, nil
 }
 
-func (f *FixupManager) validateRepository() error {
-	opsGitDir := f.cfg.OpsRepoPath + "/.git"
-	if _, err := os.Stat(opsGitDir); err != nil {
-		return fmt.Errorf("ops repository .git directory not found: %s", opsGitDir)
+// lockOps は Ops リポジトリを検証し、他のプロセスの sync/fixup と同時に変更しないようロックを取得する。
+func (f *FixupManager) lockOps(ctx context.Context, operation string) (*repolock.Lock, error) {
+	if err := f.validateRepository(); err != nil {
+		return nil, metrics.WithCause(metrics.CauseValidation, fmt.Errorf("repository validation failed: %w", err))
 	}
 
-	originalDir, err := os.Getwd()
+	lock, err := repolock.NewLocker(f.cfg, f.ops).Acquire(ctx, operation)
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+		return nil, metrics.WithCause(metrics.CauseLock, fmt.Errorf("failed to lock ops repository: %w", err))
 	}
-	defer os.Chdir(originalDir)
+	return lock, nil
+}
 
-	if err := os.Chdir(f.cfg.OpsRepoPath); err != nil {
-		return fmt.Errorf("failed to change to ops repository: %w", err)
+// lockLost は処理中に Ops リポジトリのロックを失っていた場合、err をロックの失敗に置き換える。
+// ロックを失うと Lock.Context が終了するため、そのままでは利用者による中断と区別できない。
+// ロックを保持して行う処理は lock.Context() を使い、結果のエラーをこれに通す。
+func lockLost(lock *repolock.Lock, err error) error {
+	lost := lock.Lost()
+	if err == nil || lost == nil {
+		return err
 	}
+	return metrics.WithCause(metrics.CauseLock, fmt.Errorf("stopped because %w: %v", lost, err))
+}
 
-	// 動的ブランチ追従により、ensureOnTargetBranchは不要になった。
+// prepareOpsBranch は Ops リポジトリを Dev 側のカレントブランチに切り替えてそのブランチ名を返す。
+// リポジトリの検証はロックを取得する lockOps で済ませている前提とする。
+func (f *FixupManager) prepareOpsBranch(ctx context.Context) (string, error) {
+	// 異常終了した git が残した index.lock があると以降の操作がすべて失敗するため先に除去する。
+	if err := indexlock.NewRecoverer(f.cfg, f.ops).Recover(ctx, "fixup"); err != nil {
+		return "", fmt.Errorf("failed to recover index.lock: %w", err)
+	}
 
-	return nil
+	// Dev側のカレントブランチを取得してOps側も同じブランチに切り替え。
+	devBranch, err := f.getDevCurrentBranch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get dev current branch: %w", err)
+	}
+
+	if err := f.ensureOpsBranch(ctx, devBranch); err != nil {
+		return "", fmt.Errorf("failed to ensure ops branch: %w", err)
+	}
+
+	return devBranch, nil
 }
 
-// ensureOnTargetBranch は動的ブランチ追従により削除。
+// runAbsorbFixup は変更をハンク単位で対象コミットに振り分けて fixup コミットを作成する。
+func (f *FixupManager) runAbsorbFixup(ctx context.Context, paths, unrelated []string, rng *fixupRange) (*FixupResult, error) {
+	if err := f.gitAddPaths(ctx, paths); err != nil {
+		return nil, fmt.Errorf("failed to add changes: %w", err)
+	}
 
-func (f *FixupManager) getCurrentBranch() (string, error) {
-	cmd := exec.Command(f.cfg.GitExecutable, "branch", "--show-current")
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.Output()
+	fixups, err := f.absorb(ctx, paths, rng)
 	if err != nil {
-		return "", fmt.Errorf("failed to get current branch: %w", err)
+		return nil, fmt.Errorf("failed to create fixup commits: %w", err)
+	}
+	if len(fixups) == 0 {
+		return &FixupResult{Success: true, UnrelatedChanges: unrelated}, nil
 	}
-	return strings.TrimSpace(string(output)), nil
-}
 
-func (f *FixupManager) gitCheckoutBranch(branch string) error {
-	cmd := exec.Command(f.cfg.GitExecutable, "checkout", branch)
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
+	backupRef, rewrites, err := f.autosquash(ctx, rng, fixups[0].Target)
 	if err != nil {
-		return fmt.Errorf("git checkout failed: %w, output: %s", err, string(output))
+		return nil, err
 	}
-	return nil
+
+	last := fixups[len(fixups)-1]
+	return &FixupResult{
+		CommitHash:       last.Target,
+		FixupCommitHash:  last.Commit,
+		FilesModified:    len(paths),
+		Success:          true,
+		UnrelatedChanges: unrelated,
+		Fixups:           fixups,
+		BackupRef:        backupRef,
+		Rewrites:         rewrites,
+	}, nil
 }
 
-func (f *FixupManager) hasUncommittedChanges() (bool, error) {
-	cmd := exec.Command(f.cfg.GitExecutable, "status", "--porcelain")
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.Output()
+// autosquash は有効な場合に autosquash rebase を実行し、作成したバックアップ ref と書き換えの対応を返す。
+// 書き換え可能なコミットが無く rebase を行わなかった場合は空文字列を返す。
+func (f *FixupManager) autosquash(ctx context.Context, rng *fixupRange, oldestTarget string) (string, []rewrite.Entry, error) {
+	if !f.cfg.AutosquashEnabled {
+		return "", nil, nil
+	}
+
+	upstream, err := f.rebaseUpstream(ctx, rng, oldestTarget)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// 公開済みのコミットは書き換えないよう rebase の基点を進める。
+	upstream, ok, err := f.limitToUnpublished(ctx, upstream)
+	if err != nil {
+		return "", nil, err
+	}
+	if !ok {
+		return "", nil, nil
+	}
+
+	backupRef, rewrites, err := f.gitRebaseAutosquash(ctx, upstream)
 	if err != nil {
-		return false, fmt.Errorf("git status failed: %w", err)
+		return "", nil, metrics.WithCause(metrics.CauseAutosquash, fmt.Errorf("failed to perform autosquash rebase: %w", err))
 	}
 
-	return len(strings.TrimSpace(string(output))) > 0, nil
+	if err := f.recordRewrites(ctx, "autosquash", backupRef, rewrites); err != nil {
+		return backupRef, rewrites, err
+	}
+	return backupRef, rewrites, nil
+}
+
+// commitInfo は書き換え範囲内のコミットの情報。
+type commitInfo struct {
+	Hash    string
+	Parents []string
+	Time    time.Time
+	Subject string
+	// Sync は同期処理が作成したコミット（trailer 付き）かを表す。
+	Sync bool
 }
 
-func (f *FixupManager) getBaseCommit() (string, error) {
-	// 直前のコミットを取得する。
-	cmd := exec.Command(f.cfg.GitExecutable, "rev-parse", "HEAD~1")
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.Output()
+// unpublishedCommits は upstream..HEAD のうち、リモート追跡ブランチまたは保護対象の ref から
+// 到達できない（未公開の）コミットを古い順に返す。upstream が空の場合は HEAD の履歴全体を対象とする。
+func (f *FixupManager) unpublishedCommits(ctx context.Context, upstream string) ([]commitInfo, error) {
+	return f.unpublishedCommitsFrom(ctx, "HEAD", upstream)
+}
+
+// unpublishedCommitsFrom は unpublishedCommits を HEAD ではなく tip を終端として求める。
+func (f *FixupManager) unpublishedCommitsFrom(ctx context.Context, tip, upstream string) ([]commitInfo, error) {
+	patterns := append([]string{"refs/remotes/"}, f.cfg.ProtectedRefs...)
+	published, err := git.Lines(ctx, f.ops, append([]string{"for-each-ref", "--format=%(objectname)"}, patterns...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list published refs: %w", err)
+	}
+
+	var revs strings.Builder
+	revs.WriteString(tip + "\n")
+	if upstream != "" {
+		revs.WriteString("^" + upstream + "\n")
+	}
+	for _, commit := range published {
+		revs.WriteString("^" + commit + "\n")
+	}
+
+	format := "--format=%H%x1f%P%x1f%at%x1f%s%x1f%(trailers:key=" + fcsync.CommitTrailer + ",valueonly)"
+	output, err := f.ops.RunWithOptions(ctx, git.RunOptions{Stdin: strings.NewReader(revs.String())},
+		"log", "--stdin", "-z", "--topo-order", "--reverse", format)
 	if err != nil {
-		// HEAD~1が存在しない場合（初回コミット）はHEADを使用。
-		cmd = exec.Command(f.cfg.GitExecutable, "rev-parse", "HEAD")
-		cmd.Dir = f.cfg.OpsRepoPath
-		output, err = cmd.Output()
+		return nil, fmt.Errorf("failed to list unpublished commits: %w", err)
+	}
+
+	var commits []commitInfo
+	for _, record := range git.SplitNUL(output) {
+		fields := strings.SplitN(record, "\x1f", 5)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("malformed log entry: %q", record)
+		}
+		seconds, err := strconv.ParseInt(fields[2], 10, 64)
 		if err != nil {
-			return "", fmt.Errorf("failed to get base commit: %w", err)
+			return nil, fmt.Errorf("malformed commit time: %q", fields[2])
 		}
+		commits = append(commits, commitInfo{
+			Hash:    fields[0],
+			Parents: strings.Fields(fields[1]),
+			Time:    time.Unix(seconds, 0),
+			Subject: fields[3],
+			Sync:    strings.TrimSpace(fields[4]) != "",
+		})
 	}
 
-	return strings.TrimSpace(string(output)), nil
+	return commits, nil
 }
 
-func (f *FixupManager) getModifiedFilesCount() (int, error) {
-	// ステージされた変更とワーキングディレクトリの変更の両方をチェック。
-	cmd := exec.Command(f.cfg.GitExecutable, "diff", "--name-only", "HEAD")
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.Output()
+// limitToUnpublished は upstream..HEAD から公開済みのコミットを除いた範囲の基点を返す。
+// 書き換え対象となるコミット（fixup 以外のコミット）が残らない場合は false を返す。
+func (f *FixupManager) limitToUnpublished(ctx context.Context, upstream string) (string, bool, error) {
+	commits, err := f.unpublishedCommits(ctx, upstream)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get modified files: %w", err)
+		return "", false, err
+	}
+	if len(commits) == 0 {
+		return upstream, false, nil
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) == 1 && lines[0] == "" {
-		return 0, nil
+	hasTarget := false
+	for _, commit := range commits {
+		if !isFixupSubject(commit.Subject) {
+			hasTarget = true
+		}
 	}
 
-	return len(lines), nil
+	// 最も古い未公開コミットの親が公開済み範囲の終端となる。
+	return firstParent(commits[0]), hasTarget, nil
 }
 
-func (f *FixupManager) gitAddAll() error {
-	cmd := exec.Command(f.cfg.GitExecutable, "add", ".")
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git add failed: %w, output: %s", err, string(output))
+// firstParent はコミットの最初の親を返す。ルートコミットの場合は空文字列を返す。
+func firstParent(commit commitInfo) string {
+	if len(commit.Parents) == 0 {
+		return ""
 	}
-	return nil
+	return commit.Parents[0]
+}
+
+// isFixupSubject は autosquash で他のコミットに統合されるコミットのサブジェクトかを返す。
+func isFixupSubject(subject string) bool {
+	for _, prefix := range []string{"fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(subject, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
-func (f *FixupManager) gitFixupCommit(baseCommit string) (string, error) {
-	fixupTarget := fmt.Sprintf("%s", baseCommit)
-	commitMsg := f.generateFixupMessage(baseCommit)
+// fixupRange は fixup の対象とし、autosquash で書き換えてよいコミットの範囲を表す。
+type fixupRange struct {
+	// Upstream は設定された upstream。未設定の場合は空で、範囲は HEAD から辿れる履歴全体となる。
+	Upstream string
+	// MergeBase は HEAD と Upstream のマージベース。範囲はこのコミットを含まない。
+	MergeBase string
+}
 
-	args := []string{"commit", "--fixup", fixupTarget, "-m", commitMsg}
+// revision は範囲内のコミットを列挙するための rev-list 引数を返す。
+func (r *fixupRange) revision() string {
+	if r.MergeBase == "" {
+		return "HEAD"
+	}
+	return r.MergeBase + "..HEAD"
+}
 
-	if f.cfg.AuthorName != "" && f.cfg.AuthorEmail != "" {
-		author := fmt.Sprintf("%s <%s>", f.cfg.AuthorName, f.cfg.AuthorEmail)
-		args = append(args, "--author", author)
+// resolveRange はブランチに設定された upstream とのマージベースから fixup の範囲を求める。
+func (f *FixupManager) resolveRange(ctx context.Context, branch string) (*fixupRange, error) {
+	return f.resolveRangeAt(ctx, branch, "HEAD")
+}
+
+// resolveRangeAt は resolveRange を HEAD ではなく tip を終端として求める。
+func (f *FixupManager) resolveRangeAt(ctx context.Context, branch, tip string) (*fixupRange, error) {
+	upstream := f.cfg.GetFixupUpstream(branch)
+	if upstream == "" {
+		return &fixupRange{}, nil
 	}
 
-	cmd := exec.Command(f.cfg.GitExecutable, args...)
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
+	found, err := git.Succeeds(ctx, f.ops, "rev-parse", "--verify", "--quiet", upstream+"^{commit}")
 	if err != nil {
-		return "", fmt.Errorf("git fixup commit failed: %w, output: %s", err, string(output))
+		return nil, fmt.Errorf("failed to resolve upstream %s: %w", upstream, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("upstream %s not found in ops repository", upstream)
 	}
 
-	return f.getLastCommitHash()
+	mergeBase, err := git.Output(ctx, f.ops, "merge-base", tip, upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find merge-base with %s: %w", upstream, err)
+	}
+
+	return &fixupRange{Upstream: upstream, MergeBase: mergeBase}, nil
+}
+
+// rebaseUpstream は autosquash rebase の基点を返す。
+// upstream が設定されていればマージベース、そうでなければ最も古い対象コミットの親を使う。
+func (f *FixupManager) rebaseUpstream(ctx context.Context, rng *fixupRange, oldestTarget string) (string, error) {
+	if rng.MergeBase != "" {
+		return rng.MergeBase, nil
+	}
+	return f.parentOf(ctx, oldestTarget)
 }
 
-func (f *FixupManager) gitRebaseAutosquash() error {
-	baseCommit, err := f.getBaseCommit()
+// parentOf は commit の親を返す。ルートコミットの場合は空文字列を返す。
+func (f *FixupManager) parentOf(ctx context.Context, commit string) (string, error) {
+	hasParent, err := git.Succeeds(ctx, f.ops, "rev-parse", "--verify", "--quiet", commit+"^")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve parent of %s: %w", commit, err)
+	}
+	if !hasParent {
+		return "", nil
+	}
+	parent, err := git.Output(ctx, f.ops, "rev-parse", commit+"^")
 	if err != nil {
-		return fmt.Errorf("failed to get base commit for rebase: %w", err)
+		return "", fmt.Errorf("failed to resolve parent of %s: %w", commit, err)
 	}
+	return parent, nil
+}
 
-	cmd := exec.Command(f.cfg.GitExecutable, "rebase", "--autosquash", "--interactive", baseCommit)
-	cmd.Dir = f.cfg.OpsRepoPath
-	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+// Probe は fixup を試さずに、Ops リポジトリが参照できるか（マウントが外れていないかなど）を確認する。
+func (f *FixupManager) Probe(ctx context.Context) error {
+	if err := f.validateRepository(); err != nil {
+		return err
+	}
+	_, err := f.ops.Run(ctx, "rev-parse", "--git-dir")
+	return err
+}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git rebase autosquash failed: %w, output: %s", err, string(output))
+func (f *FixupManager) validateRepository() error {
+	opsGitDir := filepath.Join(f.cfg.OpsRepoPath, ".git")
+	if _, err := os.Stat(opsGitDir); err != nil {
+		return fmt.Errorf("ops repository .git directory not found: %s", opsGitDir)
 	}
 
+	// 動的ブランチ追従により、ensureOnTargetBranchは不要になった。
+
 	return nil
 }
 
-func (f *FixupManager) getLastCommitHash() (string, error) {
-	cmd := exec.Command(f.cfg.GitExecutable, "rev-parse", "HEAD")
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.Output()
+// ensureOnTargetBranch は動的ブランチ追従により削除。
+
+func (f *FixupManager) getCurrentBranch(ctx context.Context) (string, error) {
+	branch, err := git.Output(ctx, f.ops, "branch", "--show-current")
 	if err != nil {
-		return "", fmt.Errorf("failed to get commit hash: %w", err)
+		return "", fmt.Errorf("failed to get current branch: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return branch, nil
 }
 
-func (f *FixupManager) generateFixupMessage(baseCommit string) string {
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+// collectChanges は Ops 側の未コミット変更を同期対象のパスとそれ以外に分類する。
+func (f *FixupManager) collectChanges(ctx context.Context) ([]string, []string, error) {
+	entries, err := git.Status(ctx, f.ops, "--untracked-files=all")
+	if err != nil {
+		return nil, nil, fmt.Errorf("git status failed: %w", err)
+	}
 
-	message := f.cfg.FixupMsgPrefix + "Automated fixup"
-	if baseCommit != "" {
-		message += fmt.Sprintf(" for %s", baseCommit[:8])
+	filter := pathfilter.New(f.cfg)
+	var paths, unrelated []string
+	for _, entry := range entries {
+		if filter.Managed(entry.Path) {
+			paths = append(paths, entry.Path)
+			// リネームの元パスも合わせてステージしないと削除側が取り残される。
+			if entry.OrigPath != "" {
+				paths = append(paths, entry.OrigPath)
+			}
+		} else {
+			unrelated = append(unrelated, entry.Path)
+		}
 	}
-	message += fmt.Sprintf(" @ %s", timestamp)
 
-	return message
+	return paths, unrelated, nil
 }
 
-// getDevCurrentBranch はDev側のカレントブランチを取得する。
-func (f *FixupManager) getDevCurrentBranch() (string, error) {
-	cmd := exec.Command(f.cfg.GitExecutable, "branch", "--show-current")
-	cmd.Dir = f.cfg.DevRepoPath
-	output, err := cmd.Output()
+// getBaseCommit は fixup の対象とする直前のコミット（HEAD~1）を返す。
+// HEAD~1 が範囲外（初回コミットやマージベース）の場合は HEAD を使用する。
+func (f *FixupManager) getBaseCommit(ctx context.Context, rng *fixupRange) (string, error) {
+	commits, err := git.Lines(ctx, f.ops, "rev-list", "--first-parent", "--max-count=2", rng.revision())
 	if err != nil {
-		return "", fmt.Errorf("failed to get current branch from dev repo: %w", err)
+		return "", fmt.Errorf("failed to get base commit: %w", err)
+	}
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commits to fix up between %s and HEAD", rng.Upstream)
+	}
+
+	return commits[len(commits)-1], nil
+}
+
+// gitAddPaths は同期対象のパスのみをステージする。
+func (f *FixupManager) gitAddPaths(ctx context.Context, paths []string) error {
+	if err := git.AddPaths(ctx, f.ops, paths); err != nil {
+		return fmt.Errorf("git add failed: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return nil
 }
 
-// ensureOpsBranch はOps側を指定されたブランチに切り替える。
-func (f *FixupManager) ensureOpsBranch(targetBranch string) error {
-	originalDir, err := os.Getwd()
+func (f *FixupManager) gitFixupCommit(ctx context.Context, baseCommit string, paths []string) (string, error) {
+	commitMsg, err := f.fixupMessage(ctx, baseCommit)
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+		return "", err
+	}
+
+	args := []string{"-m", commitMsg}
+
+	if f.cfg.AuthorName != "" && f.cfg.AuthorEmail != "" {
+		author := fmt.Sprintf("%s <%s>", f.cfg.AuthorName, f.cfg.AuthorEmail)
+		args = append(args, "--author", author)
+	}
+
+	if err := git.CommitPaths(ctx, f.ops, paths, args...); err != nil {
+		return "", fmt.Errorf("git fixup commit failed: %w", err)
 	}
-	defer os.Chdir(originalDir)
 
-	if err := os.Chdir(f.cfg.OpsRepoPath); err != nil {
-		return fmt.Errorf("failed to change to ops repository: %w", err)
+	return f.getLastCommitHash(ctx)
+}
+
+// gitRebaseAutosquash は upstream 以降のコミットを autosquash で rebase する。
+// upstream が空の場合はルートコミットから rebase する。
+// rebase 前の状態はバックアップ ref に保存し、失敗時は rebase を中止してその状態に戻す。
+// 作成したバックアップ ref を返す。
+func (f *FixupManager) gitRebaseAutosquash(ctx context.Context, upstream string) (string, []rewrite.Entry, error) {
+	branch, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get current branch: %w", err)
 	}
 
-	// 現在のブランチを確認。
-	currentBranch, err := f.getCurrentBranch()
+	backupRef, err := backup.NewManager(f.cfg, f.ops).Create(ctx, branch)
 	if err != nil {
-		return fmt.Errorf("failed to get current ops branch: %w", err)
+		return "", nil, err
 	}
 
-	// 既に目的のブランチにいる場合はスキップ。
-	if currentBranch == targetBranch {
-		return nil
+	todoPath, err := f.stateFilePath(ctx, "fcsm/rebase-todo")
+	if err != nil {
+		return backupRef, nil, err
+	}
+	defer os.Remove(todoPath)
+
+	// 同期対象外の未コミット変更が残っていても rebase できるよう一時的に退避する。
+	// 利用者の設定で他のブランチを書き換えたり（rebase.updateRefs）、マージを保持したり（rebase.rebaseMerges）
+	// しないよう、todo がコミットの取り込みのみで構成される設定に固定する。
+	args := []string{"-c", "rebase.updateRefs=false", "-c", "rebase.rebaseMerges=false",
+		"rebase", "--autosquash", "--interactive", "--autostash"}
+	if upstream == "" {
+		args = append(args, "--root")
+	} else {
+		args = append(args, upstream)
+	}
+
+	// git が作成した todo をそのまま受け入れつつ、書き換えの対応を求めるために写しを残す。
+	opts := git.RunOptions{Env: []string{"GIT_EDITOR=true", "GIT_SEQUENCE_EDITOR=" + copyEditor(todoPath)}}
+	if _, err := f.ops.RunWithOptions(ctx, opts, args...); err != nil {
+		if restoreErr := f.abortRebase(ctx, backupRef); restoreErr != nil {
+			return backupRef, nil, fmt.Errorf("git rebase autosquash failed: %v (restoring %s also failed: %v)", err, backupRef, restoreErr)
+		}
+		return backupRef, nil, fmt.Errorf("git rebase autosquash failed, restored %s: %w", backupRef, err)
 	}
 
-	// ブランチの存在確認。
-	if err := f.ensureBranchExists(targetBranch); err != nil {
-		return fmt.Errorf("failed to ensure branch exists: %w", err)
+	// rebase は完了しているため、ctx が終了していても書き換えの対応は求める。
+	// 対応を求められなくても履歴は書き換わっているため失敗とはせず、対応の無い書き換えとしてバックアップ ref を記録する。
+	rewrites, err := f.rebaseRewrites(context.WithoutCancel(ctx), todoPath, upstream)
+	if err != nil {
+		fmt.Printf("Warning: autosquash rebase completed but the rewritten commits could not be determined (backup: %s): %v\n", backupRef, err)
+		return backupRef, nil, nil
 	}
+	return backupRef, rewrites, nil
+}
 
-	// ブランチ切り替え。
-	if err := f.gitCheckoutBranch(targetBranch); err != nil {
-		return fmt.Errorf("failed to checkout branch %s: %w", targetBranch, err)
+// abortRebase は中断した rebase を中止し、HEAD をバックアップ ref の状態に戻す。
+// 中断（ctx の終了）による失敗からも復元できるよう、ctx が終了していても実行する。
+func (f *FixupManager) abortRebase(ctx context.Context, backupRef string) error {
+	ctx = context.WithoutCancel(ctx)
+
+	// rebase が開始前に失敗した場合は中止する対象が無いため、エラーは無視する。
+	f.ops.Run(ctx, "rebase", "--abort")
+
+	expected, err := git.Output(ctx, f.ops, "rev-parse", backupRef)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", backupRef, err)
+	}
+	head, err := git.Output(ctx, f.ops, "rev-parse", "HEAD")
+	if err == nil && head == expected {
+		return nil
 	}
 
+	if _, err := f.ops.Run(ctx, "reset", "--keep", expected); err != nil {
+		return fmt.Errorf("failed to reset to %s: %w", backupRef, err)
+	}
 	return nil
 }
 
-// ensureBranchExists は指定されたブランチが存在することを確認し、必要に応じて作成する。
-func (f *FixupManager) ensureBranchExists(branchName string) error {
-	// ローカルブランチの存在確認。
-	cmd := exec.Command(f.cfg.GitExecutable, "show-ref", "--verify", "--quiet", "refs/heads/"+branchName)
-	cmd.Dir = f.cfg.OpsRepoPath
-	if err := cmd.Run(); err == nil {
-		return nil // ブランチが存在する。
+func (f *FixupManager) getLastCommitHash(ctx context.Context) (string, error) {
+	hash, err := git.Output(ctx, f.ops, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit hash: %w", err)
 	}
+	return hash, nil
+}
 
-	// リモートブランチの存在確認。
-	cmd = exec.Command(f.cfg.GitExecutable, "show-ref", "--verify", "--quiet", "refs/remotes/origin/"+branchName)
-	cmd.Dir = f.cfg.OpsRepoPath
-	if err := cmd.Run(); err == nil {
-		// リモートブランチから作成。
-		return f.gitCreateBranchFromRemote(branchName)
+func (f *FixupManager) generateFixupMessage(baseCommit string) string {
+	timestamp := time.Now().Format("2006-01-02 15:04:05")
+
+	message := f.cfg.FixupMsgPrefix + "Automated fixup"
+	if baseCommit != "" {
+		message += fmt.Sprintf(" for %s", baseCommit[:8])
 	}
+	message += fmt.Sprintf(" @ %s", timestamp)
 
-	// ブランチが存在しない場合は新規作成。
-	return f.gitCreateBranch(branchName)
+	return message
 }
 
-// gitCreateBranch は新しいブランチを作成する。
-func (f *FixupManager) gitCreateBranch(branchName string) error {
-	cmd := exec.Command(f.cfg.GitExecutable, "checkout", "-b", branchName)
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
+// getDevCurrentBranch はDev側のカレントブランチを取得する。
+func (f *FixupManager) getDevCurrentBranch(ctx context.Context) (string, error) {
+	branch, err := git.Output(ctx, f.dev, "branch", "--show-current")
 	if err != nil {
-		return fmt.Errorf("failed to create branch %s: %w, output: %s", branchName, err, string(output))
+		return "", fmt.Errorf("failed to get current branch from dev repo: %w", err)
 	}
-	return nil
+	return branch, nil
 }
 
-// gitCreateBranchFromRemote はリモートブランチから新しいローカルブランチを作成する。
-func (f *FixupManager) gitCreateBranchFromRemote(branchName string) error {
-	cmd := exec.Command(f.cfg.GitExecutable, "checkout", "-b", branchName, "origin/"+branchName)
-	cmd.Dir = f.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to create branch %s from remote: %w, output: %s", branchName, err, string(output))
-	}
-	return nil
+// ensureOpsBranch はOps側を指定されたブランチに切り替える。
+// 切り替え前のブランチの未コミット作業は退避し、切り替え先で以前退避した作業があれば復元する。
+func (f *FixupManager) ensureOpsBranch(ctx context.Context, targetBranch string) error {
+	return autostash.NewStasher(f.ops).Switch(ctx, targetBranch)
 }
 
-func (f *FixupManager) RunContinuousFixup() error {
+// RunContinuousFixup は ctx が終了するまで設定の間隔で fixup を繰り返す。
+// 実行中の fixup は ctx の終了で中断し、rebase 中であればバックアップから元の状態に戻してから返る。
+// reloads から新しい設定を受け取ると、fixup の合間に切り替えて間隔を設定し直す。
+func (f *FixupManager) RunContinuousFixup(ctx context.Context, reloads <-chan *config.Config) error {
 	interval, err := f.cfg.GetFixupIntervalDuration()
 	if err != nil {
 		return fmt.Errorf("invalid fixup interval: %w", err)
@@ -344,11 +673,37 @@ func (f *FixupManager) RunContinuousFixup() error {
 	fmt.Println("Using dynamic branch tracking from Dev repository")
 	fmt.Println("Press Ctrl+C to stop")
 
+	breaker := retry.NewBreaker(f.cfg, "fixup", interval)
+	breaker.Probe = f.Probe
+
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-ctx.Done():
+			fmt.Println("Stopping continuous fixup")
+			return nil
+
+		case next, ok := <-reloads:
+			if !ok {
+				reloads = nil
+				continue
+			}
+			reloaded := NewFixupManager(next)
+			f.cfg, f.dev, f.ops = reloaded.cfg, reloaded.dev, reloaded.ops
+
+			nextInterval, _ := f.cfg.GetFixupIntervalDuration()
+			nextBreaker := retry.NewBreaker(f.cfg, "fixup", nextInterval)
+			nextBreaker.Probe = f.Probe
+			nextBreaker.Inherit(breaker)
+			breaker = nextBreaker
+			if nextInterval != interval {
+				interval = nextInterval
+				ticker.Reset(breaker.Delay())
+			}
+			fmt.Printf("[%s] Configuration reloaded (interval: %v)\n", time.Now().Format("15:04:05"), interval)
+
 		case <-ticker.C:
 			if f.cfg.Verbose {
 				fmt.Printf("\n[%s] Starting fixup operation...\n", time.Now().Format("15:04:05"))
@@ -359,9 +714,27 @@ func (f *FixupManager) RunContinuousFixup() error {
 				continue
 			}
 
-			result, err := f.RunFixup()
+			var result *FixupResult
+			err := breaker.Do(ctx, func(ctx context.Context) error {
+				if f.cfg.FixupAllBranches {
+					return f.runAllBranchesOnce(ctx)
+				}
+				var err error
+				result, err = f.RunFixup(ctx)
+				return err
+			})
+			// 失敗が続く間は間隔を延ばし、回路が開いている間は確認の間隔で待つ。
+			delay := breaker.Delay()
+			ticker.Reset(delay)
+			if errors.Is(err, retry.ErrOpen) {
+				fmt.Printf("[%s] Fixup skipped: %v (next probe in %v)\n", time.Now().Format("15:04:05"), err, delay.Round(time.Second))
+				continue
+			}
 			if err != nil {
-				fmt.Printf("[%s] Fixup failed: %v\n", time.Now().Format("15:04:05"), err)
+				fmt.Printf("[%s] Fixup failed: %v (next attempt in %v)\n", time.Now().Format("15:04:05"), err, delay.Round(time.Second))
+				continue
+			}
+			if result == nil {
 				continue
 			}
 
@@ -382,3 +755,19 @@ func (f *FixupManager) RunContinuousFixup() error {
 		}
 	}
 }
+
+// runAllBranchesOnce は継続実行の 1 回分として全ブランチの fixup を行い、ブランチごとの結果を表示して RunAllBranches のエラーを返す。
+func (f *FixupManager) runAllBranchesOnce(ctx context.Context) error {
+	results, err := f.RunAllBranches(ctx)
+	for _, branch := range results {
+		switch {
+		case branch.Err != nil:
+			fmt.Printf("[%s] ✗ %s: %v\n", time.Now().Format("15:04:05"), branch.Branch, branch.Err)
+		case branch.Result.FilesModified > 0:
+			fmt.Printf("[%s] ✓ %s: %d files modified\n", time.Now().Format("15:04:05"), branch.Branch, branch.Result.FilesModified)
+		case branch.Result.PendingFixups > 0:
+			fmt.Printf("[%s] ✓ %s: squashed %d pending fixup commits\n", time.Now().Format("15:04:05"), branch.Branch, branch.Result.PendingFixups)
+		}
+	}
+	return err
+}
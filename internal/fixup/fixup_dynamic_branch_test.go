@@ -1,6 +1,7 @@
 package fixup
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -36,7 +37,7 @@ func TestGetDevCurrentBranchFixup(t *testing.T) {
 
 	manager := NewFixupManager(cfg)
 	
-	branch, err := manager.getDevCurrentBranch()
+	branch, err := manager.getDevCurrentBranch(context.Background())
 	if err != nil {
 		t.Fatalf("getDevCurrentBranch() failed: %v", err)
 	}
@@ -67,13 +68,13 @@ func TestEnsureOpsBranchFixup(t *testing.T) {
 	manager := NewFixupManager(cfg)
 	
 	// 新しいブランチに切り替えテスト。
-	err := manager.ensureOpsBranch("feature-fixup-new")
+	err := manager.ensureOpsBranch(context.Background(), "feature-fixup-new")
 	if err != nil {
 		t.Fatalf("ensureOpsBranch() failed: %v", err)
 	}
 
 	// 現在のブランチを確認。
-	currentBranch, err := manager.getCurrentBranch()
+	currentBranch, err := manager.getCurrentBranch(context.Background())
 	if err != nil {
 		t.Fatalf("getCurrentBranch() failed: %v", err)
 	}
@@ -152,7 +153,7 @@ func TestDynamicBranchFixupFlow(t *testing.T) {
 	manager := NewFixupManager(cfg)
 	
 	// 動的ブランチ追従付きのfixupを実行。
-	result, err := manager.RunFixup()
+	result, err := manager.RunFixup(context.Background())
 	if err != nil {
 		t.Fatalf("RunFixup() failed: %v", err)
 	}
@@ -162,7 +163,7 @@ func TestDynamicBranchFixupFlow(t *testing.T) {
 	}
 
 	// Ops側のブランチを確認。
-	currentBranch, err := manager.getCurrentBranch()
+	currentBranch, err := manager.getCurrentBranch(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to get ops current branch: %v", err)
 	}
@@ -177,6 +178,69 @@ func TestDynamicBranchFixupFlow(t *testing.T) {
 	}
 }
 
+func TestFixupCommitsOnlyManagedPaths(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping managed paths fixup test")
+	}
+
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryFixup(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryFixup(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(opsRepo, "main.cpp"), []byte("// v1"), 0644)
+	for _, args := range [][]string{{"git", "add", "main.cpp"}, {"git", "commit", "-m", "Add main"}} {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = opsRepo
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("Failed to run %v: %v", args, err)
+		}
+	}
+
+	os.WriteFile(filepath.Join(opsRepo, "main.cpp"), []byte("// v2"), 0644)
+	os.WriteFile(filepath.Join(opsRepo, "notes.txt"), []byte("memo"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		IncludeExtensions: []string{".cpp"},
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+
+	if result.FilesModified != 1 {
+		t.Errorf("Expected 1 modified file, got %d", result.FilesModified)
+	}
+	if len(result.UnrelatedChanges) != 1 || result.UnrelatedChanges[0] != "notes.txt" {
+		t.Errorf("Expected notes.txt to be reported as unrelated, got %q", result.UnrelatedChanges)
+	}
+
+	cmd := exec.Command("git", "show", "--name-only", "--format=", "HEAD")
+	cmd.Dir = opsRepo
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git show failed: %v", err)
+	}
+	if string(output) != "main.cpp\n" {
+		t.Errorf("Fixup commit should contain only main.cpp, got: %q", output)
+	}
+
+	if _, err := os.Stat(filepath.Join(opsRepo, "notes.txt")); err != nil {
+		t.Errorf("Unrelated file should be left in the working tree: %v", err)
+	}
+}
+
 func createTestRepositoryFixup(repoPath string) error {
 	if err := os.MkdirAll(repoPath, 0755); err != nil {
 		return err
@@ -1,12 +1,18 @@
 package fixup
 
 import (
+	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/metrics"
+	"fixup-commit-sync-manager/internal/repolock"
 )
 
 func TestNewFixupManager(t *testing.T) {
@@ -100,43 +106,42 @@ func TestValidateRepositoryMissingGit(t *testing.T) {
 	}
 }
 
-func TestGetModifiedFilesCount(t *testing.T) {
+// TestLockLost は処理中に Ops リポジトリのロックを失った場合、中断ではなくロックの失敗として扱うことをテストする。
+func TestLockLost(t *testing.T) {
 	if !isGitAvailable() {
 		t.Skip("Git not available, skipping test")
 	}
 
-	tempDir := t.TempDir()
-	opsRepo := filepath.Join(tempDir, "ops")
-
+	opsRepo := filepath.Join(t.TempDir(), "ops")
 	if err := createTestRepository(opsRepo); err != nil {
-		t.Fatalf("Failed to create test repository: %v", err)
+		t.Fatalf("Failed to create ops repository: %v", err)
 	}
-
-	testFile := filepath.Join(opsRepo, "test.txt")
-	os.WriteFile(testFile, []byte("test content"), 0644)
-
-	cfg := &config.Config{
-		OpsRepoPath:   opsRepo,
-		GitExecutable: "git",
+	cfg := &config.Config{OpsRepoPath: opsRepo, RepoLockStaleAfter: "200ms"}
+	lock, err := repolock.NewLocker(cfg, git.NewRunner("git", opsRepo)).Acquire(context.Background(), "fixup")
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
 	}
+	defer lock.Release()
 
-	manager := NewFixupManager(cfg)
-
-	originalDir, _ := os.Getwd()
-	defer os.Chdir(originalDir)
-	os.Chdir(opsRepo)
-
-	cmd := exec.Command("git", "add", "test.txt")
-	cmd.Dir = opsRepo
-	cmd.Run()
+	if err := lockLost(lock, context.Canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("lockLost() should keep the error while holding the lock, got %v", err)
+	}
 
-	count, err := manager.getModifiedFilesCount()
-	if err != nil {
-		t.Errorf("getModifiedFilesCount() failed: %v", err)
+	// 他のプロセスがロックを引き継ぐと、ロックを保持して行う処理の context が終了する。
+	lockFile := filepath.Join(opsRepo, ".git", repolock.LockFile)
+	os.WriteFile(lockFile, []byte(`{"pid":1,"host":"other-host","operation":"sync","token":"other"}`), 0644)
+	select {
+	case <-lock.Context().Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Lock context should be canceled after the lock is taken over")
 	}
 
-	if count == 0 {
-		t.Error("Should detect staged files")
+	err = lockLost(lock, lock.Context().Err())
+	if !errors.Is(err, repolock.ErrLost) || metrics.Cause(err) != metrics.CauseLock {
+		t.Errorf("lockLost() = %v (cause %s), want a lock failure", err, metrics.Cause(err))
+	}
+	if lockLost(lock, nil) != nil {
+		t.Error("lockLost(nil) should be nil")
 	}
 }
 
@@ -0,0 +1,60 @@
+package fixup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fixup-commit-sync-manager/internal/history"
+	"fixup-commit-sync-manager/internal/metrics"
+)
+
+// recordHistory は started から始まった fixup の結果をブランチごとに Dev リポジトリの実行履歴に記録する。
+// 何も変更しなかったブランチもファイルとコミットを空として記録する。
+// 処理したブランチが無い場合や、ブランチを処理する前に失敗した場合は、その回の結果を 1 件記録する。
+// 中断した回も記録できるよう、ctx の終了を待たずに記録する。
+func (f *FixupManager) recordHistory(ctx context.Context, started time.Time, branches []BranchResult, err error) {
+	ctx = context.WithoutCancel(ctx)
+	duration := time.Since(started)
+	log := history.NewLog(f.dev)
+
+	var entries []history.Entry
+	for _, branch := range branches {
+		entries = append(entries, historyEntry(started, duration, branch.Branch, branch.Result, branch.Err))
+	}
+	if len(entries) == 0 {
+		entries = append(entries, historyEntry(started, duration, "", nil, err))
+	}
+
+	for _, entry := range entries {
+		if err := log.Append(ctx, entry); err != nil {
+			fmt.Printf("Warning: failed to record fixup history: %v\n", err)
+			return
+		}
+	}
+}
+
+func historyEntry(started time.Time, duration time.Duration, branch string, result *FixupResult, err error) history.Entry {
+	entry := history.Entry{Time: started, Type: history.TypeFixup, Duration: duration, Branch: branch}
+	if result != nil {
+		for _, fixup := range result.Fixups {
+			entry.Files = append(entry.Files, fixup.Files...)
+			entry.Commits = append(entry.Commits, fixup.Commit)
+		}
+		entry.BackupRef = result.BackupRef
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Cause = metrics.Cause(err)
+	}
+	return entry
+}
+
+// devBranch は履歴に記録する Dev のカレントブランチを返す。取得できない場合は空文字列。
+func (f *FixupManager) devBranch(ctx context.Context) string {
+	branch, err := f.getDevCurrentBranch(context.WithoutCancel(ctx))
+	if err != nil {
+		return ""
+	}
+	return branch
+}
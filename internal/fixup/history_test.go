@@ -0,0 +1,63 @@
+package fixup
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
+)
+
+func TestRecordHistory(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping history test")
+	}
+
+	devRepo := t.TempDir()
+	if err := exec.Command("git", "init", devRepo).Run(); err != nil {
+		t.Fatalf("git init failed: %v", err)
+	}
+	cfg := &config.Config{DevRepoPath: devRepo, OpsRepoPath: t.TempDir(), GitExecutable: "git"}
+	f := NewFixupManager(cfg)
+
+	started := time.Now()
+	f.recordHistory(context.Background(), started, []BranchResult{
+		{Branch: "idle", Result: &FixupResult{}},
+		{Branch: "feature", Result: &FixupResult{
+			Fixups:    []FixupCommit{{Target: "a1", Commit: "f1", Files: []string{"a.cpp", "b.cpp"}}},
+			BackupRef: "refs/fcsm/backup/feature/x",
+		}},
+		{Branch: "broken", Err: errors.New("rebase failed")},
+	}, nil)
+	// ブランチを処理する前の失敗は 1 件として記録する。
+	f.recordHistory(context.Background(), started, nil, errors.New("ops repository is locked"))
+	// 処理するブランチが無かった回も 1 件として記録する。
+	f.recordHistory(context.Background(), started, nil, nil)
+
+	entries, err := history.NewLog(git.NewRunner("git", devRepo)).Read(context.Background(), history.Filter{Type: history.TypeFixup})
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("Expected entries for idle, feature, broken, the lock failure and the idle cycle, got %+v", entries)
+	}
+	if e := entries[0]; e.Branch != "idle" || len(e.Files) != 0 || len(e.Commits) != 0 || e.Failed() {
+		t.Errorf("Unexpected entry for idle: %+v", e)
+	}
+	if e := entries[1]; e.Branch != "feature" || len(e.Files) != 2 || e.Commits[0] != "f1" || e.BackupRef == "" || e.Failed() {
+		t.Errorf("Unexpected entry for feature: %+v", e)
+	}
+	if e := entries[2]; e.Branch != "broken" || e.Error != "rebase failed" {
+		t.Errorf("Unexpected entry for broken: %+v", e)
+	}
+	if e := entries[3]; e.Branch != "" || e.Error != "ops repository is locked" {
+		t.Errorf("Unexpected entry for the lock failure: %+v", e)
+	}
+	if e := entries[4]; e.Branch != "" || e.Failed() || len(e.Commits) != 0 {
+		t.Errorf("Unexpected entry for the idle cycle: %+v", e)
+	}
+}
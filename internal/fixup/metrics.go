@@ -0,0 +1,37 @@
+package fixup
+
+import (
+	"time"
+
+	"fixup-commit-sync-manager/internal/metrics"
+)
+
+// observeFixup は started から始まった 1 回の fixup の結果をメトリクスに記録する。
+// results は処理したブランチごとの結果で、失敗したブランチの結果は nil となる。
+func observeFixup(started time.Time, results []*FixupResult, err error) {
+	cycle := metrics.FixupCycle{
+		Duration: time.Since(started),
+		Rewrites: make(map[string]int),
+		Err:      err,
+	}
+
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		cycle.Files += result.FilesModified
+		cycle.RewrittenCommits += len(result.Rewrites)
+
+		if result.Compaction != nil && len(result.Compaction.Rewrites) > 0 {
+			cycle.Rewrites["compaction"]++
+		}
+		switch {
+		case result.Amended:
+			cycle.Rewrites["amend"]++
+		case result.BackupRef != "":
+			cycle.Rewrites["autosquash"]++
+		}
+	}
+
+	metrics.ObserveFixup(cycle)
+}
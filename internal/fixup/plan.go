@@ -0,0 +1,356 @@
+package fixup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// FixupPlan は fixup を実行した場合に行われる操作の見積もり。作成時にリポジトリは変更しない。
+type FixupPlan struct {
+	// Branch は fixup を行うブランチ（Dev のカレントブランチ）。
+	Branch string `json:"branch"`
+	// CurrentBranch は Ops の現在のブランチ。Branch と異なる場合、実行時に切り替わる。計画は現在のブランチに対して作成する。
+	CurrentBranch string `json:"currentBranch"`
+	Upstream      string `json:"upstream,omitempty"`
+	FixupTarget   string `json:"fixupTarget"`
+	FixupStrategy string `json:"fixupStrategy"`
+	// AmendHead は amend-last 戦略で HEAD を直接 amend することを表す。
+	AmendHead        bool                `json:"amendHead,omitempty"`
+	Files            []string            `json:"files"`
+	UnrelatedChanges []string            `json:"unrelatedChanges,omitempty"`
+	Compactions      []PlannedCompaction `json:"compactions,omitempty"`
+	// Approximate は Compactions の圧縮が fixup より先に行われるのに対し、Fixups 以降を圧縮前のコミットから求めたことを表す。
+	// 実行時は圧縮後のコミットが対象となるため、対象のハッシュや todo、書き換わるコミットは計画と異なる。
+	Approximate bool           `json:"approximate,omitempty"`
+	Fixups      []PlannedFixup `json:"fixups"`
+	Autosquash  bool           `json:"autosquash"`
+	// AutosquashSkipped は autosquash rebase を行わない理由。rebase する場合は空。
+	AutosquashSkipped string `json:"autosquashSkipped,omitempty"`
+	// RebaseOnto は autosquash rebase の基点。空で Todo がある場合はルートから rebase する。
+	RebaseOnto string      `json:"rebaseOnto,omitempty"`
+	Todo       []TodoEntry `json:"todo,omitempty"`
+	// Rewritten は autosquash rebase によって新しいハッシュになるコミット。
+	Rewritten []PlannedCommit `json:"rewritten,omitempty"`
+}
+
+// PlannedFixup は作成される fixup コミット一件分の計画。
+type PlannedFixup struct {
+	Target  string   `json:"target"`
+	Subject string   `json:"subject"`
+	Files   []string `json:"files"`
+}
+
+// PlannedCompaction は一つにまとめられる同期コミットの組。
+type PlannedCompaction struct {
+	Window  time.Time       `json:"window"`
+	Commits []PlannedCommit `json:"commits"`
+}
+
+// PlannedCommit は計画に現れる既存のコミット。
+type PlannedCommit struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+}
+
+// TodoEntry は autosquash rebase の todo の 1 行。Commit が空の行はこれから作成する fixup コミット。
+type TodoEntry struct {
+	Action  string `json:"action"`
+	Commit  string `json:"commit,omitempty"`
+	Subject string `json:"subject"`
+}
+
+// Plan は RunFixup を実行した場合の操作内容を求める。ブランチの切り替えやステージ、コミットは行わない。
+func (f *FixupManager) Plan(ctx context.Context) (*FixupPlan, error) {
+	if err := f.validateRepository(); err != nil {
+		return nil, fmt.Errorf("repository validation failed: %w", err)
+	}
+
+	devBranch, err := f.getDevCurrentBranch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dev current branch: %w", err)
+	}
+	currentBranch, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	rng, err := f.resolveRange(ctx, devBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve fixup range: %w", err)
+	}
+
+	plan := &FixupPlan{
+		Branch:        devBranch,
+		CurrentBranch: currentBranch,
+		Upstream:      rng.Upstream,
+		FixupTarget:   f.cfg.FixupTarget,
+		FixupStrategy: f.strategy(),
+		Autosquash:    f.cfg.AutosquashEnabled,
+	}
+	if plan.FixupTarget == "" {
+		plan.FixupTarget = config.FixupTargetBase
+	}
+
+	if plan.Compactions, err = f.planCompaction(ctx, rng); err != nil {
+		return nil, err
+	}
+
+	paths, unrelated, err := f.collectChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for uncommitted changes: %w", err)
+	}
+	plan.Files = paths
+	plan.UnrelatedChanges = unrelated
+	if len(paths) == 0 {
+		return plan, nil
+	}
+	plan.Approximate = len(plan.Compactions) > 0
+
+	if f.strategy() == config.FixupStrategyAmendLast {
+		head, ok, err := f.amendableHead(ctx, rng)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			plan.AmendHead = true
+			plan.Fixups = []PlannedFixup{{Target: head.Hash, Subject: head.Subject, Files: paths}}
+			plan.Rewritten = []PlannedCommit{{Hash: head.Hash, Subject: head.Subject}}
+			return plan, nil
+		}
+	}
+
+	if plan.Fixups, err = f.planFixups(ctx, paths, rng); err != nil {
+		return nil, err
+	}
+	if len(plan.Fixups) == 0 || !f.cfg.AutosquashEnabled {
+		return plan, nil
+	}
+
+	if err := f.planAutosquash(ctx, plan, rng); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planCompaction は fixup 前に行われる同期コミットの圧縮を求める。
+func (f *FixupManager) planCompaction(ctx context.Context, rng *fixupRange) ([]PlannedCompaction, error) {
+	if f.cfg.CompactionWindow == "" {
+		return nil, nil
+	}
+
+	commits, err := f.unpublishedCommits(ctx, rng.MergeBase)
+	if err != nil {
+		return nil, err
+	}
+	for _, commit := range commits {
+		if len(commit.Parents) > 1 {
+			return nil, nil
+		}
+	}
+
+	var compactions []PlannedCompaction
+	for _, unit := range f.compactionUnits(commits, time.Now()) {
+		if !unit.compact {
+			continue
+		}
+		compaction := PlannedCompaction{Window: unit.window}
+		for _, commit := range unit.commits {
+			compaction.Commits = append(compaction.Commits, PlannedCommit{Hash: commit.Hash, Subject: commit.Subject})
+		}
+		compactions = append(compactions, compaction)
+	}
+	return compactions, nil
+}
+
+// planFixups は作成される fixup コミットを古い対象から順に求める。
+func (f *FixupManager) planFixups(ctx context.Context, paths []string, rng *fixupRange) ([]PlannedFixup, error) {
+	if f.cfg.FixupTarget != config.FixupTargetAbsorb {
+		target, err := f.getBaseCommit(ctx, rng)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get base commit: %w", err)
+		}
+		subject, err := f.subjectOf(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		return []PlannedFixup{{Target: target, Subject: subject, Files: paths}}, nil
+	}
+
+	// 作業中のインデックスを変更しないよう、一時インデックスにステージして割り当てを求める。
+	indexPath, err := git.Output(ctx, f.ops, "rev-parse", "--git-path", "fcsm-plan.index")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve temporary index path: %w", err)
+	}
+	if !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(f.ops.Dir(), indexPath)
+	}
+	defer os.Remove(indexPath)
+
+	planner := *f
+	planner.ops = git.WithEnv(f.ops, "GIT_INDEX_FILE="+indexPath)
+	if _, err := planner.ops.Run(ctx, "read-tree", "HEAD"); err != nil {
+		return nil, fmt.Errorf("failed to prepare temporary index: %w", err)
+	}
+	if err := git.AddPaths(ctx, planner.ops, paths); err != nil {
+		return nil, fmt.Errorf("failed to stage changes in temporary index: %w", err)
+	}
+
+	changes, targets, err := planner.absorbTargets(ctx, paths, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixups []PlannedFixup
+	for _, target := range targets {
+		subject, err := f.subjectOf(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, change := range changes {
+			hit := change.hunks == nil && change.wholeTarget == target
+			for _, h := range change.hunks {
+				hit = hit || h.target == target
+			}
+			if hit {
+				files = append(files, change.path)
+			}
+		}
+		fixups = append(fixups, PlannedFixup{Target: target, Subject: subject, Files: files})
+	}
+	return fixups, nil
+}
+
+// planAutosquash は autosquash rebase の todo と、新しいハッシュになるコミットを求める。
+func (f *FixupManager) planAutosquash(ctx context.Context, plan *FixupPlan, rng *fixupRange) error {
+	upstream, err := f.rebaseUpstream(ctx, rng, plan.Fixups[0].Target)
+	if err != nil {
+		return err
+	}
+	upstream, ok, err := f.limitToUnpublished(ctx, upstream)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		plan.AutosquashSkipped = "no unpublished commits to rewrite"
+		return nil
+	}
+
+	commits, err := f.unpublishedCommits(ctx, upstream)
+	if err != nil {
+		return err
+	}
+
+	// rebase はマージコミットを取り除き、残りを線形に積み直す。
+	var original []TodoEntry
+	for _, commit := range commits {
+		if len(commit.Parents) <= 1 {
+			original = append(original, TodoEntry{Action: "pick", Commit: commit.Hash, Subject: commit.Subject})
+		}
+	}
+	for _, fixup := range plan.Fixups {
+		original = append(original, TodoEntry{Action: "pick", Subject: f.subjectPrefix() + fixup.Subject})
+	}
+
+	plan.RebaseOnto = upstream
+	plan.Todo = autosquashTodo(original)
+
+	for _, entry := range plan.Todo[rewriteStart(original, plan.Todo):] {
+		if entry.Action == "pick" && entry.Commit != "" {
+			plan.Rewritten = append(plan.Rewritten, PlannedCommit{Hash: entry.Commit, Subject: entry.Subject})
+		}
+	}
+	return nil
+}
+
+// rewriteStart は autosquash 後の todo で最初に作り直されるコミットの位置を返す。作り直しが無い場合は len(todo)。
+// 並びか操作が変わった位置以降のコミットはすべて作り直される。
+// その位置が fixup などの場合は、直前の取り込み先のコミットも作り直される。
+func rewriteStart(original, todo []TodoEntry) int {
+	for i, entry := range todo {
+		if entry != original[i] {
+			if entry.Action != "pick" && i > 0 {
+				return i - 1
+			}
+			return i
+		}
+	}
+	return len(todo)
+}
+
+// autosquashTodo は git rebase --autosquash と同様に、fixup!/squash!/amend! コミットを対象コミットの直後へ移動する。
+func autosquashTodo(entries []TodoEntry) []TodoEntry {
+	moved := make([]bool, len(entries))
+	attached := make(map[int][]TodoEntry)
+
+	for i, entry := range entries {
+		action, rest, ok := squashAction(entry.Subject)
+		if !ok {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if moved[j] {
+				continue
+			}
+			target := entries[j]
+			if target.Subject == rest || (target.Commit != "" && len(rest) >= 4 && strings.HasPrefix(target.Commit, rest)) {
+				entry.Action = action
+				attached[j] = append(attached[j], entry)
+				moved[i] = true
+				break
+			}
+		}
+	}
+
+	todo := make([]TodoEntry, 0, len(entries))
+	for i, entry := range entries {
+		if moved[i] {
+			continue
+		}
+		todo = append(todo, entry)
+		todo = append(todo, attached[i]...)
+	}
+	return todo
+}
+
+// squashAction は件名の接頭辞から todo の操作と、対象を示す残りの件名を返す。
+func squashAction(subject string) (string, string, bool) {
+	prefixes := []struct{ prefix, action string }{
+		{"fixup! ", "fixup"},
+		{"squash! ", "squash"},
+		{"amend! ", "fixup -C"},
+	}
+
+	action := ""
+	for {
+		stripped := false
+		for _, p := range prefixes {
+			if strings.HasPrefix(subject, p.prefix) {
+				if action == "" {
+					action = p.action
+				}
+				subject = strings.TrimPrefix(subject, p.prefix)
+				stripped = true
+			}
+		}
+		if !stripped {
+			return action, subject, action != ""
+		}
+	}
+}
+
+// subjectOf は commit の件名を返す。
+func (f *FixupManager) subjectOf(ctx context.Context, commit string) (string, error) {
+	subject, err := git.Output(ctx, f.ops, "log", "-1", "--format=%s", commit)
+	if err != nil {
+		return "", fmt.Errorf("failed to read subject of %s: %w", commit, err)
+	}
+	return subject, nil
+}
@@ -0,0 +1,148 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+func TestAutosquashTodo(t *testing.T) {
+	entries := []TodoEntry{
+		{Action: "pick", Commit: "aaaa1111", Subject: "Add a"},
+		{Action: "pick", Commit: "bbbb2222", Subject: "Add b"},
+		{Action: "pick", Commit: "cccc3333", Subject: "squash! Add a"},
+		{Action: "pick", Commit: "dddd4444", Subject: "amend! fixup! bbbb"},
+		{Action: "pick", Subject: "fixup! Add a"},
+		{Action: "pick", Subject: "fixup! Unknown"},
+	}
+
+	var got []string
+	for _, entry := range autosquashTodo(entries) {
+		got = append(got, entry.Action+" "+entry.Subject)
+	}
+
+	want := []string{
+		"pick Add a",
+		"squash squash! Add a",
+		"fixup fixup! Add a",
+		"pick Add b",
+		"fixup -C amend! fixup! bbbb",
+		"pick fixup! Unknown",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("autosquashTodo() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPlanDoesNotModifyRepository(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping fixup plan test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+	feature1 := run("rev-parse", "HEAD~1")
+	feature2 := run("rev-parse", "HEAD")
+
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+	os.WriteFile(filepath.Join(opsRepo, "notes.txt"), []byte("memo"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		IncludeExtensions: []string{".cpp"},
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+	}
+
+	status := run("status", "--porcelain")
+	plan, err := NewFixupManager(cfg).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() failed: %v", err)
+	}
+
+	if run("rev-parse", "HEAD") != feature2 || run("status", "--porcelain") != status {
+		t.Error("Plan() must not modify the ops repository")
+	}
+
+	if len(plan.Files) != 1 || plan.Files[0] != "base.cpp" {
+		t.Errorf("Expected base.cpp to be staged, got %q", plan.Files)
+	}
+	if len(plan.UnrelatedChanges) != 1 || plan.UnrelatedChanges[0] != "notes.txt" {
+		t.Errorf("Expected notes.txt to be reported as unrelated, got %q", plan.UnrelatedChanges)
+	}
+	if len(plan.Fixups) != 1 || plan.Fixups[0].Target != feature1 || plan.Fixups[0].Subject != "Feature 1" {
+		t.Fatalf("Expected a fixup for Feature 1, got %+v", plan.Fixups)
+	}
+
+	// origin/main は公開済みのため rebase はその上から行われる。
+	if plan.RebaseOnto != run("rev-parse", "origin/main") {
+		t.Errorf("Rebase should start at origin/main, got %s", plan.RebaseOnto)
+	}
+	var todo []string
+	for _, entry := range plan.Todo {
+		todo = append(todo, entry.Action+" "+entry.Subject)
+	}
+	if strings.Join(todo, ", ") != "pick Feature 1, fixup fixup! Feature 1, pick Feature 2" {
+		t.Errorf("Unexpected todo: %q", todo)
+	}
+	if len(plan.Rewritten) != 2 || plan.Rewritten[0].Hash != feature1 || plan.Rewritten[1].Hash != feature2 {
+		t.Errorf("Feature 1 and Feature 2 should get new hashes, got %+v", plan.Rewritten)
+	}
+}
+
+func TestPlanAbsorbTargets(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping absorb plan test")
+	}
+
+	devRepo, opsRepo := createAbsorbRepositories(t)
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = opsRepo
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Failed to run git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	addA := run("rev-parse", "HEAD~2")
+	addB := run("rev-parse", "HEAD~1")
+	editA := run("rev-parse", "HEAD")
+
+	os.WriteFile(filepath.Join(opsRepo, "b.cpp"), []byte("b1\nB2\nb3\n"), 0644)
+	os.WriteFile(filepath.Join(opsRepo, "a.cpp"), []byte("A1\na2\na3\na4\na5-edited\n"), 0644)
+	run("add", "a.cpp")
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		FixupTarget:       config.FixupTargetAbsorb,
+		AutosquashEnabled: true,
+	}
+
+	plan, err := NewFixupManager(cfg).Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() failed: %v", err)
+	}
+
+	if len(plan.Fixups) != 2 || plan.Fixups[0].Target != addA || plan.Fixups[1].Target != addB {
+		t.Fatalf("Expected fixups for Add a and Add b, got %+v", plan.Fixups)
+	}
+	if len(plan.Rewritten) != 3 || plan.Rewritten[0].Hash != addA || plan.Rewritten[2].Hash != editA {
+		t.Errorf("All three commits should get new hashes, got %+v", plan.Rewritten)
+	}
+
+	// 作業中のインデックスはそのまま残る。
+	if staged := run("diff", "--cached", "--name-only"); staged != "a.cpp" {
+		t.Errorf("Index should be left untouched, got staged %q", staged)
+	}
+}
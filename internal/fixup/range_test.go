@@ -0,0 +1,218 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+func TestFixupWithinUpstreamRange(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping upstream range test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+	upstreamHead := run("rev-parse", "origin/main")
+	feature1 := run("rev-parse", "HEAD~1")
+
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+		FixupUpstream:     "origin/main",
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+	if result.CommitHash != feature1 {
+		t.Errorf("Expected fixup target %s, got %s", feature1, result.CommitHash)
+	}
+
+	if subjects := run("log", "--format=%s", "origin/main..HEAD"); subjects != "Feature 2\nFeature 1" {
+		t.Errorf("Fixup should be squashed within the branch, got:\n%s", subjects)
+	}
+	if base := run("merge-base", "HEAD", "origin/main"); base != upstreamHead {
+		t.Errorf("Commits of the upstream must not be rewritten: merge-base %s, want %s", base, upstreamHead)
+	}
+	if content := run("show", "HEAD~1:base.cpp"); content != "changed" {
+		t.Errorf("Change should be folded into Feature 1, got %q", content)
+	}
+}
+
+func TestAbsorbNeverTargetsOutsideRange(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping upstream range test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1")
+	upstreamHead := run("rev-parse", "origin/main")
+	feature1 := run("rev-parse", "HEAD")
+
+	// base.cpp は upstream のコミットで追加されたため、blame 結果は範囲外となる。
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		FixupTarget:       config.FixupTargetAbsorb,
+		AutosquashEnabled: true,
+		BranchUpstreams:   map[string]string{"feature": "origin/main"},
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+	if len(result.Fixups) != 1 || result.Fixups[0].Target != feature1 {
+		t.Fatalf("Expected a single fixup for %s, got %+v", feature1, result.Fixups)
+	}
+	if base := run("merge-base", "HEAD", "origin/main"); base != upstreamHead {
+		t.Errorf("Commits of the upstream must not be rewritten: merge-base %s, want %s", base, upstreamHead)
+	}
+}
+
+func TestFixupWithoutCommitsInRange(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping upstream range test")
+	}
+
+	devRepo, opsRepo, _ := createUpstreamRepositories(t)
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:    devRepo,
+		OpsRepoPath:    opsRepo,
+		GitExecutable:  "git",
+		FixupMsgPrefix: "fixup! ",
+		FixupUpstream:  "origin/main",
+	}
+
+	_, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no commits to fix up") {
+		t.Errorf("Expected error for empty range, got %v", err)
+	}
+}
+
+func TestAutosquashKeepsProtectedCommits(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping published history test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+	feature1 := run("rev-parse", "HEAD~1")
+	run("tag", "v1.0", feature1)
+
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+		ProtectedRefs:     []string{"refs/tags/"},
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+	if result.CommitHash != feature1 {
+		t.Fatalf("Expected fixup target %s, got %s", feature1, result.CommitHash)
+	}
+
+	// タグ付きのコミットは書き換えず、その fixup は統合されずに残る。
+	if base := run("merge-base", "HEAD", "v1.0"); base != feature1 {
+		t.Errorf("Protected commit must not be rewritten: merge-base %s, want %s", base, feature1)
+	}
+	if subjects := run("log", "--format=%s", "v1.0..HEAD"); subjects != "fixup! Feature 1\nFeature 2" {
+		t.Errorf("Unexpected history after autosquash:\n%s", subjects)
+	}
+}
+
+func TestAutosquashSkippedWhenAllPublished(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping published history test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1")
+	run("update-ref", "refs/remotes/origin/feature", "HEAD")
+
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+	if result.BackupRef != "" {
+		t.Errorf("Rebase should be skipped when every target is published, got backup %s", result.BackupRef)
+	}
+	if head := run("rev-parse", "HEAD"); head != result.FixupCommitHash {
+		t.Errorf("Fixup commit should stay on top of published history, HEAD %s", head)
+	}
+}
+
+// createUpstreamRepositories は origin/main の上に feature ブランチのコミットを積んだ Ops リポジトリを作成する。
+func createUpstreamRepositories(t *testing.T, featureCommits ...string) (string, string, func(args ...string) string) {
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryFixup(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryFixup(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	git := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Failed to run git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	run := func(args ...string) string {
+		return git(opsRepo, args...)
+	}
+
+	git(devRepo, "checkout", "-b", "feature")
+
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("base"), 0644)
+	run("add", "base.cpp")
+	run("commit", "-m", "Base")
+	run("update-ref", "refs/remotes/origin/main", "HEAD")
+	run("checkout", "-b", "feature")
+
+	for i, message := range featureCommits {
+		name := filepath.Join(opsRepo, "feature"+string(rune('a'+i))+".cpp")
+		os.WriteFile(name, []byte(message), 0644)
+		run("add", "-A")
+		run("commit", "-m", message)
+	}
+
+	return devRepo, opsRepo, run
+}
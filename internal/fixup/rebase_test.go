@@ -0,0 +1,82 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/backup"
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+)
+
+func TestAutosquashConflictRestoresBackup(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping autosquash conflict test")
+	}
+
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryFixup(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryFixup(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	run := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = opsRepo
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Failed to run git %v: %v", args, err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	for _, step := range []struct{ content, message string }{{"1\n", "Add f"}, {"2\n", "Edit f"}} {
+		os.WriteFile(filepath.Join(opsRepo, "f.cpp"), []byte(step.content), 0644)
+		run("add", "f.cpp")
+		run("commit", "-m", step.message)
+	}
+
+	// 直前のコミット（Add f）への fixup は Edit f と衝突する。
+	os.WriteFile(filepath.Join(opsRepo, "f.cpp"), []byte("3\n"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+	}
+
+	_, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err == nil {
+		t.Fatal("RunFixup() should fail when autosquash conflicts")
+	}
+
+	if _, err := os.Stat(filepath.Join(opsRepo, ".git", "rebase-merge")); !os.IsNotExist(err) {
+		t.Error("Rebase should be aborted")
+	}
+	if status := run("status", "--porcelain"); status != "" {
+		t.Errorf("Ops repository should be clean after restore, got: %s", status)
+	}
+
+	branch := run("branch", "--show-current")
+	backups, err := backup.NewManager(cfg, git.NewRunner("git", opsRepo)).List(context.Background(), branch)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected one backup ref, got %+v, %v", backups, err)
+	}
+	if head := run("rev-parse", "HEAD"); head != backups[0].Commit {
+		t.Errorf("HEAD should be restored to the backup %s, got %s", backups[0].Commit, head)
+	}
+	if subject := run("log", "-1", "--format=%s"); subject != "fixup! Add f" {
+		t.Errorf("Fixup commit should be kept for a later retry, got %q", subject)
+	}
+}
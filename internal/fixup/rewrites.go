@@ -0,0 +1,145 @@
+package fixup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/rewrite"
+)
+
+// stateFilePath は Ops リポジトリの git ディレクトリ内の name の絶対パスを返し、親ディレクトリを作成する。
+func (f *FixupManager) stateFilePath(ctx context.Context, name string) (string, error) {
+	path, err := git.Output(ctx, f.ops, "rev-parse", "--git-path", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", name, err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(f.ops.Dir(), path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return path, nil
+}
+
+// copyEditor は編集対象のファイルを path に複製するだけのエディタコマンドを返す。
+// git はエディタをシェル経由で "$@" 付きで起動するため、$1 が編集対象のファイルとなる。
+func copyEditor(path string) string {
+	quoted := "'" + strings.ReplaceAll(filepath.ToSlash(path), "'", `'\''`) + "'"
+	return `cp "$1" ` + quoted + "; :"
+}
+
+// rebaseRewrites は rebase で実行された todo と rebase 後の履歴から、書き換え前後のコミットの対応を求める。
+// todo の pick ごとに一つのコミットが作られ、続く fixup/squash のコミットも同じコミットに対応する。
+func (f *FixupManager) rebaseRewrites(ctx context.Context, todoPath, upstream string) ([]rewrite.Entry, error) {
+	data, err := os.ReadFile(todoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rebase todo: %w", err)
+	}
+	groups, err := parseTodo(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	revision := "HEAD"
+	if upstream != "" {
+		revision = upstream + "..HEAD"
+	}
+	rebased, err := git.Lines(ctx, f.ops, "rev-list", "--reverse", "--first-parent", revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rebased commits: %w", err)
+	}
+	if len(rebased) != len(groups) {
+		return nil, fmt.Errorf("rebase produced %d commits for %d todo entries", len(rebased), len(groups))
+	}
+
+	var rewrites []rewrite.Entry
+	for i, group := range groups {
+		commits, err := git.Lines(ctx, f.ops, append([]string{"rev-parse"}, group...)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve todo commits: %w", err)
+		}
+		for _, commit := range commits {
+			// 先頭の変更されなかったコミットは fast-forward されてハッシュが変わらない。
+			if commit != rebased[i] {
+				rewrites = append(rewrites, rewrite.Entry{Old: commit, New: rebased[i]})
+			}
+		}
+	}
+	return rewrites, nil
+}
+
+// parseTodo は rebase の todo を、作成されるコミットごとの元コミットの組に分ける。
+func parseTodo(todo string) ([][]string, error) {
+	var groups [][]string
+	for _, line := range strings.Split(todo, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		command, args := fields[0], fields[1:]
+		// fixup -C / -c はメッセージの扱いを指定するオプション。
+		if len(args) > 0 && (args[0] == "-C" || args[0] == "-c") {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("malformed rebase todo line: %q", line)
+		}
+
+		switch command {
+		case "pick", "p", "reword", "r", "edit", "e":
+			groups = append(groups, []string{args[0]})
+		case "fixup", "f", "squash", "s":
+			if len(groups) == 0 {
+				return nil, fmt.Errorf("rebase todo starts with %s", command)
+			}
+			groups[len(groups)-1] = append(groups[len(groups)-1], args[0])
+		case "drop", "d":
+		default:
+			return nil, fmt.Errorf("unsupported rebase todo command: %q", command)
+		}
+	}
+	return groups, nil
+}
+
+// recordRewrites は書き換えの対応を状態ファイルに記録し、設定されていれば notes を新しいコミットへ移す。
+// 履歴は既に書き換わっているため、ctx が終了していても記録は完了させる。
+func (f *FixupManager) recordRewrites(ctx context.Context, operation, backupRef string, rewrites []rewrite.Entry) error {
+	if len(rewrites) == 0 && backupRef == "" {
+		return nil
+	}
+	if rewrites == nil {
+		// 書き換えたが対応を求められなかった場合も、バックアップ ref を辿れるよう空の対応として記録する。
+		rewrites = []rewrite.Entry{}
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	branch, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	record := rewrite.Record{
+		Time:      time.Now(),
+		Branch:    branch,
+		Operation: operation,
+		BackupRef: backupRef,
+		Rewrites:  rewrites,
+	}
+	if err := rewrite.NewLog(f.ops).Append(ctx, record); err != nil {
+		return err
+	}
+
+	if len(f.cfg.RewriteNotesRefs) > 0 {
+		if err := rewrite.MoveNotes(ctx, f.ops, f.cfg.RewriteNotesRefs, rewrites); err != nil {
+			return err
+		}
+	}
+	return nil
+}
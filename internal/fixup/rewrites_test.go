@@ -0,0 +1,188 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/rewrite"
+)
+
+func TestParseTodo(t *testing.T) {
+	todo := `pick 1111111 Add a
+fixup 2222222 fixup! Add a
+f -C 3333333 amend! Add a
+pick 4444444 Add b
+squash 5555555 squash! Add b
+drop 6666666 Dropped
+
+# Rebase 0000000..6666666 onto 0000000 (6 commands)
+`
+	groups, err := parseTodo(todo)
+	if err != nil {
+		t.Fatalf("parseTodo() failed: %v", err)
+	}
+	got := make([]string, 0, len(groups))
+	for _, group := range groups {
+		got = append(got, strings.Join(group, "+"))
+	}
+	if strings.Join(got, " ") != "1111111+2222222+3333333 4444444+5555555" {
+		t.Errorf("Unexpected groups: %q", got)
+	}
+
+	if _, err := parseTodo("exec make test\n"); err == nil {
+		t.Error("Unsupported commands should be rejected")
+	}
+}
+
+func TestAutosquashRecordsRewrites(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping rewrite map test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+	feature1 := run("rev-parse", "HEAD~1")
+	feature2 := run("rev-parse", "HEAD")
+	run("notes", "add", "-m", "ci: passed", feature1)
+
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+		RewriteNotesRefs:  []string{"refs/notes/commits"},
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+
+	newFeature1 := run("rev-parse", "HEAD~1")
+	newFeature2 := run("rev-parse", "HEAD")
+	want := []rewrite.Entry{
+		{Old: feature1, New: newFeature1},
+		{Old: result.FixupCommitHash, New: newFeature1},
+		{Old: feature2, New: newFeature2},
+	}
+	if len(result.Rewrites) != len(want) {
+		t.Fatalf("Expected rewrites %+v, got %+v", want, result.Rewrites)
+	}
+	for i := range want {
+		if result.Rewrites[i] != want[i] {
+			t.Errorf("Rewrite %d: expected %+v, got %+v", i, want[i], result.Rewrites[i])
+		}
+	}
+
+	records, err := rewrite.NewLog(git.NewRunner("git", opsRepo)).Read(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read rewrite state: %v", err)
+	}
+	if len(records) != 1 || records[0].Operation != "autosquash" || records[0].BackupRef != result.BackupRef || len(records[0].Rewrites) != 3 {
+		t.Errorf("Unexpected rewrite records: %+v", records)
+	}
+
+	if note := run("notes", "show", newFeature1); note != "ci: passed" {
+		t.Errorf("Note should be moved to the rewritten commit, got %q", note)
+	}
+	if notes := run("notes", "list"); strings.Contains(notes, feature1) {
+		t.Errorf("Note should be removed from the old commit, got:\n%s", notes)
+	}
+}
+
+func TestAutosquashIgnoresRebaseConfig(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping rebase config test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+	// rebase.updateRefs が有効だと、範囲内を指す別ブランチの update-ref が todo に入る。
+	run("config", "rebase.updateRefs", "true")
+	run("config", "rebase.rebaseMerges", "true")
+	run("branch", "stacked", "HEAD~1")
+	stacked := run("rev-parse", "stacked")
+
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+	}
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+	if len(result.Rewrites) != 3 {
+		t.Errorf("Expected 3 rewrites, got %+v", result.Rewrites)
+	}
+
+	// 他のブランチは書き換えない。
+	if got := run("rev-parse", "stacked"); got != stacked {
+		t.Errorf("Branch stacked should not be moved by the autosquash rebase, got %s (was %s)", got, stacked)
+	}
+}
+
+// droppingRunner は autosquash rebase の todo を写した後で、subject の pick を drop に書き換える Runner。
+// rebase 中に空になって取り除かれたコミットのように、todo と rebase 後の履歴の数が合わない場合を再現する。
+type droppingRunner struct {
+	git.Runner
+	subject string
+}
+
+func (r *droppingRunner) RunWithOptions(ctx context.Context, opts git.RunOptions, args ...string) ([]byte, error) {
+	for i, env := range opts.Env {
+		if strings.HasPrefix(env, "GIT_SEQUENCE_EDITOR=") {
+			opts.Env[i] = strings.TrimSuffix(env, "; :") + `; sed -i 's/^pick \([0-9a-f]* ` + r.subject + `\)$/drop \1/' "$1"; :`
+		}
+	}
+	return r.Runner.RunWithOptions(ctx, opts, args...)
+}
+
+func TestAutosquashWithDroppedCommit(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping dropped commit test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+	os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		AutosquashEnabled: true,
+	}
+	ops := &droppingRunner{Runner: git.NewRunner("git", opsRepo), subject: "Feature 2"}
+	manager := NewFixupManagerWithRunners(cfg, git.NewRunner("git", devRepo), ops)
+
+	// rebase が成功して履歴が書き換わった後は、対応を求められなくても失敗としない。
+	result, err := manager.RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() should succeed once the rebase has completed: %v", err)
+	}
+	if result.BackupRef == "" || len(result.Rewrites) != 0 {
+		t.Errorf("Expected a backup ref without rewrites, got backup %q rewrites %+v", result.BackupRef, result.Rewrites)
+	}
+	if subjects := run("log", "--format=%s", "origin/main..HEAD"); subjects != "Feature 1" {
+		t.Errorf("Expected the rebased history, got:\n%s", subjects)
+	}
+
+	records, err := rewrite.NewLog(git.NewRunner("git", opsRepo)).Read(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to read rewrite state: %v", err)
+	}
+	if len(records) != 1 || records[0].BackupRef != result.BackupRef || len(records[0].Rewrites) != 0 {
+		t.Errorf("Expected the backup ref to be recorded with an empty map, got %+v", records)
+	}
+}
@@ -0,0 +1,128 @@
+package fixup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/backup"
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/rewrite"
+)
+
+// AmendedTrailer は amend! 戦略で書き換えたメッセージに付与する trailer のキー。
+const AmendedTrailer = "Fcsm-Amended"
+
+// strategy は有効な fixup 戦略を返す。
+func (f *FixupManager) strategy() string {
+	if f.cfg.FixupStrategy == "" {
+		return config.FixupStrategyFixup
+	}
+	return f.cfg.FixupStrategy
+}
+
+// subjectPrefix は fixup コミットの件名の接頭辞を返す。autosquash はこの接頭辞で todo の操作を決める。
+func (f *FixupManager) subjectPrefix() string {
+	switch f.strategy() {
+	case config.FixupStrategyAmend:
+		return "amend! "
+	case config.FixupStrategySquash:
+		return "squash! "
+	default:
+		return "fixup! "
+	}
+}
+
+// fixupMessage は target に対する fixup コミットのメッセージを戦略に応じて作成する。
+func (f *FixupManager) fixupMessage(ctx context.Context, target string) (string, error) {
+	subject, err := f.subjectOf(ctx, target)
+	if err != nil {
+		return "", err
+	}
+
+	if f.strategy() != config.FixupStrategyAmend {
+		return fmt.Sprintf("%s%s\n\n%s\n", f.subjectPrefix(), subject, f.generateFixupMessage(target)), nil
+	}
+
+	// amend! コミットの本文は autosquash 後に対象コミットの新しいメッセージとなるため、
+	// 元のメッセージに書き換えを示す trailer を加えたものにする。
+	original, err := f.ops.Run(ctx, "log", "-1", "--format=%B", target)
+	if err != nil {
+		return "", fmt.Errorf("failed to read message of %s: %w", target, err)
+	}
+	trailer := fmt.Sprintf("%s: %s", AmendedTrailer, time.Now().Format("2006-01-02 15:04:05"))
+	amended, err := f.ops.RunWithOptions(ctx, git.RunOptions{Stdin: strings.NewReader(string(original))},
+		"interpret-trailers", "--trailer", trailer)
+	if err != nil {
+		return "", fmt.Errorf("failed to add trailer to message of %s: %w", target, err)
+	}
+
+	return fmt.Sprintf("amend! %s\n\n%s\n", subject, strings.TrimRight(string(amended), "\n")), nil
+}
+
+// amendLast は HEAD が未公開の同期コミットであれば、変更をそのコミットへ直接 amend する。
+// 対象外の場合は false を返し、呼び出し側は通常の fixup コミットを作成する。
+func (f *FixupManager) amendLast(ctx context.Context, paths, unrelated []string, rng *fixupRange) (*FixupResult, bool, error) {
+	head, ok, err := f.amendableHead(ctx, rng)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	branch, err := f.getCurrentBranch(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get current branch: %w", err)
+	}
+	backupRef, err := backup.NewManager(f.cfg, f.ops).Create(ctx, branch)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := f.gitAddPaths(ctx, paths); err != nil {
+		return nil, false, fmt.Errorf("failed to add changes: %w", err)
+	}
+	if err := git.CommitPaths(ctx, f.ops, paths, "--amend", "--no-edit"); err != nil {
+		return nil, false, fmt.Errorf("git commit --amend failed: %w", err)
+	}
+
+	amended, err := f.getLastCommitHash(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rewrites := []rewrite.Entry{{Old: head.Hash, New: amended}}
+	if err := f.recordRewrites(ctx, "amend", backupRef, rewrites); err != nil {
+		return nil, false, err
+	}
+
+	return &FixupResult{
+		CommitHash:       head.Hash,
+		FixupCommitHash:  amended,
+		FilesModified:    len(paths),
+		Success:          true,
+		UnrelatedChanges: unrelated,
+		Fixups:           []FixupCommit{{Target: head.Hash, Commit: amended, Files: paths}},
+		BackupRef:        backupRef,
+		Amended:          true,
+		Rewrites:         rewrites,
+	}, true, nil
+}
+
+// amendableHead は HEAD が直接 amend できる（未公開かつ同期処理が作成した）コミットかを判定する。
+func (f *FixupManager) amendableHead(ctx context.Context, rng *fixupRange) (commitInfo, bool, error) {
+	commits, err := f.unpublishedCommits(ctx, rng.MergeBase)
+	if err != nil {
+		return commitInfo{}, false, err
+	}
+	if len(commits) == 0 {
+		return commitInfo{}, false, nil
+	}
+
+	// --topo-order --reverse の最後が HEAD となる。
+	head := commits[len(commits)-1]
+	if len(head.Parents) > 1 || !f.isSyncCommit(head) {
+		return commitInfo{}, false, nil
+	}
+	return head, true, nil
+}
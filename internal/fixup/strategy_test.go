@@ -0,0 +1,197 @@
+package fixup
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+func TestFixupMessageStrategies(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping fixup message test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1")
+	target := run("rev-parse", "HEAD")
+
+	tests := []struct {
+		strategy string
+		subject  string
+		body     string
+	}{
+		{"", "fixup! Feature 1", "fixup! Automated fixup for " + target[:8]},
+		{config.FixupStrategyFixup, "fixup! Feature 1", "fixup! Automated fixup for " + target[:8]},
+		{config.FixupStrategySquash, "squash! Feature 1", "fixup! Automated fixup for " + target[:8]},
+		{config.FixupStrategyAmend, "amend! Feature 1", "Feature 1\n\n" + AmendedTrailer + ": "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			cfg := &config.Config{
+				DevRepoPath:    devRepo,
+				OpsRepoPath:    opsRepo,
+				GitExecutable:  "git",
+				FixupMsgPrefix: "fixup! ",
+				FixupStrategy:  tt.strategy,
+			}
+
+			message, err := NewFixupManager(cfg).fixupMessage(context.Background(), target)
+			if err != nil {
+				t.Fatalf("fixupMessage() failed: %v", err)
+			}
+			subject, body, _ := strings.Cut(message, "\n\n")
+			if subject != tt.subject {
+				t.Errorf("Expected subject %q, got %q", tt.subject, subject)
+			}
+			if !strings.HasPrefix(body, tt.body) {
+				t.Errorf("Expected body to start with %q, got %q", tt.body, body)
+			}
+		})
+	}
+}
+
+func TestFixupStrategiesAutosquash(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping fixup strategy test")
+	}
+
+	tests := []struct {
+		strategy string
+		check    func(t *testing.T, message string)
+	}{
+		{config.FixupStrategyFixup, func(t *testing.T, message string) {
+			if message != "Feature 1" {
+				t.Errorf("fixup should keep the target message, got %q", message)
+			}
+		}},
+		{config.FixupStrategyAmend, func(t *testing.T, message string) {
+			if !strings.HasPrefix(message, "Feature 1\n\n"+AmendedTrailer+": ") {
+				t.Errorf("amend! should rewrite the target message, got %q", message)
+			}
+		}},
+		{config.FixupStrategySquash, func(t *testing.T, message string) {
+			if !strings.HasPrefix(message, "Feature 1") || !strings.Contains(message, "Automated fixup") {
+				t.Errorf("squash! should keep both messages, got %q", message)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1", "Feature 2")
+			os.WriteFile(filepath.Join(opsRepo, "base.cpp"), []byte("changed"), 0644)
+
+			cfg := &config.Config{
+				DevRepoPath:       devRepo,
+				OpsRepoPath:       opsRepo,
+				GitExecutable:     "git",
+				FixupMsgPrefix:    "fixup! ",
+				FixupStrategy:     tt.strategy,
+				AutosquashEnabled: true,
+			}
+
+			if _, err := NewFixupManager(cfg).RunFixup(context.Background()); err != nil {
+				t.Fatalf("RunFixup() failed: %v", err)
+			}
+
+			if subjects := run("log", "--format=%s", "origin/main..HEAD"); subjects != "Feature 2\nFeature 1" {
+				t.Errorf("Fixup should be folded into Feature 1, got:\n%s", subjects)
+			}
+			if content := run("show", "HEAD~1:base.cpp"); content != "changed" {
+				t.Errorf("Change should be part of Feature 1, got %q", content)
+			}
+			tt.check(t, run("log", "-1", "--format=%B", "HEAD~1"))
+		})
+	}
+}
+
+func TestAmendLastStrategy(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping amend-last test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t, "Feature 1")
+	os.WriteFile(filepath.Join(opsRepo, "sync.cpp"), []byte("synced"), 0644)
+	run("add", "sync.cpp")
+	run("commit", "-m", "Auto-sync: 2024-01-01\n\nFcsm-Sync: auto")
+	syncCommit := run("rev-parse", "HEAD")
+
+	os.WriteFile(filepath.Join(opsRepo, "sync.cpp"), []byte("fixed"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		GitExecutable:     "git",
+		FixupMsgPrefix:    "fixup! ",
+		FixupStrategy:     config.FixupStrategyAmendLast,
+		AutosquashEnabled: true,
+	}
+
+	result, err := NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("RunFixup() failed: %v", err)
+	}
+	if !result.Amended || result.CommitHash != syncCommit || result.BackupRef == "" {
+		t.Fatalf("Expected the sync commit to be amended with a backup, got %+v", result)
+	}
+	if subjects := run("log", "--format=%s", "origin/main..HEAD"); subjects != "Auto-sync: 2024-01-01\nFeature 1" {
+		t.Errorf("No new commit should be created, got:\n%s", subjects)
+	}
+	if content := run("show", "HEAD:sync.cpp"); content != "fixed" {
+		t.Errorf("Change should be amended into HEAD, got %q", content)
+	}
+
+	// HEAD が人のコミットの場合は通常の fixup コミットを作成する。
+	os.WriteFile(filepath.Join(opsRepo, "human.cpp"), []byte("human"), 0644)
+	run("add", "human.cpp")
+	run("commit", "-m", "Human change")
+	os.WriteFile(filepath.Join(opsRepo, "human.cpp"), []byte("fixed"), 0644)
+
+	cfg.AutosquashEnabled = false
+	result, err = NewFixupManager(cfg).RunFixup(context.Background())
+	if err != nil {
+		t.Fatalf("Second RunFixup() failed: %v", err)
+	}
+	if result.Amended {
+		t.Error("A human commit must not be amended")
+	}
+	if subject := run("log", "-1", "--format=%s"); !strings.HasPrefix(subject, "fixup! ") {
+		t.Errorf("Expected a fixup commit on top, got %q", subject)
+	}
+}
+
+func TestAmendLastSkipsPublishedHead(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping amend-last test")
+	}
+
+	devRepo, opsRepo, run := createUpstreamRepositories(t)
+	os.WriteFile(filepath.Join(opsRepo, "sync.cpp"), []byte("synced"), 0644)
+	run("add", "sync.cpp")
+	run("commit", "-m", "Auto-sync: 2024-01-01\n\nFcsm-Sync: auto")
+	run("update-ref", "refs/remotes/origin/feature", "HEAD")
+
+	cfg := &config.Config{
+		DevRepoPath:   devRepo,
+		OpsRepoPath:   opsRepo,
+		GitExecutable: "git",
+		FixupStrategy: config.FixupStrategyAmendLast,
+	}
+
+	_, ok, err := NewFixupManager(cfg).amendableHead(context.Background(), &fixupRange{})
+	if err != nil || ok {
+		t.Errorf("A published sync commit must not be amended, got ok=%v err=%v", ok, err)
+	}
+
+	cmd := exec.Command("git", "update-ref", "-d", "refs/remotes/origin/feature")
+	cmd.Dir = opsRepo
+	cmd.Run()
+	if _, ok, _ := NewFixupManager(cfg).amendableHead(context.Background(), &fixupRange{}); !ok {
+		t.Error("An unpublished sync commit should be amendable")
+	}
+}
@@ -0,0 +1,248 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+// Runner は単一リポジトリを作業ディレクトリとして git コマンドを実行する。
+// プロセス全体のカレントディレクトリには依存しないため、複数の Runner を並行して利用できる。
+type Runner interface {
+	// Dir は git を実行するリポジトリのパスを返す。
+	Dir() string
+	// Run は git コマンドを実行し、標準出力を返す。
+	Run(ctx context.Context, args ...string) ([]byte, error)
+	// RunWithOptions は環境変数や標準入力を指定して git コマンドを実行する。
+	RunWithOptions(ctx context.Context, opts RunOptions, args ...string) ([]byte, error)
+}
+
+// RunOptions は git コマンド実行時の追加設定。
+type RunOptions struct {
+	Env     []string
+	Stdin   io.Reader
+	Timeout time.Duration
+}
+
+// Logger は git 呼び出しを記録するロガー。logger.Logger がこれを満たす。
+type Logger interface {
+	Debug(format string, args ...interface{})
+}
+
+// StdLogger は標準 log パッケージへ出力する Logger。
+type StdLogger struct{}
+
+func (StdLogger) Debug(format string, args ...interface{}) {
+	log.Printf("[DEBUG] "+format, args...)
+}
+
+// killGracePeriod は ctx の終了時に git へ終了を求めてから、強制終了するまでの猶予。
+const killGracePeriod = 5 * time.Second
+
+// ExecRunner は git 実行ファイルを子プロセスとして起動する Runner。
+type ExecRunner struct {
+	Executable string
+	WorkDir    string
+	Timeout    time.Duration
+	Logger     Logger
+}
+
+func NewRunner(executable, dir string) *ExecRunner {
+	if executable == "" {
+		executable = "git"
+	}
+	return &ExecRunner{
+		Executable: executable,
+		WorkDir:    dir,
+	}
+}
+
+func (r *ExecRunner) Dir() string {
+	return r.WorkDir
+}
+
+func (r *ExecRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return r.RunWithOptions(ctx, RunOptions{}, args...)
+}
+
+func (r *ExecRunner) RunWithOptions(ctx context.Context, opts RunOptions, args ...string) ([]byte, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timeout := r.Timeout
+	if opts.Timeout > 0 {
+		timeout = opts.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, r.Executable, args...)
+	cmd.Dir = r.WorkDir
+	stopKill := killTreeOnCancel(cmd)
+	defer stopKill()
+	// 終了させた git の子孫が出力のパイプを保持し続けても、待ち続けないようにする。
+	cmd.WaitDelay = killGracePeriod + time.Second
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
+	if opts.Stdin != nil {
+		cmd.Stdin = opts.Stdin
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	r.logf("git %s (dir: %s)", strings.Join(args, " "), r.WorkDir)
+
+	err := cmd.Run()
+	elapsed := time.Since(start)
+	if err != nil {
+		gitErr := &Error{
+			Dir:      r.WorkDir,
+			Args:     args,
+			ExitCode: -1,
+			Stderr:   strings.TrimSpace(stderr.String()),
+			Err:      err,
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			gitErr.ExitCode = exitErr.ExitCode()
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			gitErr.Err = ctxErr
+		}
+		r.logf("git %s failed after %v: %v", strings.Join(args, " "), elapsed, gitErr.Err)
+		return stdout.Bytes(), gitErr
+	}
+
+	r.logf("git %s completed in %v", strings.Join(args, " "), elapsed)
+	return stdout.Bytes(), nil
+}
+
+func (r *ExecRunner) logf(format string, args ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Debug(format, args...)
+	}
+}
+
+// WithEnv は r で実行するすべての git コマンドに環境変数 env を追加する Runner を返す。
+// 一時インデックス（GIT_INDEX_FILE）を使って作業中のインデックスに触れずに処理する場合などに使う。
+func WithEnv(r Runner, env ...string) Runner {
+	return &envRunner{Runner: r, env: env}
+}
+
+type envRunner struct {
+	Runner
+	env []string
+}
+
+func (r *envRunner) Run(ctx context.Context, args ...string) ([]byte, error) {
+	return r.RunWithOptions(ctx, RunOptions{}, args...)
+}
+
+func (r *envRunner) RunWithOptions(ctx context.Context, opts RunOptions, args ...string) ([]byte, error) {
+	opts.Env = append(append([]string{}, r.env...), opts.Env...)
+	return r.Runner.RunWithOptions(ctx, opts, args...)
+}
+
+// Error は失敗した git 呼び出しの詳細を保持する。
+type Error struct {
+	Dir      string
+	Args     []string
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("git %s failed in %s: %v", strings.Join(e.Args, " "), e.Dir, e.Err)
+	if e.Stderr != "" {
+		msg += ", output: " + e.Stderr
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode は err が git の終了コードを伴う場合にその値を返す。それ以外は -1。
+func ExitCode(err error) int {
+	var gitErr *Error
+	if errors.As(err, &gitErr) {
+		return gitErr.ExitCode
+	}
+	return -1
+}
+
+// Output は git コマンドを実行し、前後の空白を除いた標準出力を返す。
+func Output(ctx context.Context, r Runner, args ...string) (string, error) {
+	output, err := r.Run(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Lines は git コマンドを実行し、空行を除いた標準出力の各行を返す。
+func Lines(ctx context.Context, r Runner, args ...string) ([]string, error) {
+	output, err := r.Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// Succeeds は git コマンドが終了コード 0 で終了したかを返す。
+// show-ref --verify --quiet のような存在確認に使用する。
+// git が 0 以外の終了コードで終了した場合だけを false とし、中断やタイムアウト、起動の失敗、
+// リポジトリを開けないなど git 自体が異常終了した場合（終了コード 128）はエラーを返す。
+func Succeeds(ctx context.Context, r Runner, args ...string) (bool, error) {
+	_, err := r.Run(ctx, args...)
+	if err == nil {
+		return true, nil
+	}
+	if code := ExitCode(err); code > 0 && code != fatalExitCode &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return false, nil
+	}
+	return false, err
+}
+
+// fatalExitCode は git が致命的なエラーで終了した場合の終了コード。
+const fatalExitCode = 128
+
+// NewConfiguredRunner は設定のタイムアウトと詳細出力を反映した Runner を作成する。
+func NewConfiguredRunner(cfg *config.Config, dir string) *ExecRunner {
+	r := NewRunner(cfg.GitExecutable, dir)
+	if timeout, err := cfg.GetGitTimeoutDuration(); err == nil {
+		r.Timeout = timeout
+	}
+	if cfg.Verbose {
+		r.Logger = StdLogger{}
+	}
+	return r
+}
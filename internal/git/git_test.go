@@ -0,0 +1,229 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Debug(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestNewRunnerDefaults(t *testing.T) {
+	r := NewRunner("", "/path/to/repo")
+
+	if r.Executable != "git" {
+		t.Errorf("Expected default executable 'git', got %q", r.Executable)
+	}
+	if r.Dir() != "/path/to/repo" {
+		t.Errorf("Expected dir '/path/to/repo', got %q", r.Dir())
+	}
+}
+
+func TestRunUsesWorkingDirectory(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repoA := testutil.CreateRepository(t)
+	repoB := testutil.CreateRepository(t)
+
+	originalDir, _ := os.Getwd()
+
+	// 複数リポジトリへの並行実行でもプロセスのカレントディレクトリに依存しない。
+	var wg sync.WaitGroup
+	for _, repo := range []string{repoA, repoB} {
+		wg.Add(1)
+		go func(repo string) {
+			defer wg.Done()
+			top, err := Output(context.Background(), NewRunner("git", repo), "rev-parse", "--show-toplevel")
+			if err != nil {
+				t.Errorf("rev-parse failed: %v", err)
+				return
+			}
+			expected, _ := filepath.EvalSymlinks(repo)
+			actual, _ := filepath.EvalSymlinks(top)
+			if actual != expected {
+				t.Errorf("Expected toplevel %q, got %q", expected, actual)
+			}
+		}(repo)
+	}
+	wg.Wait()
+
+	if cwd, _ := os.Getwd(); cwd != originalDir {
+		t.Errorf("Working directory should not change, got %q", cwd)
+	}
+}
+
+func TestRunReturnsStructuredError(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	r := NewRunner("git", repo)
+
+	_, err := r.Run(context.Background(), "rev-parse", "--verify", "refs/heads/does-not-exist")
+	if err == nil {
+		t.Fatal("Expected error for missing ref")
+	}
+
+	var gitErr *Error
+	if !errors.As(err, &gitErr) {
+		t.Fatalf("Expected *git.Error, got %T", err)
+	}
+	if gitErr.ExitCode == 0 || ExitCode(err) != gitErr.ExitCode {
+		t.Errorf("Unexpected exit code: %d", gitErr.ExitCode)
+	}
+	if gitErr.Dir != repo {
+		t.Errorf("Expected dir %q, got %q", repo, gitErr.Dir)
+	}
+	if !strings.Contains(err.Error(), "rev-parse --verify") {
+		t.Errorf("Error message should contain args, got: %v", err)
+	}
+}
+
+func TestRunWithOptionsStdinAndEnv(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	r := NewRunner("git", repo)
+
+	opts := RunOptions{Stdin: strings.NewReader("hello\n")}
+	hash, err := r.RunWithOptions(context.Background(), opts, "hash-object", "--stdin")
+	if err != nil {
+		t.Fatalf("hash-object failed: %v", err)
+	}
+	if strings.TrimSpace(string(hash)) != "ce013625030ba8dba906f756967f9e9ca394464a" {
+		t.Errorf("Unexpected hash: %s", hash)
+	}
+
+	opts = RunOptions{Env: []string{"GIT_AUTHOR_NAME=Env Author"}}
+	ident, err := r.RunWithOptions(context.Background(), opts, "var", "GIT_AUTHOR_IDENT")
+	if err != nil {
+		t.Fatalf("git var failed: %v", err)
+	}
+	if !strings.HasPrefix(string(ident), "Env Author") {
+		t.Errorf("Env should be passed to git, got: %s", ident)
+	}
+}
+
+func TestRunCancelledContext(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	r := NewRunner("git", repo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := r.Run(ctx, "status")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRunLogsInvocations(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	logger := &recordingLogger{}
+	r := NewRunner("git", repo)
+	r.Logger = logger
+
+	r.Run(context.Background(), "status", "--porcelain")
+
+	if len(logger.messages) != 2 {
+		t.Fatalf("Expected start and end log entries, got %v", logger.messages)
+	}
+	if !strings.Contains(logger.messages[0], "git status --porcelain") {
+		t.Errorf("Unexpected log entry: %s", logger.messages[0])
+	}
+}
+
+func TestLines(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	os.WriteFile(filepath.Join(repo, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(repo, "b.txt"), []byte("b"), 0644)
+
+	lines, err := Lines(context.Background(), NewRunner("git", repo), "ls-files", "--others")
+	if err != nil {
+		t.Fatalf("Lines() failed: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Errorf("Expected 2 lines, got %v", lines)
+	}
+}
+
+// TestSucceeds は存在しない ref を false として返し、git 自体が失敗した場合や中断した場合はエラーを返すことをテストする。
+func TestSucceeds(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	r := NewRunner("git", repo)
+	ctx := context.Background()
+
+	if ok, err := Succeeds(ctx, r, "show-ref", "--verify", "--quiet", "refs/heads/"+testutil.Git(t, repo, "branch", "--show-current")); !ok || err != nil {
+		t.Errorf("Succeeds() for existing branch = %v, %v", ok, err)
+	}
+	if ok, err := Succeeds(ctx, r, "show-ref", "--verify", "--quiet", "refs/heads/missing"); ok || err != nil {
+		t.Errorf("Succeeds() for missing branch = %v, %v, want false, nil", ok, err)
+	}
+
+	if _, err := Succeeds(ctx, NewRunner("git", t.TempDir()), "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		t.Error("Succeeds() outside a repository should fail")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Succeeds(canceled, r, "show-ref", "--verify", "--quiet", "refs/heads/missing"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Succeeds() with canceled context = %v, want context.Canceled", err)
+	}
+}
+
+func TestNewConfiguredRunner(t *testing.T) {
+	cfg := &config.Config{
+		GitExecutable: "git",
+		GitTimeout:    "30s",
+		Verbose:       true,
+	}
+
+	r := NewConfiguredRunner(cfg, "/path/to/repo")
+
+	if r.Timeout != 30*time.Second {
+		t.Errorf("Expected timeout 30s, got %v", r.Timeout)
+	}
+	if r.Logger == nil {
+		t.Error("Verbose config should enable git logging")
+	}
+}
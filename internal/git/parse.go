@@ -0,0 +1,141 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// StatusEntry は git status --porcelain=v2 の 1 エントリを表す。
+type StatusEntry struct {
+	// Kind は '1'（通常の変更）、'2'（リネーム・コピー）、'u'（未マージ）、'?'（未追跡）、'!'（無視）のいずれか。
+	Kind byte
+	// XY はインデックスと作業ツリーの状態を表す 2 文字。未追跡・無視の場合は "??" / "!!"。
+	XY       string
+	Path     string
+	OrigPath string
+}
+
+// IsUntracked は未追跡ファイルかを返す。
+func (e StatusEntry) IsUntracked() bool {
+	return e.Kind == '?'
+}
+
+// IsUnmerged はコンフリクト中のファイルかを返す。
+func (e StatusEntry) IsUnmerged() bool {
+	return e.Kind == 'u'
+}
+
+// SplitNUL は -z 出力を NUL 区切りのレコードに分割する。空レコードは除外する。
+func SplitNUL(data []byte) []string {
+	var records []string
+	for _, record := range bytes.Split(data, []byte{0}) {
+		if len(record) > 0 {
+			records = append(records, string(record))
+		}
+	}
+	return records
+}
+
+// Paths は -z 付きで実行した git コマンドの出力をパスの一覧として返す。
+// core.quotePath によるエスケープを受けないため、非 ASCII や空白・改行を含むパスもそのまま得られる。
+func Paths(ctx context.Context, r Runner, args ...string) ([]string, error) {
+	output, err := r.Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return SplitNUL(output), nil
+}
+
+// Status は git status --porcelain=v2 -z を実行して結果を解析する。
+func Status(ctx context.Context, r Runner, extraArgs ...string) ([]StatusEntry, error) {
+	args := append([]string{"status", "--porcelain=v2", "-z"}, extraArgs...)
+	output, err := r.Run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatusV2(output)
+}
+
+// ParseStatusV2 は git status --porcelain=v2 -z の出力を解析する。
+func ParseStatusV2(data []byte) ([]StatusEntry, error) {
+	records := bytes.Split(data, []byte{0})
+
+	var entries []StatusEntry
+	for i := 0; i < len(records); i++ {
+		record := string(records[i])
+		if record == "" {
+			continue
+		}
+
+		switch record[0] {
+		case '#':
+			// --branch 指定時のヘッダー行。
+			continue
+		case '1':
+			fields := strings.SplitN(record, " ", 9)
+			if len(fields) != 9 {
+				return nil, fmt.Errorf("malformed status entry: %q", record)
+			}
+			entries = append(entries, StatusEntry{Kind: '1', XY: fields[1], Path: fields[8]})
+		case '2':
+			fields := strings.SplitN(record, " ", 10)
+			if len(fields) != 10 {
+				return nil, fmt.Errorf("malformed status entry: %q", record)
+			}
+			// 元のパスは次の NUL 区切りレコードに格納される。
+			if i+1 >= len(records) || len(records[i+1]) == 0 {
+				return nil, fmt.Errorf("missing original path for rename entry: %q", record)
+			}
+			i++
+			entries = append(entries, StatusEntry{Kind: '2', XY: fields[1], Path: fields[9], OrigPath: string(records[i])})
+		case 'u':
+			fields := strings.SplitN(record, " ", 11)
+			if len(fields) != 11 {
+				return nil, fmt.Errorf("malformed status entry: %q", record)
+			}
+			entries = append(entries, StatusEntry{Kind: 'u', XY: fields[1], Path: fields[10]})
+		case '?', '!':
+			if len(record) < 3 {
+				return nil, fmt.Errorf("malformed status entry: %q", record)
+			}
+			entries = append(entries, StatusEntry{Kind: record[0], XY: record[0:1] + record[0:1], Path: record[2:]})
+		default:
+			return nil, fmt.Errorf("unknown status entry: %q", record)
+		}
+	}
+
+	return entries, nil
+}
+
+// PathspecStdin は pathspec を NUL 区切りで標準入力から渡すための RunOptions を返す。
+// --pathspec-from-file=- --pathspec-file-nul と組み合わせて使用する。
+func PathspecStdin(paths []string) RunOptions {
+	var buf bytes.Buffer
+	for _, p := range paths {
+		buf.WriteString(p)
+		buf.WriteByte(0)
+	}
+	return RunOptions{Stdin: &buf}
+}
+
+// AddPaths は指定したパスのみをステージする。削除されたパスは削除としてステージされる。
+// パスはグロブとして解釈されない。
+func AddPaths(ctx context.Context, r Runner, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	_, err := r.RunWithOptions(ctx, PathspecStdin(paths),
+		"--literal-pathspecs", "add", "-A", "--pathspec-from-file=-", "--pathspec-file-nul")
+	return err
+}
+
+// CommitPaths は指定したパスのみを対象にコミットする。
+// 他にステージ済みの変更があってもコミットには含まれない。
+func CommitPaths(ctx context.Context, r Runner, paths []string, args ...string) error {
+	commitArgs := append([]string{"--literal-pathspecs", "commit"}, args...)
+	commitArgs = append(commitArgs, "--pathspec-from-file=-", "--pathspec-file-nul")
+	_, err := r.RunWithOptions(ctx, PathspecStdin(paths), commitArgs...)
+	return err
+}
@@ -0,0 +1,111 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func TestSplitNUL(t *testing.T) {
+	data := []byte("a.txt\x00日本語.cpp\x00with space.h\x00")
+
+	got := SplitNUL(data)
+	want := []string{"a.txt", "日本語.cpp", "with space.h"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitNUL() = %q, want %q", got, want)
+	}
+
+	if got := SplitNUL(nil); len(got) != 0 {
+		t.Errorf("SplitNUL(nil) should be empty, got %q", got)
+	}
+}
+
+func TestParseStatusV2(t *testing.T) {
+	data := []byte(
+		"# branch.oid 0123456789abcdef\x00" +
+			"1 .M N... 100644 100644 100644 aaaa bbbb src/main.cpp\x00" +
+			"2 R. N... 100644 100644 100644 aaaa bbbb R100 new name.h\x00old name.h\x00" +
+			"u UU N... 100644 100644 100644 100644 aaaa bbbb cccc conflict.cpp\x00" +
+			"? 日本語 ファイル.cpp\x00" +
+			"! build/out.o\x00")
+
+	entries, err := ParseStatusV2(data)
+	if err != nil {
+		t.Fatalf("ParseStatusV2() failed: %v", err)
+	}
+
+	want := []StatusEntry{
+		{Kind: '1', XY: ".M", Path: "src/main.cpp"},
+		{Kind: '2', XY: "R.", Path: "new name.h", OrigPath: "old name.h"},
+		{Kind: 'u', XY: "UU", Path: "conflict.cpp"},
+		{Kind: '?', XY: "??", Path: "日本語 ファイル.cpp"},
+		{Kind: '!', XY: "!!", Path: "build/out.o"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseStatusV2() = %+v, want %+v", entries, want)
+	}
+
+	if !entries[2].IsUnmerged() || !entries[3].IsUntracked() {
+		t.Error("Entry kind helpers returned unexpected values")
+	}
+}
+
+func TestParseStatusV2Malformed(t *testing.T) {
+	tests := [][]byte{
+		[]byte("1 .M N...\x00"),
+		[]byte("2 R. N... 100644 100644 100644 aaaa bbbb R100 new.h\x00"),
+		[]byte("x unknown\x00"),
+	}
+
+	for _, data := range tests {
+		if _, err := ParseStatusV2(data); err == nil {
+			t.Errorf("ParseStatusV2(%q) should fail", data)
+		}
+	}
+}
+
+func TestPathsWithSpecialCharacters(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	names := []string{"日本語.cpp", "with space.h", `quote"d.h`}
+	if runtime.GOOS != "windows" {
+		names = append(names, "new\nline.cpp")
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("Failed to create %q: %v", name, err)
+		}
+	}
+
+	r := NewRunner("git", repo)
+	paths, err := Paths(context.Background(), r, "ls-files", "-z", "--others")
+	if err != nil {
+		t.Fatalf("Paths() failed: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, p := range paths {
+		found[p] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			t.Errorf("Path %q not returned verbatim, got %q", name, paths)
+		}
+	}
+
+	entries, err := Status(context.Background(), r)
+	if err != nil {
+		t.Fatalf("Status() failed: %v", err)
+	}
+	if len(entries) != len(names) {
+		t.Errorf("Expected %d status entries, got %+v", len(names), entries)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+// +build !windows
+
+package git
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// killTreeOnCancel は cmd を独自のプロセスグループで起動し、ctx の終了時にグループ全体を終了させるよう設定する。
+// 端末の Ctrl+C は git へ直接届かなくなり、終了は常にこの経路で行われる。
+// まず SIGTERM を送って git にロックファイルを片付けさせ、killGracePeriod 後も残っていれば SIGKILL で終了させる。
+// 戻り値の関数は cmd の終了後に呼び、未送信の SIGKILL を取り消す。
+func killTreeOnCancel(cmd *exec.Cmd) func() {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var timer *time.Timer
+	cmd.Cancel = func() error {
+		// 負の PID はプロセスグループ全体（git が起動したフックやエディタを含む）を表す。
+		pgid := -cmd.Process.Pid
+		timer = time.AfterFunc(killGracePeriod, func() {
+			syscall.Kill(pgid, syscall.SIGKILL)
+		})
+		return syscall.Kill(pgid, syscall.SIGTERM)
+	}
+
+	return func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
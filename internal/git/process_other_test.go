@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestRunTimeoutKillsProcessTree はタイムアウト時に git が起動した子孫プロセスも終了させることをテストする。
+func TestRunTimeoutKillsProcessTree(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "child.pid")
+
+	// SIGTERM を無視し、孫プロセスを残したまま止まる git の代わり。
+	script := filepath.Join(dir, "hung-git")
+	content := "#!/bin/sh\ntrap '' TERM\nsleep 60 &\necho $! > " + pidFile + "\nwait\n"
+	if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+
+	r := NewRunner(script, dir)
+	r.Timeout = 200 * time.Millisecond
+
+	started := time.Now()
+	_, err := r.Run(context.Background(), "status")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > killGracePeriod+3*time.Second {
+		t.Errorf("Run() returned after %v", elapsed)
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("Failed to read child pid: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("Invalid child pid %q: %v", data, err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("Child process %d should have been killed", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
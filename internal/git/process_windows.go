@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package git
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// killTreeOnCancel は cmd を独自のプロセスグループで起動し、ctx の終了時に子孫プロセスを含めて終了させるよう設定する。
+// コンソールの Ctrl+C は git へ直接届かなくなり、終了は常にこの経路で行われる。
+// 戻り値の関数は cmd の終了後に呼ぶ。Windows では後処理は無い。
+func killTreeOnCancel(cmd *exec.Cmd) func() {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+	return func() {}
+}
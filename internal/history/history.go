@@ -0,0 +1,260 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// StateFile は実行履歴を追記する、Dev リポジトリの git ディレクトリからの相対パス。
+// Ops リポジトリ（VHDX 上など）が使えないときの失敗も記録できるよう、Dev 側に置く。
+const StateFile = "fcsm/history.jsonl"
+
+// 記録する処理の種類。
+const (
+	TypeSync     = "sync"
+	TypeFixup    = "fixup"
+	TypeSnapshot = "snapshot"
+	// TypeError は絞り込みにのみ使い、種類を問わず失敗した実行を表す。
+	TypeError = "error"
+)
+
+// Types は絞り込みに指定できる種類の一覧。
+var Types = []string{TypeSync, TypeFixup, TypeSnapshot, TypeError}
+
+// Entry は sync / fixup / snapshot の 1 回の実行結果。
+type Entry struct {
+	// Time は実行を開始した時刻。
+	Time     time.Time     `json:"time"`
+	Type     string        `json:"type"`
+	Duration time.Duration `json:"duration"`
+	Branch   string        `json:"branch,omitempty"`
+	// Files は同期・fixup したファイル。
+	Files []string `json:"files,omitempty"`
+	// Commits は作成したコミット（同期コミット、fixup コミット）。
+	Commits   []string `json:"commits,omitempty"`
+	BackupRef string   `json:"backupRef,omitempty"`
+	Snapshot  string   `json:"snapshot,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	// Cause は失敗の原因の分類（metrics.Cause）。
+	Cause string `json:"cause,omitempty"`
+}
+
+// Failed は実行が失敗したかを返す。
+func (e Entry) Failed() bool {
+	return e.Error != ""
+}
+
+// Filter は履歴の絞り込み条件。空の項目では絞り込まない。
+type Filter struct {
+	// Type は種類（sync / fixup / snapshot）、または失敗した実行を表す error。
+	Type   string
+	Branch string
+	// Since と Until は開始時刻の範囲。Since を含み Until を含まない。
+	Since time.Time
+	Until time.Time
+}
+
+// Match は entry が条件に一致するかを返す。
+func (f Filter) Match(entry Entry) bool {
+	switch {
+	case f.Type == TypeError && !entry.Failed():
+		return false
+	case f.Type != "" && f.Type != TypeError && f.Type != entry.Type:
+		return false
+	case f.Branch != "" && f.Branch != entry.Branch:
+		return false
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// DefaultMaxSize は状態ファイルを切り替える既定の大きさ。1 件はおよそ数百バイトのため、数千件に相当する。
+const DefaultMaxSize = 1 << 20
+
+// readChunkSize は状態ファイルを末尾から読む際に一度に読む大きさ。
+const readChunkSize = 64 << 10
+
+// Log は実行履歴を Dev リポジトリの状態ファイルへ JSON Lines 形式で追記する。追記のみで書き換えは行わない。
+// Ops リポジトリが長時間使えずに失敗が続いても大きくなり続けないよう、MaxSize を超えたら
+// 状態ファイルを 1 世代前のファイル（<状態ファイル>.1）に切り替え、それより古い記録は削除する。
+type Log struct {
+	git git.Runner
+	// MaxSize は状態ファイルを切り替える大きさ。0 以下の場合は切り替えない。
+	MaxSize int64
+}
+
+// NewLog は Dev リポジトリの runner の履歴を返す。
+func NewLog(runner git.Runner) *Log {
+	return &Log{git: runner, MaxSize: DefaultMaxSize}
+}
+
+// Path は状態ファイルの絶対パスを返す。
+func (l *Log) Path(ctx context.Context) (string, error) {
+	path, err := git.Output(ctx, l.git, "rev-parse", "--git-path", StateFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve history file: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.git.Dir(), path)
+	}
+	return path, nil
+}
+
+// Append は entry を状態ファイルに追記する。1 行を 1 回の書き込みで追記するため、
+// 複数のプロセスが同時に追記しても行が混ざらない。
+func (l *Log) Append(ctx context.Context, entry Entry) error {
+	path, err := l.Path(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode history entry: %w", err)
+	}
+	if err := l.rotate(path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// rotate は状態ファイルが MaxSize 以上であれば 1 世代前のファイルへ移す。
+// 別のプロセスが先に移した場合は何もしない。
+func (l *Log) rotate(path string) error {
+	if l.MaxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.Size() < l.MaxSize) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check history file: %w", err)
+	}
+	if err := os.Rename(path, previousPath(path)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate history file: %w", err)
+	}
+	return nil
+}
+
+// previousPath は状態ファイル path の 1 世代前のファイルのパスを返す。
+func previousPath(path string) string {
+	return path + ".1"
+}
+
+// Read は 1 世代前のファイルと状態ファイルから filter に一致する記録を古い順に返す。ファイルが無い場合は空。
+// 書き込み途中で終了したなどで壊れた行は読み飛ばす。
+func (l *Log) Read(ctx context.Context, filter Filter) ([]Entry, error) {
+	path, err := l.Path(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, file := range []string{previousPath(path), path} {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history file: %w", err)
+		}
+
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			if entry, ok := parseLine(line); ok && filter.Match(entry) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Last は種類 entryType の最新の記録を返す。記録が無い場合は nil。
+// 状態ファイル全体は読まず、末尾から一致する記録が見つかるまで読む。
+func (l *Log) Last(ctx context.Context, entryType string) (*Entry, error) {
+	path, err := l.Path(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := Filter{Type: entryType}
+	for _, file := range []string{path, previousPath(path)} {
+		entry, err := lastMatch(file, filter)
+		if err != nil || entry != nil {
+			return entry, err
+		}
+	}
+	return nil, nil
+}
+
+// lastMatch はファイル path を末尾から readChunkSize ずつ読み、filter に一致する最も新しい記録を返す。
+func lastMatch(path string, filter Filter) (*Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	// rest は前に読んだ範囲の先頭にある、行頭がまだ読めていない行。
+	var rest []byte
+	for offset := info.Size(); offset > 0; {
+		size := min(int64(readChunkSize), offset)
+		offset -= size
+		chunk := make([]byte, size, size+int64(len(rest)))
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return nil, fmt.Errorf("failed to read history file: %w", err)
+		}
+
+		lines := bytes.Split(append(chunk, rest...), []byte{'\n'})
+		first := 0
+		if offset > 0 {
+			rest, first = lines[0], 1
+		}
+		for i := len(lines) - 1; i >= first; i-- {
+			if entry, ok := parseLine(lines[i]); ok && filter.Match(entry) {
+				return &entry, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// parseLine は状態ファイルの 1 行を記録として読む。空行と壊れた行は false を返す。
+func parseLine(line []byte) (Entry, bool) {
+	var entry Entry
+	if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &entry) != nil {
+		return Entry{}, false
+	}
+	return entry, true
+}
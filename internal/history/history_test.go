@@ -0,0 +1,180 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func newTestLog(t *testing.T) *Log {
+	t.Helper()
+	repo := t.TempDir()
+	testutil.InitRepository(t, repo)
+	return NewLog(git.NewRunner("git", repo))
+}
+
+func TestAppendAndRead(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	ctx := context.Background()
+	log := newTestLog(t)
+	if entries, err := log.Read(ctx, Filter{}); err != nil || len(entries) != 0 {
+		t.Fatalf("Read() without a file = %v, %v; want empty", entries, err)
+	}
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	written := []Entry{
+		{Time: start, Type: TypeSync, Duration: time.Second, Branch: "main", Files: []string{"a.cpp"}, Commits: []string{"abc"}},
+		{Time: start.Add(time.Hour), Type: TypeFixup, Branch: "main", Error: "rebase failed", Cause: "rebase"},
+		{Time: start.Add(2 * time.Hour), Type: TypeSnapshot, Snapshot: "manual"},
+	}
+	for _, entry := range written {
+		if err := log.Append(ctx, entry); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+
+	// 書き込み途中で終了した行は読み飛ばす。
+	path, _ := log.Path(ctx)
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	file.WriteString(`{"time":"2024-01-01T`)
+	file.Close()
+
+	entries, err := log.Read(ctx, Filter{})
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(entries) != 3 || entries[0].Commits[0] != "abc" || entries[1].Cause != "rebase" || entries[2].Snapshot != "manual" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	last, err := log.Last(ctx, TypeFixup)
+	if err != nil || last == nil || last.Error != "rebase failed" {
+		t.Errorf("Last(fixup) = %+v, %v", last, err)
+	}
+	if last, err := log.Last(ctx, TypeError); err != nil || last == nil || last.Type != TypeFixup {
+		t.Errorf("Last(error) = %+v, %v; want the failed fixup", last, err)
+	}
+}
+
+// TestRotate は状態ファイルが MaxSize を超えると 1 世代前のファイルへ切り替え、それより古い記録を削除することをテストする。
+func TestRotate(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	ctx := context.Background()
+	log := newTestLog(t)
+	log.MaxSize = 1
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		entry := Entry{Time: start.Add(time.Duration(i) * time.Hour), Type: TypeSync, Error: fmt.Sprintf("failure %d", i)}
+		if err := log.Append(ctx, entry); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+
+	entries, err := log.Read(ctx, Filter{})
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Error != "failure 1" || entries[1].Error != "failure 2" {
+		t.Errorf("Expected the current and previous files only, got %+v", entries)
+	}
+
+	// 状態ファイルに一致する記録が無い場合は 1 世代前のファイルから探す。
+	if err := log.Append(ctx, Entry{Time: start.Add(3 * time.Hour), Type: TypeSnapshot}); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	if last, err := log.Last(ctx, TypeSync); err != nil || last == nil || last.Error != "failure 2" {
+		t.Errorf("Last(sync) = %+v, %v; want the entry in the previous file", last, err)
+	}
+}
+
+// TestLastReadsFromEnd は読み込み単位をまたぐ大きな状態ファイルでも、末尾から最新の記録を返すことをテストする。
+func TestLastReadsFromEnd(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	ctx := context.Background()
+	log := newTestLog(t)
+	path, err := log.Path(ctx)
+	if err != nil {
+		t.Fatalf("Path() failed: %v", err)
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create history file: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	var size int
+	for i := 0; size < 3*readChunkSize; i++ {
+		entryType := TypeSync
+		if i == 0 {
+			entryType = TypeFixup
+		}
+		data, _ := json.Marshal(Entry{Time: start.Add(time.Duration(i) * time.Minute), Type: entryType, Branch: fmt.Sprintf("branch-%d", i)})
+		n, _ := file.Write(append(data, '\n'))
+		size += n
+	}
+	file.Close()
+
+	last, err := log.Last(ctx, TypeFixup)
+	if err != nil || last == nil || last.Branch != "branch-0" {
+		t.Errorf("Last(fixup) = %+v, %v; want the first entry", last, err)
+	}
+	last, err = log.Last(ctx, TypeSync)
+	if err != nil || last == nil {
+		t.Fatalf("Last(sync) = %+v, %v", last, err)
+	}
+	entries, _ := log.Read(ctx, Filter{Type: TypeSync})
+	if last.Branch != entries[len(entries)-1].Branch {
+		t.Errorf("Last(sync) = %s, want %s", last.Branch, entries[len(entries)-1].Branch)
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := Entry{Time: at, Type: TypeSync, Branch: "feature"}
+	failed := Entry{Time: at, Type: TypeSnapshot, Error: "disk full"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		entry  Entry
+		want   bool
+	}{
+		{"empty", Filter{}, entry, true},
+		{"type", Filter{Type: TypeSync}, entry, true},
+		{"other type", Filter{Type: TypeFixup}, entry, false},
+		{"error excludes success", Filter{Type: TypeError}, entry, false},
+		{"error matches any failure", Filter{Type: TypeError}, failed, true},
+		{"branch", Filter{Branch: "feature"}, entry, true},
+		{"other branch", Filter{Branch: "main"}, entry, false},
+		{"since is inclusive", Filter{Since: at}, entry, true},
+		{"before since", Filter{Since: at.Add(time.Second)}, entry, false},
+		{"until is exclusive", Filter{Until: at}, entry, false},
+		{"before until", Filter{Until: at.Add(time.Second)}, entry, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.entry); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
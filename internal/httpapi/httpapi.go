@@ -0,0 +1,224 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/history"
+)
+
+// PathPrefix は API のパスの接頭辞。
+const PathPrefix = "/api/v1/"
+
+// DefaultHistoryLimit は limit を指定しない場合に返す実行履歴の最大件数。
+const DefaultHistoryLimit = 100
+
+// Backend は API が参照・操作する run デーモン。
+type Backend interface {
+	// Status は現在の状態を返す。
+	Status() *control.Status
+	// History は Dev リポジトリの実行履歴から filter に一致する記録を古い順に返す。
+	History(ctx context.Context, filter history.Filter) ([]history.Entry, error)
+	// Handle は制御要求を実行して応答を返す。sync / fixup / snapshot は完了まで待つ。
+	Handle(req control.Request) control.Response
+}
+
+// Results は sync / fixup / snapshot それぞれの直近の実行結果。
+type Results struct {
+	Sync     *control.RunInfo `json:"sync"`
+	Fixup    *control.RunInfo `json:"fixup"`
+	Snapshot *control.RunInfo `json:"snapshot"`
+}
+
+// errorResponse はエラー時の応答本文。
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// triggers は POST で実行できる処理。
+var triggers = map[string]bool{
+	control.CommandSync:     true,
+	control.CommandFixup:    true,
+	control.CommandSnapshot: true,
+}
+
+// NewHandler は token で認証する API の http.Handler を返す。
+//
+//	GET  /api/v1/status          現在の状態
+//	GET  /api/v1/results         sync / fixup / snapshot の直近の結果
+//	GET  /api/v1/history         実行履歴（新しい順）。limit、type、branch、since、until で絞り込む
+//	POST /api/v1/sync            同期を実行
+//	POST /api/v1/fixup           fixup を実行
+//	POST /api/v1/snapshot        VHDX スナップショットを作成
+func NewHandler(backend Backend, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathPrefix+"status", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, backend.Status())
+	})
+	mux.HandleFunc(PathPrefix+"results", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		status := backend.Status()
+		writeJSON(w, http.StatusOK, Results{Sync: status.LastSync, Fixup: status.LastFixup, Snapshot: status.LastSnapshot})
+	})
+	mux.HandleFunc(PathPrefix+"history", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		filter, limit, err := historyQuery(r.URL.Query())
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+		entries, err := backend.History(r.Context(), filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+
+		newestFirst := make([]history.Entry, 0, len(entries))
+		for i := len(entries) - 1; i >= 0 && (limit == 0 || len(newestFirst) < limit); i-- {
+			newestFirst = append(newestFirst, entries[i])
+		}
+		writeJSON(w, http.StatusOK, newestFirst)
+	})
+	mux.HandleFunc(PathPrefix, func(w http.ResponseWriter, r *http.Request) {
+		command := strings.TrimPrefix(r.URL.Path, PathPrefix)
+		if !triggers[command] {
+			writeJSON(w, http.StatusNotFound, errorResponse{Error: "not found: " + r.URL.Path})
+			return
+		}
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+
+		resp := backend.Handle(control.Request{Command: command})
+		code := http.StatusOK
+		if !resp.OK {
+			code = http.StatusInternalServerError
+		}
+		writeJSON(w, code, resp)
+	})
+
+	return authenticate(mux, token)
+}
+
+// historyQuery は実行履歴の絞り込み条件と返す件数の上限を解析する。
+// type は sync / fixup / snapshot / error、since と until は RFC 3339 形式の時刻。limit=0 は無制限。
+func historyQuery(query url.Values) (history.Filter, int, error) {
+	filter := history.Filter{Type: query.Get("type"), Branch: query.Get("branch")}
+	if filter.Type != "" {
+		valid := false
+		for _, t := range history.Types {
+			valid = valid || t == filter.Type
+		}
+		if !valid {
+			return filter, 0, fmt.Errorf("invalid type: %s (use %s)", filter.Type, strings.Join(history.Types, ", "))
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, 0, fmt.Errorf("invalid %s: %s (use RFC 3339)", param.name, value)
+		}
+		*param.dst = t
+	}
+
+	limit := DefaultHistoryLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return filter, 0, fmt.Errorf("invalid limit: %s", value)
+		}
+		limit = n
+	}
+	return filter, limit, nil
+}
+
+// authenticate は Authorization: Bearer <token> ヘッダーを検証する。
+func authenticate(next http.Handler, token string) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fcsm"`)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed: " + r.Method})
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// Server は API の HTTP サーバー。
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// Start は addr で API の待ち受けを開始する。
+func Start(addr, token string, backend Backend) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s := &Server{
+		server: &http.Server{
+			Handler:           NewHandler(backend, token),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		listener: listener,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("HTTP API server stopped: %v\n", err)
+		}
+	}()
+	return s, nil
+}
+
+// Addr は待ち受けているアドレスを返す。ポート 0 を指定した場合に実際のポートを知るために使う。
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown は新しい要求の受け付けを止め、処理中の要求の完了を待って終了する。
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,219 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/history"
+)
+
+type fakeBackend struct {
+	status   control.Status
+	history  []history.Entry
+	requests []string
+}
+
+func (b *fakeBackend) Status() *control.Status {
+	status := b.status
+	return &status
+}
+
+func (b *fakeBackend) History(ctx context.Context, filter history.Filter) ([]history.Entry, error) {
+	var entries []history.Entry
+	for _, entry := range b.history {
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (b *fakeBackend) Handle(req control.Request) control.Response {
+	b.requests = append(b.requests, req.Command)
+	if req.Command == control.CommandSnapshot {
+		return control.Response{Error: "snapshot failed: vhdxPath is not configured"}
+	}
+	return control.Response{OK: true, Message: req.Command + " completed"}
+}
+
+func newTestBackend() *fakeBackend {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &fakeBackend{
+		status: control.Status{
+			PID:       42,
+			LastSync:  &control.RunInfo{Operation: control.CommandSync, Time: now},
+			LastFixup: &control.RunInfo{Operation: control.CommandFixup, Time: now, Error: "conflict"},
+		},
+		history: []history.Entry{
+			{Type: history.TypeSync, Time: now, Branch: "main"},
+			{Type: history.TypeFixup, Time: now.Add(time.Minute), Branch: "main", Error: "conflict"},
+			{Type: history.TypeSync, Time: now.Add(2 * time.Minute), Branch: "feature"},
+		},
+	}
+}
+
+func request(t *testing.T, handler http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthentication(t *testing.T) {
+	backend := newTestBackend()
+	handler := NewHandler(backend, "secret")
+
+	for _, token := range []string{"", "wrong", "secret2"} {
+		rec := request(t, handler, http.MethodPost, "/api/v1/sync", token)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: expected 401, got %d", token, rec.Code)
+		}
+	}
+	if len(backend.requests) != 0 {
+		t.Errorf("Unauthenticated requests should not reach the backend: %v", backend.requests)
+	}
+
+	// トークンが空の場合はすべて拒否する。
+	if rec := request(t, NewHandler(backend, ""), http.MethodGet, "/api/v1/status", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Empty token should reject every request, got %d", rec.Code)
+	}
+}
+
+func TestStatusAndResults(t *testing.T) {
+	handler := NewHandler(newTestBackend(), "secret")
+
+	rec := request(t, handler, http.MethodGet, "/api/v1/status", "secret")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Unexpected status response: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var status control.Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || status.PID != 42 {
+		t.Errorf("Unexpected status body: %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = request(t, handler, http.MethodGet, "/api/v1/results", "secret")
+	var results Results
+	if err := json.Unmarshal(rec.Body.Bytes(), &results); err != nil {
+		t.Fatalf("Failed to decode results: %v", err)
+	}
+	if results.Sync == nil || results.Fixup == nil || results.Fixup.Error != "conflict" || results.Snapshot != nil {
+		t.Errorf("Unexpected results: %s", rec.Body.String())
+	}
+
+	if rec := request(t, handler, http.MethodPost, "/api/v1/status", "secret"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status: expected 405, got %d", rec.Code)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	handler := NewHandler(newTestBackend(), "secret")
+
+	tests := []struct {
+		query string
+		code  int
+		count int
+	}{
+		{"", http.StatusOK, 3},
+		{"?limit=2", http.StatusOK, 2},
+		{"?limit=10", http.StatusOK, 3},
+		{"?limit=-1", http.StatusBadRequest, 0},
+		{"?limit=abc", http.StatusBadRequest, 0},
+		{"?limit=0", http.StatusOK, 3},
+		{"?type=sync", http.StatusOK, 2},
+		{"?type=error", http.StatusOK, 1},
+		{"?type=bogus", http.StatusBadRequest, 0},
+		{"?branch=main", http.StatusOK, 2},
+		{"?since=2024-01-02T03:05:00Z", http.StatusOK, 2},
+		{"?since=2024-01-02T03:05:00Z&until=2024-01-02T03:06:00Z", http.StatusOK, 1},
+		{"?until=yesterday", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		rec := request(t, handler, http.MethodGet, "/api/v1/history"+tt.query, "secret")
+		if rec.Code != tt.code {
+			t.Errorf("%q: expected %d, got %d", tt.query, tt.code, rec.Code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var history []history.Entry
+		if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil {
+			t.Fatalf("%q: failed to decode history: %v", tt.query, err)
+		}
+		if len(history) != tt.count {
+			t.Errorf("%q: expected %d entries, got %d", tt.query, tt.count, len(history))
+		}
+		// 新しい順に返す。
+		if len(history) > 1 && !history[0].Time.After(history[1].Time) {
+			t.Errorf("%q: history should be newest first: %s", tt.query, rec.Body.String())
+		}
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	backend := newTestBackend()
+	handler := NewHandler(backend, "secret")
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodPost, "/api/v1/sync", http.StatusOK},
+		{http.MethodPost, "/api/v1/fixup", http.StatusOK},
+		{http.MethodPost, "/api/v1/snapshot", http.StatusInternalServerError},
+		{http.MethodGet, "/api/v1/sync", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/stop", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/pause", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rec := request(t, handler, tt.method, tt.path, "secret")
+		if rec.Code != tt.code {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.code, rec.Code)
+		}
+	}
+
+	if got := strings.Join(backend.requests, ","); got != "sync,fixup,snapshot" {
+		t.Errorf("Unexpected backend requests: %s", got)
+	}
+}
+
+func TestStartAndShutdown(t *testing.T) {
+	server, err := Start("127.0.0.1:0", "secret", newTestBackend())
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/api/v1/status", server.Addr()), nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() failed: %v", err)
+	}
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Error("Server should not accept requests after shutdown")
+	}
+}
@@ -0,0 +1,125 @@
+package indexlock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/notify"
+)
+
+// DefaultStaleAfter は設定が無い場合に index.lock を放置されたとみなす経過時間。
+const DefaultStaleAfter = 10 * time.Minute
+
+// Result は index.lock の検査結果を表す。
+type Result struct {
+	Path    string
+	Age     time.Duration
+	Removed bool
+	// Reason は削除を見送った理由。削除した場合は空。
+	Reason string
+}
+
+// Recoverer はクラッシュした git プロセスなどが残した index.lock を検出して除去する。
+type Recoverer struct {
+	git        git.Runner
+	staleAfter time.Duration
+	notifier   *notify.Notifier
+	// gitRunning はリポジトリで動作中の git プロセスがあるかを返す。テストで差し替える。
+	gitRunning func(repoDir string) (bool, error)
+}
+
+func NewRecoverer(cfg *config.Config, runner git.Runner) *Recoverer {
+	staleAfter, err := cfg.GetIndexLockStaleAfterDuration()
+	if err != nil || staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	return &Recoverer{
+		git:        runner,
+		staleAfter: staleAfter,
+		notifier:   notify.NewNotifier(cfg.NotifyOnError),
+		gitRunning: gitProcessRunning,
+	}
+}
+
+// Check は index.lock を検査し、閾値より古く所有する git プロセスが無い場合に削除する。
+// index.lock が存在しない場合は nil を返す。
+func (r *Recoverer) Check(ctx context.Context) (*Result, error) {
+	lockPath, err := r.lockPath(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(lockPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", lockPath, err)
+	}
+
+	result := &Result{Path: lockPath, Age: time.Since(info.ModTime())}
+	if result.Age < r.staleAfter {
+		result.Reason = fmt.Sprintf("lock is younger than %v", r.staleAfter)
+		return result, nil
+	}
+
+	running, err := r.gitRunning(r.git.Dir())
+	if err != nil {
+		// 所有者を確認できない場合は安全側に倒して削除しない。
+		result.Reason = fmt.Sprintf("cannot verify git processes: %v", err)
+		return result, nil
+	}
+	if running {
+		result.Reason = "a git process is still running in the repository"
+		return result, nil
+	}
+
+	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		return result, fmt.Errorf("failed to remove stale %s: %w", lockPath, err)
+	}
+	result.Removed = true
+	return result, nil
+}
+
+// Recover は Check を実行し、除去した場合はログ出力と通知を行う。
+// operation は通知に含める処理名（sync / fixup）。
+func (r *Recoverer) Recover(ctx context.Context, operation string) error {
+	result, err := r.Check(ctx)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+
+	if !result.Removed {
+		fmt.Printf("Found index.lock (age %v), leaving it in place: %s\n", result.Age.Round(time.Second), result.Reason)
+		return nil
+	}
+
+	fmt.Printf("Removed stale index.lock (age %v): %s\n", result.Age.Round(time.Second), result.Path)
+	r.notifier.NotifyInfo("Stale index.lock removed",
+		fmt.Sprintf("Recovered from a stale index.lock before %s", operation),
+		map[string]string{
+			"Path": result.Path,
+			"Age":  result.Age.Round(time.Second).String(),
+		})
+	return nil
+}
+
+// lockPath は index.lock のパスを返す。worktree でも正しく解決できるよう git に問い合わせる。
+func (r *Recoverer) lockPath(ctx context.Context) (string, error) {
+	path, err := git.Output(ctx, r.git, "rev-parse", "--git-path", "index.lock")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve index.lock path: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.git.Dir(), path)
+	}
+	return path, nil
+}
@@ -0,0 +1,122 @@
+package indexlock
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func TestCheckWithoutLock(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	r := newTestRecoverer(repo, false)
+
+	result, err := r.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check() failed: %v", err)
+	}
+	if result != nil {
+		t.Errorf("Expected nil result without index.lock, got %+v", result)
+	}
+}
+
+func TestCheckRemovesStaleLock(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	lockPath := createLock(t, repo, time.Hour)
+
+	result, err := newTestRecoverer(repo, false).Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check() failed: %v", err)
+	}
+	if result == nil || !result.Removed {
+		t.Fatalf("Expected stale lock to be removed, got %+v", result)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Error("index.lock should be deleted")
+	}
+}
+
+func TestCheckKeepsFreshLock(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	lockPath := createLock(t, repo, 0)
+
+	result, err := newTestRecoverer(repo, false).Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check() failed: %v", err)
+	}
+	if result == nil || result.Removed || result.Reason == "" {
+		t.Fatalf("Expected fresh lock to be kept with a reason, got %+v", result)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Error("Fresh index.lock should be kept")
+	}
+}
+
+func TestCheckKeepsLockOwnedByRunningGit(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	lockPath := createLock(t, repo, time.Hour)
+
+	result, err := newTestRecoverer(repo, true).Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check() failed: %v", err)
+	}
+	if result == nil || result.Removed {
+		t.Fatalf("Expected lock of a running git process to be kept, got %+v", result)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Error("index.lock owned by a running git should be kept")
+	}
+}
+
+func TestNewRecovererDefaults(t *testing.T) {
+	r := NewRecoverer(&config.Config{}, git.NewRunner("git", t.TempDir()))
+	if r.staleAfter != DefaultStaleAfter {
+		t.Errorf("Expected default threshold %v, got %v", DefaultStaleAfter, r.staleAfter)
+	}
+
+	r = NewRecoverer(&config.Config{IndexLockStaleAfter: "30s"}, git.NewRunner("git", t.TempDir()))
+	if r.staleAfter != 30*time.Second {
+		t.Errorf("Expected threshold 30s, got %v", r.staleAfter)
+	}
+}
+
+func newTestRecoverer(repo string, running bool) *Recoverer {
+	r := NewRecoverer(&config.Config{IndexLockStaleAfter: "1m"}, git.NewRunner("git", repo))
+	r.gitRunning = func(string) (bool, error) {
+		return running, nil
+	}
+	return r
+}
+
+func createLock(t *testing.T, repo string, age time.Duration) string {
+	lockPath := filepath.Join(repo, ".git", "index.lock")
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to create index.lock: %v", err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(lockPath, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set index.lock mtime: %v", err)
+	}
+	return lockPath
+}
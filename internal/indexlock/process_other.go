@@ -0,0 +1,62 @@
+//go:build !windows
+// +build !windows
+
+package indexlock
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gitProcessRunning はリポジトリ配下をカレントディレクトリとする git プロセスがあるかを返す。
+// /proc が無い環境では pgrep で git プロセスの有無のみを確認する。
+func gitProcessRunning(repoDir string) (bool, error) {
+	pids, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil || len(pids) == 0 {
+		return pgrepGit()
+	}
+
+	repo, err := filepath.EvalSymlinks(repoDir)
+	if err != nil {
+		repo = repoDir
+	}
+
+	self := strconv.Itoa(os.Getpid())
+	for _, procDir := range pids {
+		if filepath.Base(procDir) == self {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procDir, "comm"))
+		if err != nil || strings.TrimSpace(string(comm)) != "git" {
+			continue
+		}
+		cwd, err := os.Readlink(filepath.Join(procDir, "cwd"))
+		if err != nil {
+			// 他ユーザーのプロセスなど確認できないものは所有者の可能性があるとみなす。
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return true, nil
+		}
+		if cwd == repo || strings.HasPrefix(cwd, repo+string(filepath.Separator)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func pgrepGit() (bool, error) {
+	err := exec.Command("pgrep", "-x", "git").Run()
+	if err == nil {
+		return true, nil
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return false, err
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package indexlock
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// gitProcessRunning は git.exe が実行中かを返す。
+// Windows ではプロセスのカレントディレクトリを取得できないため、リポジトリを問わず判定する。
+func gitProcessRunning(repoDir string) (bool, error) {
+	output, err := exec.Command("tasklist", "/FI", "IMAGENAME eq git.exe", "/NH", "/FO", "CSV").Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(strings.ToLower(string(output)), "\"git.exe\""), nil
+}
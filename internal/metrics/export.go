@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ContentType は Prometheus のテキスト形式の Content-Type。
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Handler は r のメトリクスを返す http.Handler を返す。
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteText(w)
+	})
+}
+
+// Server は /metrics を提供する HTTP サーバー。
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+}
+
+// Serve は addr で r の /metrics の提供を開始する。
+func Serve(addr string, r *Registry) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(r))
+	s := &Server{
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		listener: listener,
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("Metrics server stopped: %v\n", err)
+		}
+	}()
+	return s, nil
+}
+
+// Addr は待ち受けているアドレスを返す。
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown は処理中の要求の完了を待ってサーバーを終了する。
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// WriteTextfile は r のメトリクスを node_exporter の textfile collector 形式で path に書き出す。
+// 収集中に途中までのファイルが読まれないよう、一時ファイルに書いてから置き換える。
+func WriteTextfile(path string, r *Registry) error {
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	// node_exporter は *.prom のみを読むため、一時ファイルは別の拡張子にする。
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace metrics textfile: %w", err)
+	}
+	return nil
+}
+
+// TextfileWriter は一定間隔でメトリクスを textfile に書き出す。
+type TextfileWriter struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartTextfileWriter は interval ごとに r のメトリクスを path へ書き出し始める。
+// 書き出しの失敗は表示のみ行い、次の間隔で再試行する。
+func StartTextfileWriter(path string, interval time.Duration, r *Registry) *TextfileWriter {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &TextfileWriter{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		write := func() {
+			if err := WriteTextfile(path, r); err != nil {
+				fmt.Printf("Failed to write metrics textfile: %v\n", err)
+			}
+		}
+
+		write()
+		for {
+			select {
+			case <-ctx.Done():
+				write()
+				return
+			case <-ticker.C:
+				write()
+			}
+		}
+	}()
+	return t
+}
+
+// Stop は最後にもう一度書き出してから停止する。
+func (t *TextfileWriter) Stop() {
+	t.cancel()
+	<-t.done
+}
@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Default は sync / fixup / スナップショットの各処理が記録するレジストリ。
+var Default = NewRegistry()
+
+// 失敗の原因。Counter の cause ラベルの値となる。
+const (
+	CausePaused     = "paused"
+	CauseValidation = "validation"
+	CauseLock       = "lock"
+	CauseIndexLock  = "index_lock"
+	CauseBranch     = "branch"
+	CauseDetect     = "detect"
+	CauseApply      = "apply"
+	CauseCommit     = "commit"
+	CauseCompaction = "compaction"
+	CauseAutosquash = "autosquash"
+	CauseCanceled   = "canceled"
+	CauseOther      = "other"
+)
+
+// DurationBuckets は処理時間（秒）の Histogram の上限。
+var DurationBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900}
+
+// FileBuckets は 1 回の処理で扱ったファイル数の Histogram の上限。
+var FileBuckets = []float64{0, 1, 5, 10, 25, 50, 100, 500, 1000}
+
+var (
+	syncRuns          = Default.NewCounter("fcsm_sync_runs_total", "Sync cycles by result.", "result")
+	syncFailures      = Default.NewCounter("fcsm_sync_failures_total", "Failed sync cycles by cause.", "cause")
+	syncDuration      = Default.NewHistogram("fcsm_sync_duration_seconds", "Duration of sync cycles.", DurationBuckets)
+	syncFiles         = Default.NewHistogram("fcsm_sync_files", "Files committed per successful sync cycle.", FileBuckets)
+	syncFilesTotal    = Default.NewCounter("fcsm_sync_files_total", "Files committed by sync, by change type.", "change")
+	syncLastSuccess   = Default.NewGauge("fcsm_sync_last_success_timestamp_seconds", "Unix time of the last successful sync cycle.")
+	syncLastSuccessAt atomic.Int64
+
+	fixupRuns        = Default.NewCounter("fcsm_fixup_runs_total", "Fixup cycles by result.", "result")
+	fixupFailures    = Default.NewCounter("fcsm_fixup_failures_total", "Failed fixup cycles by cause.", "cause")
+	fixupDuration    = Default.NewHistogram("fcsm_fixup_duration_seconds", "Duration of fixup cycles.", DurationBuckets)
+	fixupFiles       = Default.NewHistogram("fcsm_fixup_files", "Files folded into fixup commits per successful fixup cycle.", FileBuckets)
+	fixupRewrites    = Default.NewCounter("fcsm_fixup_rewrites_total", "History rewrites by operation.", "operation")
+	fixupRewritten   = Default.NewCounter("fcsm_fixup_rewritten_commits_total", "Commits replaced by history rewrites.")
+	fixupLastSuccess = Default.NewGauge("fcsm_fixup_last_success_timestamp_seconds", "Unix time of the last successful fixup cycle.")
+
+	breakerFailures = Default.NewGauge("fcsm_consecutive_failures", "Consecutive failures of the continuous loop by operation.", "operation")
+	breakerOpen     = Default.NewGauge("fcsm_circuit_open", "Whether the circuit breaker of the continuous loop is open (1) by operation.", "operation")
+
+	snapshotRuns     = Default.NewCounter("fcsm_snapshot_runs_total", "VHDX snapshots by result.", "result")
+	snapshotDuration = Default.NewHistogram("fcsm_snapshot_duration_seconds", "Duration of VHDX snapshot creation.", DurationBuckets)
+	snapshotSize     = Default.NewGauge("fcsm_snapshot_size_bytes", "Size of the last VHDX snapshot.")
+	snapshotTotal    = Default.NewCounter("fcsm_snapshot_bytes_total", "Total size of created VHDX snapshots.")
+)
+
+func init() {
+	// 一度も発生していない結果も 0 として書き出し、rate() などで扱えるようにする。
+	for _, result := range []string{"success", "failure"} {
+		syncRuns.Add(0, result)
+		fixupRuns.Add(0, result)
+		snapshotRuns.Add(0, result)
+	}
+	syncRuns.Add(0, "paused")
+	fixupRewritten.Add(0)
+	for _, operation := range []string{"sync", "fixup"} {
+		breakerFailures.Set(0, operation)
+		breakerOpen.Set(0, operation)
+	}
+	snapshotTotal.Add(0)
+
+	Default.NewGaugeFunc("fcsm_sync_seconds_since_last_success", "Seconds since the last successful sync cycle in this process.",
+		func() (float64, bool) {
+			last := syncLastSuccessAt.Load()
+			if last == 0 {
+				return 0, false
+			}
+			return time.Since(time.Unix(0, last)).Seconds(), true
+		})
+}
+
+// causeError は失敗の原因を付けたエラー。メッセージは元のエラーのまま変えない。
+type causeError struct {
+	cause string
+	err   error
+}
+
+func (e *causeError) Error() string { return e.err.Error() }
+func (e *causeError) Unwrap() error { return e.err }
+
+// WithCause は err に失敗の原因 cause を付ける。err が nil の場合は nil を返す。
+func WithCause(cause string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &causeError{cause: cause, err: err}
+}
+
+// Cause は err に付けられた最も外側の失敗の原因を返す。付いていない場合は CauseOther。
+// 中断（context.Canceled）による失敗は原因に関わらず CauseCanceled とする。
+func Cause(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return CauseCanceled
+	}
+
+	var ce *causeError
+	if errors.As(err, &ce) {
+		return ce.cause
+	}
+	return CauseOther
+}
+
+// SyncCycle は 1 回の同期の結果。
+type SyncCycle struct {
+	Duration time.Duration
+	Added    int
+	Modified int
+	Deleted  int
+	Err      error
+}
+
+// ObserveSync は 1 回の同期の結果を記録する。
+// ロックファイルで一時停止していた回は失敗ではないため、result="paused" として数えるだけにする。
+func ObserveSync(c SyncCycle) {
+	if c.Err != nil && Cause(c.Err) == CausePaused {
+		syncRuns.Inc("paused")
+		return
+	}
+
+	syncDuration.Observe(c.Duration.Seconds())
+	if c.Err != nil {
+		syncRuns.Inc("failure")
+		syncFailures.Inc(Cause(c.Err))
+		return
+	}
+
+	syncRuns.Inc("success")
+	syncFiles.Observe(float64(c.Added + c.Modified + c.Deleted))
+	syncFilesTotal.Add(float64(c.Added), "added")
+	syncFilesTotal.Add(float64(c.Modified), "modified")
+	syncFilesTotal.Add(float64(c.Deleted), "deleted")
+
+	now := time.Now()
+	syncLastSuccess.Set(float64(now.Unix()))
+	syncLastSuccessAt.Store(now.UnixNano())
+}
+
+// FixupCycle は 1 回の fixup の結果。全ブランチを処理した場合はその合計。
+type FixupCycle struct {
+	Duration time.Duration
+	Files    int
+	// Rewrites は履歴を書き換えた操作（autosquash / amend / compaction）ごとの回数。
+	Rewrites map[string]int
+	// RewrittenCommits は書き換えで置き換えられたコミットの数。
+	RewrittenCommits int
+	Err              error
+}
+
+// ObserveFixup は 1 回の fixup の結果を記録する。
+func ObserveFixup(c FixupCycle) {
+	fixupDuration.Observe(c.Duration.Seconds())
+	for operation, n := range c.Rewrites {
+		fixupRewrites.Add(float64(n), operation)
+	}
+	fixupRewritten.Add(float64(c.RewrittenCommits))
+
+	if c.Err != nil {
+		fixupRuns.Inc("failure")
+		fixupFailures.Inc(Cause(c.Err))
+		return
+	}
+
+	fixupRuns.Inc("success")
+	fixupFiles.Observe(float64(c.Files))
+	fixupLastSuccess.Set(float64(time.Now().Unix()))
+}
+
+// ObserveSnapshot はスナップショット作成の結果と、作成したスナップショットのサイズを記録する。
+func ObserveSnapshot(duration time.Duration, size int64, err error) {
+	snapshotDuration.Observe(duration.Seconds())
+	if err != nil {
+		snapshotRuns.Inc("failure")
+		return
+	}
+
+	snapshotRuns.Inc("success")
+	snapshotSize.Set(float64(size))
+	snapshotTotal.Add(float64(size))
+}
+
+// SetBreakerState は継続実行の operation（sync / fixup）の連続失敗回数と、回路が開いているかを記録する。
+func SetBreakerState(operation string, failures int, open bool) {
+	breakerFailures.Set(float64(failures), operation)
+	value := 0.0
+	if open {
+		value = 1
+	}
+	breakerOpen.Set(value, operation)
+}
@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() failed: %v", err)
+	}
+	return buf.String()
+}
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	counter := r.NewCounter("test_runs_total", "Runs by result.", "result")
+	gauge := r.NewGauge("test_size_bytes", "Size.")
+	histogram := r.NewHistogram("test_duration_seconds", "Duration.", []float64{1, 5})
+	r.NewGaugeFunc("test_unset", "Never set.", func() (float64, bool) { return 0, false })
+
+	counter.Inc("success")
+	counter.Add(2, "failure")
+	counter.Add(-1, "failure")
+	counter.Inc(`quote"back\slash`)
+	gauge.Set(1.5e9)
+	histogram.Observe(0.5)
+	histogram.Observe(3)
+	histogram.Observe(10)
+
+	want := `# HELP test_runs_total Runs by result.
+# TYPE test_runs_total counter
+test_runs_total{result="failure"} 2
+test_runs_total{result="quote\"back\\slash"} 1
+test_runs_total{result="success"} 1
+# HELP test_size_bytes Size.
+# TYPE test_size_bytes gauge
+test_size_bytes 1.5e+09
+# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="1"} 1
+test_duration_seconds_bucket{le="5"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 13.5
+test_duration_seconds_count 3
+# HELP test_unset Never set.
+# TYPE test_unset gauge
+`
+	if got := render(t, r); got != want {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCause(t *testing.T) {
+	base := errors.New("index.lock exists")
+	err := fmt.Errorf("sync failed: %w", WithCause(CauseIndexLock, base))
+
+	if got := Cause(err); got != CauseIndexLock {
+		t.Errorf("Cause() = %q, want %q", got, CauseIndexLock)
+	}
+	if err.Error() != "sync failed: index.lock exists" {
+		t.Errorf("WithCause() should not change the message: %q", err.Error())
+	}
+	if !errors.Is(err, base) {
+		t.Error("WithCause() should keep the wrapped error")
+	}
+	if got := Cause(base); got != CauseOther {
+		t.Errorf("Cause() of an unclassified error = %q, want %q", got, CauseOther)
+	}
+	if WithCause(CauseLock, nil) != nil {
+		t.Error("WithCause(nil) should be nil")
+	}
+}
+
+func TestObserveSync(t *testing.T) {
+	before := syncFailures.Value(CauseBranch)
+	successes := syncRuns.Value("success")
+	failures := syncRuns.Value("failure")
+	paused := syncRuns.Value("paused")
+	pausedFailures := syncFailures.Value(CausePaused)
+	added := syncFilesTotal.Value("added")
+
+	ObserveSync(SyncCycle{Duration: time.Second, Err: WithCause(CauseBranch, errors.New("checkout failed"))})
+	ObserveSync(SyncCycle{Duration: time.Second, Added: 2, Modified: 1})
+	ObserveSync(SyncCycle{Err: WithCause(CausePaused, errors.New("sync is paused"))})
+
+	if got := syncFailures.Value(CauseBranch) - before; got != 1 {
+		t.Errorf("Expected one branch failure, got %v", got)
+	}
+	if got := syncRuns.Value("failure") - failures; got != 1 {
+		t.Errorf("Paused cycles should not be counted as failures, got %v failures", got)
+	}
+	if got := syncFailures.Value(CausePaused) - pausedFailures; got != 0 {
+		t.Errorf("Paused cycles should not be counted as failure causes, got %v", got)
+	}
+	if got := syncRuns.Value("paused") - paused; got != 1 {
+		t.Errorf("Expected one paused cycle, got %v", got)
+	}
+	if got := syncRuns.Value("success") - successes; got != 1 {
+		t.Errorf("Expected one success, got %v", got)
+	}
+	if got := syncFilesTotal.Value("added") - added; got != 2 {
+		t.Errorf("Expected two added files, got %v", got)
+	}
+
+	text := render(t, Default)
+	if !strings.Contains(text, "fcsm_sync_seconds_since_last_success ") {
+		t.Errorf("Time since last success should be exported after a successful sync:\n%s", text)
+	}
+}
+
+func TestObserveFixup(t *testing.T) {
+	autosquash := fixupRewrites.Value("autosquash")
+	rewritten := fixupRewritten.Value()
+	failures := fixupFailures.Value(CauseAutosquash)
+
+	ObserveFixup(FixupCycle{Files: 3, Rewrites: map[string]int{"autosquash": 1}, RewrittenCommits: 4})
+	ObserveFixup(FixupCycle{Err: WithCause(CauseAutosquash, errors.New("conflict"))})
+
+	if got := fixupRewrites.Value("autosquash") - autosquash; got != 1 {
+		t.Errorf("Expected one autosquash rewrite, got %v", got)
+	}
+	if got := fixupRewritten.Value() - rewritten; got != 4 {
+		t.Errorf("Expected four rewritten commits, got %v", got)
+	}
+	if got := fixupFailures.Value(CauseAutosquash) - failures; got != 1 {
+		t.Errorf("Expected one autosquash failure, got %v", got)
+	}
+}
+
+func TestObserveSnapshot(t *testing.T) {
+	ObserveSnapshot(time.Second, 4096, nil)
+	ObserveSnapshot(time.Second, 0, errors.New("disk full"))
+
+	if got := snapshotSize.Value(); got != 4096 {
+		t.Errorf("Snapshot size should be kept from the last successful snapshot, got %v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Test.").Inc()
+
+	rec := httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("Unexpected response: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "test_total 1\n") {
+		t.Errorf("Unexpected body:\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST should be rejected, got %d", rec.Code)
+	}
+}
+
+func TestTextfileWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "textfile", "fcsm.prom")
+	r := NewRegistry()
+	counter := r.NewCounter("test_total", "Test.")
+	counter.Inc()
+
+	writer := StartTextfileWriter(path, time.Hour, r)
+	counter.Inc()
+	writer.Stop()
+
+	// 停止時に最後の値を書き出す。
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Textfile was not written: %v", err)
+	}
+	if !strings.Contains(string(data), "test_total 2\n") {
+		t.Errorf("Textfile should contain the final values:\n%s", data)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if len(matches) != 0 {
+		t.Errorf("Temporary files should not be left behind: %v", matches)
+	}
+}
@@ -0,0 +1,270 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry はメトリクスを保持し、Prometheus のテキスト形式（0.0.4）で書き出す。
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// collector は Registry に登録されたメトリクス。
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText はすべてのメトリクスを登録順に w へ書き出す。
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	return buf.Flush()
+}
+
+// series はラベルの値の組ごとの値を保持する。
+type series struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+func newSeries(name, help, kind string, labelNames []string) *series {
+	return &series{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+}
+
+func (s *series) update(labelValues []string, fn func(float64) float64) {
+	if len(labelValues) != len(s.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", s.name, len(s.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.labels[key]; !ok {
+		s.labels[key] = append([]string(nil), labelValues...)
+	}
+	s.values[key] = fn(s.values[key])
+}
+
+func (s *series) get(labelValues []string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[strings.Join(labelValues, "\xff")]
+}
+
+func (s *series) write(w *bufio.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeHeader(w, s.name, s.help, s.kind)
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeSample(w, s.name, s.labelNames, s.labels[key], s.values[key])
+	}
+}
+
+// Counter は増加のみする値。ラベルの値の組ごとに別の系列となる。
+type Counter struct {
+	s *series
+}
+
+// NewCounter は labelNames をラベルに持つ Counter を登録する。
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{s: newSeries(name, help, "counter", labelNames)}
+	r.register(c.s)
+	return c
+}
+
+// Add は labelValues の系列に v を加える。v が負の場合は無視する。
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.s.update(labelValues, func(old float64) float64 { return old + v })
+}
+
+// Inc は labelValues の系列に 1 を加える。
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value は labelValues の系列の現在の値を返す。
+func (c *Counter) Value(labelValues ...string) float64 {
+	return c.s.get(labelValues)
+}
+
+// Gauge は増減する値。
+type Gauge struct {
+	s *series
+}
+
+// NewGauge は labelNames をラベルに持つ Gauge を登録する。
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{s: newSeries(name, help, "gauge", labelNames)}
+	r.register(g.s)
+	return g
+}
+
+// Set は labelValues の系列の値を v にする。
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.s.update(labelValues, func(float64) float64 { return v })
+}
+
+// Value は labelValues の系列の現在の値を返す。
+func (g *Gauge) Value(labelValues ...string) float64 {
+	return g.s.get(labelValues)
+}
+
+// gaugeFunc は書き出すたびに値を求める Gauge。
+type gaugeFunc struct {
+	name string
+	help string
+	fn   func() (float64, bool)
+}
+
+// NewGaugeFunc は書き出すたびに fn で値を求める Gauge を登録する。fn が false を返した場合は系列を書き出さない。
+func (r *Registry) NewGaugeFunc(name, help string, fn func() (float64, bool)) {
+	r.register(&gaugeFunc{name: name, help: help, fn: fn})
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	if v, ok := g.fn(); ok {
+		writeSample(w, g.name, nil, nil, v)
+	}
+}
+
+// Histogram は観測値の分布。buckets はそれぞれの上限（以下）を昇順に並べたもの。
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram は buckets を上限とする Histogram を登録する。
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe は観測値 v を加える。
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// Count は観測値の数を返す。
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	le := []string{"le"}
+	for i, upper := range h.buckets {
+		writeSample(w, h.name+"_bucket", le, []string{formatValue(upper)}, float64(h.counts[i]))
+	}
+	writeSample(w, h.name+"_bucket", le, []string{"+Inf"}, float64(h.count))
+	writeSample(w, h.name+"_sum", nil, nil, h.sum)
+	writeSample(w, h.name+"_count", nil, nil, float64(h.count))
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, v float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.WriteByte('{')
+		for i, label := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, labelValueEscaper.Replace(labelValues[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatValue(v))
+	w.WriteByte('\n')
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
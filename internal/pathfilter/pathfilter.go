@@ -0,0 +1,66 @@
+package pathfilter
+
+import (
+	"path/filepath"
+	"strings"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+// Filter は設定の include/exclude 条件に基づいて同期対象のパスを判定する。
+type Filter struct {
+	includeExtensions []string
+	includePatterns   []string
+	excludePatterns   []string
+}
+
+func New(cfg *config.Config) *Filter {
+	return &Filter{
+		includeExtensions: cfg.IncludeExtensions,
+		includePatterns:   cfg.IncludePatterns,
+		excludePatterns:   cfg.ExcludePatterns,
+	}
+}
+
+// Match はパスが同期対象（include 条件に一致し、exclude 条件に一致しない）かを返す。
+func (f *Filter) Match(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+
+	for _, includeExt := range f.includeExtensions {
+		if ext == strings.ToLower(includeExt) {
+			return !f.Excluded(filePath)
+		}
+	}
+
+	for _, pattern := range f.includePatterns {
+		if matched, _ := filepath.Match(pattern, filePath); matched {
+			return !f.Excluded(filePath)
+		}
+	}
+
+	return false
+}
+
+// Excluded はパスが exclude 条件に一致するかを返す。
+func (f *Filter) Excluded(filePath string) bool {
+	for _, pattern := range f.excludePatterns {
+		if matched, _ := filepath.Match(pattern, filePath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// HasIncludes は include 条件（拡張子またはパターン）が一つ以上設定されているかを返す。
+func (f *Filter) HasIncludes() bool {
+	return len(f.includeExtensions)+len(f.includePatterns) > 0
+}
+
+// Managed は Ops 側で管理対象とみなすパスかを返す。
+// include 条件が未設定の場合は exclude 条件に一致しないすべてのパスを対象とする。
+func (f *Filter) Managed(filePath string) bool {
+	if !f.HasIncludes() {
+		return !f.Excluded(filePath)
+	}
+	return f.Match(filePath)
+}
@@ -0,0 +1,52 @@
+package pathfilter
+
+import (
+	"testing"
+
+	"fixup-commit-sync-manager/internal/config"
+)
+
+func TestMatch(t *testing.T) {
+	filter := New(&config.Config{
+		IncludeExtensions: []string{".cpp", ".H"},
+		IncludePatterns:   []string{"docs/*.md"},
+		ExcludePatterns:   []string{"bin/**", "*.tmp.cpp"},
+	})
+
+	tests := []struct {
+		filePath string
+		expected bool
+	}{
+		{"main.cpp", true},
+		{"header.h", true},
+		{"日本語.cpp", true},
+		{"docs/readme.md", true},
+		{"readme.md", false},
+		{"bin/output.cpp", false},
+		{"scratch.tmp.cpp", false},
+		{"notes.txt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filePath, func(t *testing.T) {
+			if got := filter.Match(tt.filePath); got != tt.expected {
+				t.Errorf("Match(%q) = %t, want %t", tt.filePath, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestManaged(t *testing.T) {
+	withIncludes := New(&config.Config{IncludeExtensions: []string{".cpp"}})
+	if withIncludes.Managed("notes.txt") {
+		t.Error("Managed() should follow include conditions when configured")
+	}
+
+	withoutIncludes := New(&config.Config{ExcludePatterns: []string{"*.log"}})
+	if !withoutIncludes.Managed("notes.txt") {
+		t.Error("Managed() should accept any path when no include conditions are configured")
+	}
+	if withoutIncludes.Managed("build.log") {
+		t.Error("Managed() should reject excluded paths")
+	}
+}
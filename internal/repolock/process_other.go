@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package repolock
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive は pid のプロセスが存在するかを返す。権限が無く確認できない場合は存在するとみなす。
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
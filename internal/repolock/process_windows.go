@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package repolock
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// processAlive は pid のプロセスが存在するかを返す。確認できない場合は存在するとみなす。
+func processAlive(pid int) bool {
+	output, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/NH", "/FO", "CSV").Output()
+	if err != nil {
+		return true
+	}
+	return strings.Contains(string(output), fmt.Sprintf("\"%d\"", pid))
+}
@@ -0,0 +1,410 @@
+package repolock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// LockFile はロックファイルの、Ops リポジトリの git ディレクトリからの相対パス。
+const LockFile = "fcsm/repo.lock"
+
+// DefaultStaleAfter は設定が無い場合に、heartbeat が更新されないロックを放置されたとみなす経過時間。
+const DefaultStaleAfter = 2 * time.Minute
+
+// pollInterval はロックの解放を待つ間の確認間隔。
+const pollInterval = 200 * time.Millisecond
+
+// ErrLocked は他のプロセスがロックを保持していることを表す。
+var ErrLocked = errors.New("ops repository is locked by another process")
+
+// ErrLost は保持中のロックを他のプロセスに引き継がれたか、ロックファイルが削除されたことを表す。
+var ErrLost = errors.New("repository lock was lost")
+
+// Info はロックファイルに記録する保持者の情報。
+type Info struct {
+	PID       int    `json:"pid"`
+	Host      string `json:"host"`
+	Operation string `json:"operation"`
+	// Token はロックごとに生成する識別子。PID の再利用や同一プロセス内の別のロックと区別する。
+	Token      string    `json:"token"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	Heartbeat  time.Time `json:"heartbeat"`
+}
+
+// LockedError は待機時間内にロックを取得できなかったことを表す。errors.Is(err, ErrLocked) で判定できる。
+type LockedError struct {
+	Path   string
+	Holder Info
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("ops repository is locked by pid %d on %s (%s, heartbeat %v ago): %s",
+		e.Holder.PID, e.Holder.Host, e.Holder.Operation, time.Since(e.Holder.Heartbeat).Round(time.Second), e.Path)
+}
+
+func (e *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Locker は Ops リポジトリを変更する処理同士の同時実行を防ぐ、プロセス間の advisory lock を扱う。
+// ロックファイルは保持中に定期的に heartbeat を更新し、保持者のプロセスが終了したか
+// heartbeat が staleAfter より古くなったロックは放置されたものとして引き継ぐ。
+type Locker struct {
+	git        git.Runner
+	wait       time.Duration
+	staleAfter time.Duration
+	host       string
+	now        func() time.Time
+	// processAlive は同じホストのプロセスが実行中かを返す。テストで差し替える。
+	processAlive func(pid int) bool
+}
+
+func NewLocker(cfg *config.Config, runner git.Runner) *Locker {
+	wait, err := cfg.GetRepoLockWaitDuration()
+	if err != nil || wait < 0 {
+		wait = 0
+	}
+	staleAfter, err := cfg.GetRepoLockStaleAfterDuration()
+	if err != nil || staleAfter <= 0 {
+		staleAfter = DefaultStaleAfter
+	}
+	host, _ := os.Hostname()
+
+	return &Locker{
+		git:          runner,
+		wait:         wait,
+		staleAfter:   staleAfter,
+		host:         host,
+		now:          time.Now,
+		processAlive: processAlive,
+	}
+}
+
+// Path はロックファイルの絶対パスを返す。
+func (l *Locker) Path(ctx context.Context) (string, error) {
+	path, err := git.Output(ctx, l.git, "rev-parse", "--git-path", LockFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve repository lock path: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.git.Dir(), path)
+	}
+	return path, nil
+}
+
+// Acquire は operation のためにロックを取得する。他のプロセスが保持している場合は設定された時間まで待ち、
+// それでも取得できなければ *LockedError を返す。取得したロックは Release で解放する。
+// ロックを保持して行う処理は、ロックを失うと終了する Lock.Context を使う。
+func (l *Locker) Acquire(ctx context.Context, operation string) (*Lock, error) {
+	path, err := l.Path(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	now := l.now()
+	info := Info{
+		PID:        os.Getpid(),
+		Host:       l.host,
+		Operation:  operation,
+		Token:      token,
+		AcquiredAt: now,
+		Heartbeat:  now,
+	}
+
+	deadline := now.Add(l.wait)
+	for {
+		created, err := createExclusive(path, info)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return l.start(ctx, path, info), nil
+		}
+
+		holder, err := readInfo(path)
+		if os.IsNotExist(err) {
+			// 確認までの間に解放された。
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if reason := l.staleReason(holder); reason != "" {
+			taken, err := l.takeOver(path, holder, token)
+			if err != nil {
+				return nil, err
+			}
+			if taken {
+				fmt.Printf("Took over stale repository lock held by pid %d on %s (%s): %s\n",
+					holder.PID, holder.Host, holder.Operation, reason)
+			}
+			continue
+		}
+
+		if !l.now().Before(deadline) {
+			return nil, &LockedError{Path: path, Holder: *holder}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Holder は現在のロックの保持者を返す。ロックが無い場合は nil。stale は放置されたロックであることを表す。
+func (l *Locker) Holder(ctx context.Context) (*Info, bool, error) {
+	path, err := l.Path(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	holder, err := readInfo(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return holder, l.staleReason(holder) != "", nil
+}
+
+// staleReason はロックが放置されたものとみなせる場合にその理由を返す。有効なロックであれば空。
+func (l *Locker) staleReason(holder *Info) string {
+	// 同じホストであればプロセスの有無で判定できる。他ホストのプロセスは確認できないため heartbeat のみで判定する。
+	if holder.Host == l.host && holder.PID > 0 && !l.processAlive(holder.PID) {
+		return "owner process is not running"
+	}
+	if age := l.now().Sub(holder.Heartbeat); age > l.staleAfter {
+		return fmt.Sprintf("heartbeat is %v old", age.Round(time.Second))
+	}
+	return ""
+}
+
+// takeOver は放置されたロックを取り除く。確認後に他のプロセスが引き継いだ場合は、そのロックを戻して false を返す。
+func (l *Locker) takeOver(path string, stale *Info, token string) (bool, error) {
+	moved := fmt.Sprintf("%s.%s.stale", path, token)
+	if err := os.Rename(path, moved); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to take over repository lock: %w", err)
+	}
+	defer os.Remove(moved)
+
+	current, err := readInfo(moved)
+	if err == nil && (current.Token != stale.Token || !current.Heartbeat.Equal(stale.Heartbeat)) {
+		// 取り除いたのは確認後に更新された有効なロック。既に新しいロックが作られていなければ戻す。
+		os.Link(moved, path)
+		return false, nil
+	}
+	return true, nil
+}
+
+// start は取得したロックの heartbeat の更新を開始する。
+func (l *Locker) start(ctx context.Context, path string, info Info) *Lock {
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	lock := &Lock{
+		path:       path,
+		info:       info,
+		now:        l.now,
+		staleAfter: l.staleAfter,
+		ctx:        lockCtx,
+		cancel:     cancel,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go lock.heartbeat(l.staleAfter / 4)
+	return lock
+}
+
+// Lock は取得済みのロック。
+type Lock struct {
+	path       string
+	info       Info
+	now        func() time.Time
+	staleAfter time.Duration
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	lostErr error
+}
+
+// Info はこのロックとして記録した保持者の情報を返す。
+func (k *Lock) Info() Info {
+	return k.info
+}
+
+// Context は Acquire に渡した ctx を元に、ロックを失うと終了する context を返す。
+// 他のプロセスに引き継がれた後も Ops リポジトリを変更し続けないよう、ロックを保持して行う git 操作にはこれを使う。
+// ロックを失って終了した場合、context.Cause は Lost と同じエラーを返す。
+func (k *Lock) Context() context.Context {
+	return k.ctx
+}
+
+// Lost は保持中に他のプロセスへロックが引き継がれた場合にその内容を返す。errors.Is(err, ErrLost) で判定できる。
+func (k *Lock) Lost() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.lostErr
+}
+
+// Release は heartbeat の更新を止め、ロックファイルが自分のものであれば削除する。
+func (k *Lock) Release() error {
+	k.once.Do(func() {
+		close(k.stop)
+		<-k.done
+		k.cancel(nil)
+	})
+
+	current, err := readInfo(k.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current.Token != k.info.Token {
+		return nil
+	}
+	if err := os.Remove(k.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release repository lock: %w", err)
+	}
+	return nil
+}
+
+func (k *Lock) heartbeat(interval time.Duration) {
+	defer close(k.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+			// 他のプロセスが読み込み中で置き換えられないなど、一時的に更新できない場合は次の周期で再度更新する。
+			if err := k.refresh(); errors.Is(err, ErrLost) {
+				k.mu.Lock()
+				k.lostErr = err
+				k.mu.Unlock()
+				k.cancel(err)
+				return
+			}
+		}
+	}
+}
+
+// refresh はロックファイルが自分のものであることを確認して heartbeat を更新する。
+func (k *Lock) refresh() error {
+	current, err := readInfo(k.path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: lock file disappeared", ErrLost)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read repository lock: %w", err)
+	}
+	if current.Token != k.info.Token {
+		return fmt.Errorf("%w: taken over by pid %d on %s", ErrLost, current.PID, current.Host)
+	}
+	// 他のプロセスが放置されたロックとして引き継ぐのは heartbeat が staleAfter より古い場合に限られる。
+	// 確認から置き換えまでの間に引き継がれたロックを上書きしないよう、古くなりかけた heartbeat は更新せずに手放す。
+	if age := k.now().Sub(k.info.Heartbeat); age > k.staleAfter/2 {
+		return fmt.Errorf("%w: heartbeat was not refreshed for %v", ErrLost, age.Round(time.Second))
+	}
+
+	info := k.info
+	info.Heartbeat = k.now()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	// 読み手が書き込み途中の内容やロックの無い状態を見ないよう、一時ファイルから置き換える。
+	tmp := fmt.Sprintf("%s.%s.tmp", k.path, k.info.Token)
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to update repository lock: %w", err)
+	}
+	if err := os.Rename(tmp, k.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to update repository lock: %w", err)
+	}
+	k.info = info
+
+	// 置き換えた後も自分のロックであることを確認する。
+	if current, err := readInfo(k.path); err == nil && current.Token != k.info.Token {
+		return fmt.Errorf("%w: taken over by pid %d on %s", ErrLost, current.PID, current.Host)
+	}
+	return nil
+}
+
+// createExclusive は info を書き込んだロックファイルを作成する。既に存在する場合は false を返す。
+// 内容の無いロックファイルが見えないよう、一時ファイルに書き込んでからハードリンクで作成する。
+func createExclusive(path string, info Info) (bool, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return false, err
+	}
+
+	tmp := fmt.Sprintf("%s.%s.tmp", path, info.Token)
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return false, fmt.Errorf("failed to write repository lock: %w", err)
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Link(tmp, path); err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create repository lock: %w", err)
+	}
+	return true, nil
+}
+
+func readInfo(path string) (*Info, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		// 壊れたロックファイルは、最終更新時刻を heartbeat とみなして扱う。
+		stat, statErr := os.Stat(path)
+		if statErr != nil {
+			return nil, statErr
+		}
+		return &Info{Operation: "unknown", Heartbeat: stat.ModTime()}, nil
+	}
+	return &info, nil
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
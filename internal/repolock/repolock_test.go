@@ -0,0 +1,262 @@
+package repolock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func TestAcquireAndRelease(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	locker := newTestLocker(repo, "0s", "1m")
+
+	lock, err := locker.Acquire(context.Background(), "sync")
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+
+	holder := readLock(t, repo)
+	if holder.PID != os.Getpid() || holder.Host != locker.host || holder.Operation != "sync" || holder.Token == "" {
+		t.Errorf("Unexpected lock holder: %+v", holder)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() failed: %v", err)
+	}
+	if _, err := os.Stat(lockPath(repo)); !os.IsNotExist(err) {
+		t.Error("Lock file should be removed on release")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(lockPath(repo)))
+	if len(entries) != 0 {
+		t.Errorf("Temporary files should not be left behind: %v", entries)
+	}
+}
+
+func TestAcquireFailsFastWhenHeld(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	first, err := newTestLocker(repo, "0s", "1m").Acquire(context.Background(), "fixup")
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	defer first.Release()
+
+	_, err = newTestLocker(repo, "0s", "1m").Acquire(context.Background(), "sync")
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("Expected ErrLocked, got %v", err)
+	}
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Holder.Operation != "fixup" {
+		t.Errorf("Error should describe the holder, got %v", err)
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	first, err := newTestLocker(repo, "0s", "1m").Acquire(context.Background(), "fixup")
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	time.AfterFunc(300*time.Millisecond, func() { first.Release() })
+
+	second, err := newTestLocker(repo, "5s", "1m").Acquire(context.Background(), "sync")
+	if err != nil {
+		t.Fatalf("Acquire() should succeed after the holder releases: %v", err)
+	}
+	defer second.Release()
+
+	if holder := readLock(t, repo); holder.Operation != "sync" {
+		t.Errorf("Expected lock to be held by sync, got %+v", holder)
+	}
+}
+
+func TestAcquireTakesOverStaleLock(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	tests := []struct {
+		name      string
+		host      string
+		heartbeat time.Duration
+		alive     bool
+		takeOver  bool
+	}{
+		{"owner process exited", "", 0, false, true},
+		{"heartbeat expired on another host", "other-host", time.Hour, true, true},
+		{"fresh lock on another host", "other-host", 0, false, false},
+		{"heartbeat expired with a reused pid", "", time.Hour, true, true},
+		{"running owner on this host", "", 0, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := testutil.CreateRepository(t)
+			locker := newTestLocker(repo, "0s", "30m")
+			locker.processAlive = func(int) bool { return tt.alive }
+
+			host := tt.host
+			if host == "" {
+				host = locker.host
+			}
+			writeLock(t, repo, Info{
+				PID:       999999,
+				Host:      host,
+				Operation: "fixup",
+				Token:     "stale",
+				Heartbeat: time.Now().Add(-tt.heartbeat),
+			})
+
+			lock, err := locker.Acquire(context.Background(), "sync")
+			if !tt.takeOver {
+				if !errors.Is(err, ErrLocked) {
+					t.Fatalf("Expected ErrLocked, got %v", err)
+				}
+				if holder := readLock(t, repo); holder.Token != "stale" {
+					t.Errorf("Valid lock should be kept, got %+v", holder)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Acquire() should take over the stale lock: %v", err)
+			}
+			defer lock.Release()
+			if holder := readLock(t, repo); holder.Token != lock.Info().Token {
+				t.Errorf("Expected lock to be taken over, got %+v", holder)
+			}
+		})
+	}
+}
+
+func TestHeartbeatAndLostLock(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	lock, err := newTestLocker(repo, "0s", "200ms").Acquire(context.Background(), "sync")
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	acquired := readLock(t, repo)
+
+	time.Sleep(150 * time.Millisecond)
+	if holder := readLock(t, repo); !holder.Heartbeat.After(acquired.Heartbeat) {
+		t.Errorf("Heartbeat should be refreshed: %v -> %v", acquired.Heartbeat, holder.Heartbeat)
+	}
+
+	// 他のプロセスに引き継がれたロックは、解放時に削除しない。
+	writeLock(t, repo, Info{PID: 1, Host: "other-host", Operation: "fixup", Token: "other", Heartbeat: time.Now()})
+	time.Sleep(150 * time.Millisecond)
+	lost := lock.Lost()
+	if !errors.Is(lost, ErrLost) {
+		t.Errorf("Lost() should report the takeover, got %v", lost)
+	}
+	// ロックを失うと、ロックを保持して行う処理の context を終了する。
+	select {
+	case <-lock.Context().Done():
+		if cause := context.Cause(lock.Context()); cause != lost {
+			t.Errorf("context.Cause() = %v, want %v", cause, lost)
+		}
+	case <-time.After(time.Second):
+		t.Error("Context() should be canceled when the lock is lost")
+	}
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release() failed: %v", err)
+	}
+	if holder := readLock(t, repo); holder.Token != "other" {
+		t.Errorf("Lock of the new holder should be kept, got %+v", holder)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(lockPath(repo)))
+	if len(entries) != 1 {
+		t.Errorf("Only the new holder's lock file should be left, got %v", entries)
+	}
+}
+
+// TestRefreshKeepsTakenOverLock は heartbeat が古くなりかけたロックを更新せず、引き継いだ他のプロセスのロックを上書きしないことをテストする。
+func TestRefreshKeepsTakenOverLock(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	lock, err := newTestLocker(repo, "0s", "1m").Acquire(context.Background(), "sync")
+	if err != nil {
+		t.Fatalf("Acquire() failed: %v", err)
+	}
+	defer lock.Release()
+
+	if err := lock.refresh(); err != nil {
+		t.Fatalf("refresh() failed: %v", err)
+	}
+
+	// heartbeat を更新できずに時間が経った場合は、まだ自分のロックに見えても更新しない。
+	acquired := readLock(t, repo)
+	lock.now = func() time.Time { return acquired.Heartbeat.Add(time.Minute) }
+	if err := lock.refresh(); !errors.Is(err, ErrLost) {
+		t.Errorf("refresh() with an overdue heartbeat = %v, want ErrLost", err)
+	}
+	if holder := readLock(t, repo); !holder.Heartbeat.Equal(acquired.Heartbeat) {
+		t.Errorf("Overdue lock should not be rewritten, got %+v", holder)
+	}
+}
+
+func TestNewLockerDefaults(t *testing.T) {
+	locker := NewLocker(&config.Config{}, git.NewRunner("git", t.TempDir()))
+	if locker.wait != 0 || locker.staleAfter != DefaultStaleAfter {
+		t.Errorf("Expected fail-fast with default stale threshold, got wait %v stale %v", locker.wait, locker.staleAfter)
+	}
+}
+
+func newTestLocker(repo, wait, staleAfter string) *Locker {
+	return NewLocker(&config.Config{RepoLockWait: wait, RepoLockStaleAfter: staleAfter}, git.NewRunner("git", repo))
+}
+
+func lockPath(repo string) string {
+	return filepath.Join(repo, ".git", LockFile)
+}
+
+func readLock(t *testing.T, repo string) Info {
+	data, err := os.ReadFile(lockPath(repo))
+	if err != nil {
+		t.Fatalf("Failed to read lock file: %v", err)
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("Failed to parse lock file: %v", err)
+	}
+	return info
+}
+
+func writeLock(t *testing.T, repo string, info Info) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("Failed to encode lock: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(lockPath(repo)), 0755); err != nil {
+		t.Fatalf("Failed to create state directory: %v", err)
+	}
+	if err := os.WriteFile(lockPath(repo), data, 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/metrics"
+	"fixup-commit-sync-manager/internal/notify"
+)
+
+// 設定が無い場合の既定値。
+const (
+	DefaultBackoffMax    = 30 * time.Minute
+	DefaultProbeInterval = 5 * time.Minute
+)
+
+// jitterFactor は待ち時間を前後にずらす割合。複数のプロセスが同時に再試行しないようにする。
+const jitterFactor = 0.2
+
+// ErrOpen は回路が開いており、確認に失敗したため処理を実行しなかったことを表す。
+var ErrOpen = errors.New("circuit breaker is open")
+
+// Breaker は継続実行の連続失敗を数え、次の実行までの間隔を決める。
+// 失敗が続くと間隔を倍々に延ばし、threshold 回続けて失敗すると回路を開いて一度だけ通知する。
+// 回路が開いている間は処理の代わりに Probe で原因の解消を確認し、成功した場合のみ処理を再開する。
+// ループの goroutine からのみ使用する。
+type Breaker struct {
+	operation     string
+	interval      time.Duration
+	maxBackoff    time.Duration
+	threshold     int
+	probeInterval time.Duration
+	notifier      *notify.Notifier
+
+	// Probe は回路が開いている間に処理の代わりに実行する軽い確認。nil の場合は処理そのものを試す。
+	Probe func(ctx context.Context) error
+	// random は [0, 1) の乱数を返す。テストで差し替える。
+	random func() float64
+
+	failures int
+	open     bool
+	lastErr  error
+}
+
+// NewBreaker は間隔 interval で operation（sync / fixup）を繰り返すループ用の Breaker を作成する。
+func NewBreaker(cfg *config.Config, operation string, interval time.Duration) *Breaker {
+	maxBackoff, err := cfg.GetFailureBackoffMaxDuration()
+	if err != nil || maxBackoff <= 0 {
+		maxBackoff = DefaultBackoffMax
+	}
+	probeInterval, err := cfg.GetCircuitProbeIntervalDuration()
+	if err != nil || probeInterval <= 0 {
+		probeInterval = DefaultProbeInterval
+	}
+	return &Breaker{
+		operation:     operation,
+		interval:      interval,
+		maxBackoff:    maxBackoff,
+		threshold:     cfg.CircuitBreakerThreshold,
+		probeInterval: probeInterval,
+		notifier:      notify.NewNotifier(cfg.NotifyOnError),
+		random:        rand.Float64,
+	}
+}
+
+// Inherit は設定の再読み込みで作り直した b に、以前の Breaker の失敗回数と回路の状態を引き継ぐ。
+func (b *Breaker) Inherit(old *Breaker) {
+	if old == nil {
+		return
+	}
+	b.failures = old.failures
+	b.open = old.open
+	b.lastErr = old.lastErr
+	b.publish()
+}
+
+// Do は回路が閉じていれば op を実行して結果を記録する。
+// 回路が開いている場合は先に Probe を実行し、失敗すれば op を実行せずに ErrOpen を返す。
+func (b *Breaker) Do(ctx context.Context, op func(ctx context.Context) error) error {
+	if b.open && b.Probe != nil {
+		if err := b.Probe(ctx); err != nil {
+			return fmt.Errorf("%w after %d consecutive failures, probe failed: %w", ErrOpen, b.failures, err)
+		}
+		fmt.Printf("%s probe succeeded, retrying\n", b.operation)
+	}
+
+	err := op(ctx)
+	b.Record(err)
+	return err
+}
+
+// Record は 1 回の実行結果を記録する。一時停止と中断による失敗は数えない。
+func (b *Breaker) Record(err error) {
+	if err != nil {
+		switch metrics.Cause(err) {
+		case metrics.CausePaused, metrics.CauseCanceled:
+			return
+		}
+	}
+
+	if err == nil {
+		if b.open {
+			fmt.Printf("%s recovered after %d consecutive failures\n", b.operation, b.failures)
+			b.notifier.NotifyInfo(fmt.Sprintf("%s recovered", b.operation),
+				fmt.Sprintf("%s succeeded again after %d consecutive failures", b.operation, b.failures),
+				map[string]string{"Last error": b.lastErr.Error()})
+		}
+		b.failures = 0
+		b.open = false
+		b.lastErr = nil
+		b.publish()
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	if !b.open && b.threshold > 0 && b.failures >= b.threshold {
+		b.open = true
+		fmt.Printf("Circuit breaker opened for %s after %d consecutive failures; probing every %v\n",
+			b.operation, b.failures, b.probeInterval)
+		b.notifier.NotifyError(b.operation, err, map[string]string{
+			"Consecutive failures": fmt.Sprintf("%d", b.failures),
+			"Probe interval":       b.probeInterval.String(),
+		})
+	}
+	b.publish()
+}
+
+// Delay は次の実行までの間隔を返す。失敗していなければ interval、回路が開いていれば確認の間隔となる。
+// 失敗が続いている間は interval から倍々に延ばした値（上限 maxBackoff）を前後にずらして返す。
+func (b *Breaker) Delay() time.Duration {
+	switch {
+	case b.open:
+		return b.jitter(b.probeInterval)
+	case b.failures == 0:
+		return b.interval
+	}
+
+	limit := b.maxBackoff
+	if limit < b.interval {
+		limit = b.interval
+	}
+	delay := b.interval
+	for i := 1; i < b.failures && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return b.jitter(delay)
+}
+
+func (b *Breaker) jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (1 + jitterFactor*(2*b.random()-1)))
+}
+
+// Failures は連続して失敗した回数を返す。
+func (b *Breaker) Failures() int {
+	return b.failures
+}
+
+// IsOpen は回路が開いているかを返す。
+func (b *Breaker) IsOpen() bool {
+	return b.open
+}
+
+func (b *Breaker) publish() {
+	metrics.SetBreakerState(b.operation, b.failures, b.open)
+}
@@ -0,0 +1,144 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/metrics"
+)
+
+func newTestBreaker(cfg *config.Config) *Breaker {
+	b := NewBreaker(cfg, "sync", time.Minute)
+	b.random = func() float64 { return 0.5 } // ジッターなし。
+	return b
+}
+
+func TestBreakerBackoff(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.FailureBackoffMax = "5m"
+	cfg.CircuitBreakerThreshold = 0
+	b := newTestBreaker(cfg)
+
+	if got := b.Delay(); got != time.Minute {
+		t.Errorf("Delay() without failures = %v, want 1m", got)
+	}
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, w := range want {
+		b.Record(errors.New("ops unavailable"))
+		if got := b.Delay(); got != w {
+			t.Errorf("Delay() after %d failures = %v, want %v", i+1, got, w)
+		}
+	}
+	if b.IsOpen() {
+		t.Error("Breaker with threshold 0 should never open")
+	}
+
+	b.Record(nil)
+	if b.Failures() != 0 || b.Delay() != time.Minute {
+		t.Errorf("Success should reset the backoff, got %d failures and delay %v", b.Failures(), b.Delay())
+	}
+}
+
+func TestBreakerJitter(t *testing.T) {
+	b := newTestBreaker(config.DefaultConfig())
+	b.Record(errors.New("failed"))
+	b.Record(errors.New("failed"))
+
+	b.random = func() float64 { return 0 }
+	if got := b.Delay(); got != 96*time.Second {
+		t.Errorf("Delay() with minimum jitter = %v, want 1m36s", got)
+	}
+	b.random = func() float64 { return 0.999999 }
+	if got := b.Delay(); got < 143*time.Second || got > 144*time.Second {
+		t.Errorf("Delay() with maximum jitter = %v, want about 2m24s", got)
+	}
+}
+
+func TestBreakerOpensAndProbes(t *testing.T) {
+	var notifications atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notifications.Add(1)
+	}))
+	defer server.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.CircuitBreakerThreshold = 3
+	cfg.CircuitProbeInterval = "10m"
+	cfg.NotifyOnError = &config.NotifyConfig{SlackWebhookURL: server.URL}
+	b := newTestBreaker(cfg)
+
+	probeErr := errors.New("ops is not mounted")
+	b.Probe = func(ctx context.Context) error { return probeErr }
+
+	runs := 0
+	failing := func(ctx context.Context) error {
+		runs++
+		return fmt.Errorf("sync failed")
+	}
+	for i := 0; i < 3; i++ {
+		b.Do(context.Background(), failing)
+	}
+	if !b.IsOpen() || runs != 3 {
+		t.Fatalf("Breaker should open after 3 failures, open=%v runs=%d", b.IsOpen(), runs)
+	}
+	if got := b.Delay(); got != 10*time.Minute {
+		t.Errorf("Delay() while open = %v, want the probe interval", got)
+	}
+
+	// 回路が開いている間は確認に失敗する限り処理を実行せず、通知も繰り返さない。
+	for i := 0; i < 3; i++ {
+		err := b.Do(context.Background(), failing)
+		if !errors.Is(err, ErrOpen) || !errors.Is(err, probeErr) {
+			t.Errorf("Do() while open = %v, want ErrOpen wrapping the probe error", err)
+		}
+	}
+	if runs != 3 {
+		t.Errorf("Operation should not run while the probe fails, ran %d times", runs)
+	}
+	if got := notifications.Load(); got != 1 {
+		t.Errorf("Expected a single notification when opening, got %d", got)
+	}
+
+	// 確認に成功すると処理を試し、成功すれば回路を閉じて回復を通知する。
+	b.Probe = func(ctx context.Context) error { return nil }
+	if err := b.Do(context.Background(), func(ctx context.Context) error { return nil }); err != nil {
+		t.Fatalf("Do() after recovery failed: %v", err)
+	}
+	if b.IsOpen() || b.Failures() != 0 {
+		t.Errorf("Breaker should close after a success, open=%v failures=%d", b.IsOpen(), b.Failures())
+	}
+	if got := notifications.Load(); got != 2 {
+		t.Errorf("Expected a recovery notification, got %d notifications", got)
+	}
+}
+
+func TestBreakerIgnoresPauseAndCancel(t *testing.T) {
+	b := newTestBreaker(config.DefaultConfig())
+
+	b.Record(metrics.WithCause(metrics.CausePaused, errors.New("sync is paused")))
+	b.Record(fmt.Errorf("git failed: %w", context.Canceled))
+	if b.Failures() != 0 {
+		t.Errorf("Paused and canceled runs should not count as failures, got %d", b.Failures())
+	}
+}
+
+func TestBreakerInherit(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.CircuitBreakerThreshold = 1
+	old := newTestBreaker(cfg)
+	old.Record(errors.New("failed"))
+
+	b := newTestBreaker(cfg)
+	b.Inherit(old)
+	if !b.IsOpen() || b.Failures() != 1 {
+		t.Errorf("Inherit() should keep the failure state, open=%v failures=%d", b.IsOpen(), b.Failures())
+	}
+}
@@ -0,0 +1,210 @@
+package rewrite
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/git"
+)
+
+// StateFile は書き換え記録を追記する、Ops リポジトリの git ディレクトリからの相対パス。
+const StateFile = "fcsm/rewrites.jsonl"
+
+// Entry は書き換え前後のコミットの対応。git の post-rewrite フックに渡される 1 行に相当する。
+type Entry struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// Record は一回の履歴書き換え（autosquash rebase、amend、圧縮）の記録。
+type Record struct {
+	Time      time.Time `json:"time"`
+	Branch    string    `json:"branch"`
+	Operation string    `json:"operation"`
+	BackupRef string    `json:"backupRef,omitempty"`
+	Rewrites  []Entry   `json:"rewrites"`
+}
+
+// Log は書き換え記録を Ops リポジトリの状態ファイルへ JSON Lines 形式で追記する。
+type Log struct {
+	git git.Runner
+}
+
+func NewLog(runner git.Runner) *Log {
+	return &Log{git: runner}
+}
+
+// Path は状態ファイルの絶対パスを返す。
+func (l *Log) Path(ctx context.Context) (string, error) {
+	path, err := git.Output(ctx, l.git, "rev-parse", "--git-path", StateFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve rewrite state file: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.git.Dir(), path)
+	}
+	return path, nil
+}
+
+// Append は record を状態ファイルに追記する。
+func (l *Log) Append(ctx context.Context, record Record) error {
+	path, err := l.Path(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode rewrite record: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open rewrite state file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write rewrite state file: %w", err)
+	}
+	return nil
+}
+
+// Read は状態ファイルの記録を古い順に返す。ファイルが無い場合は空。
+func (l *Log) Read(ctx context.Context) ([]Record, error) {
+	path, err := l.Path(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rewrite state file: %w", err)
+	}
+
+	var records []Record
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("malformed rewrite record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Compose は first の後に second が行われた場合の、first 前から second 後への対応を返す。
+// first で作られたコミットが second でさらに書き換えられた場合は最終的なコミットに置き換え、
+// first の前から存在し second のみで書き換えられたコミットはそのまま加える。
+func Compose(first, second []Entry) []Entry {
+	next := make(map[string]string, len(second))
+	for _, entry := range second {
+		next[entry.Old] = entry.New
+	}
+
+	// first で作られた中間のコミットは、最終的な対応には現れない。
+	intermediate := make(map[string]bool, len(first))
+	composed := make([]Entry, 0, len(first)+len(second))
+	for _, entry := range first {
+		intermediate[entry.New] = true
+		if rewritten, ok := next[entry.New]; ok {
+			entry.New = rewritten
+		}
+		composed = append(composed, entry)
+	}
+	for _, entry := range second {
+		if !intermediate[entry.Old] {
+			composed = append(composed, entry)
+		}
+	}
+	return composed
+}
+
+// MoveNotes は refs の各 notes ref で、書き換え前のコミットに付いた note を書き換え後のコミットへ移す。
+// 複数のコミットが一つにまとめられた場合は、最初に対応付けられたコミットの note を残す。
+func MoveNotes(ctx context.Context, runner git.Runner, refs []string, entries []Entry) error {
+	for _, ref := range refs {
+		annotated, err := notedObjects(ctx, runner, ref)
+		if err != nil {
+			return err
+		}
+
+		var copies, removals strings.Builder
+		for _, entry := range entries {
+			if !annotated[entry.Old] {
+				continue
+			}
+			if !annotated[entry.New] {
+				fmt.Fprintf(&copies, "%s %s\n", entry.Old, entry.New)
+				annotated[entry.New] = true
+			}
+			fmt.Fprintf(&removals, "%s\n", entry.Old)
+		}
+		if removals.Len() == 0 {
+			continue
+		}
+
+		if copies.Len() > 0 {
+			if _, err := runner.RunWithOptions(ctx, git.RunOptions{Stdin: strings.NewReader(copies.String())},
+				"notes", "--ref", ref, "copy", "--stdin"); err != nil {
+				return fmt.Errorf("failed to copy notes in %s: %w", ref, err)
+			}
+		}
+		if _, err := runner.RunWithOptions(ctx, git.RunOptions{Stdin: strings.NewReader(removals.String())},
+			"notes", "--ref", ref, "remove", "--ignore-missing", "--stdin"); err != nil {
+			return fmt.Errorf("failed to remove moved notes in %s: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+// notedObjects は notes ref に note が付いているオブジェクトの集合を返す。
+func notedObjects(ctx context.Context, runner git.Runner, ref string) (map[string]bool, error) {
+	annotated := make(map[string]bool)
+	exists, err := git.Succeeds(ctx, runner, "rev-parse", "--verify", "--quiet", expandNotesRef(ref))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve notes ref %s: %w", ref, err)
+	}
+	if !exists {
+		return annotated, nil
+	}
+
+	lines, err := git.Lines(ctx, runner, "notes", "--ref", ref, "list")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notes in %s: %w", ref, err)
+	}
+	for _, line := range lines {
+		// 各行は "<note blob> <注釈対象のオブジェクト>"。
+		if fields := strings.Fields(line); len(fields) == 2 {
+			annotated[fields[1]] = true
+		}
+	}
+	return annotated, nil
+}
+
+// expandNotesRef は git notes --ref と同様に、短い名前を refs/notes/ 配下の ref に展開する。
+func expandNotesRef(ref string) string {
+	switch {
+	case strings.HasPrefix(ref, "refs/notes/"):
+		return ref
+	case strings.HasPrefix(ref, "notes/"):
+		return "refs/" + ref
+	default:
+		return "refs/notes/" + ref
+	}
+}
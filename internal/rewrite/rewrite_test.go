@@ -0,0 +1,93 @@
+package rewrite
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/testutil"
+)
+
+func TestCompose(t *testing.T) {
+	first := []Entry{{Old: "a", New: "b"}, {Old: "x", New: "y"}}
+	second := []Entry{{Old: "b", New: "c"}, {Old: "z", New: "w"}}
+
+	got := Compose(first, second)
+	want := []Entry{{Old: "a", New: "c"}, {Old: "x", New: "y"}, {Old: "z", New: "w"}}
+	if len(got) != len(want) {
+		t.Fatalf("Compose() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Compose()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := Compose(nil, second); len(got) != 2 {
+		t.Errorf("Compose(nil, second) should return second, got %+v", got)
+	}
+}
+
+func TestLogAppendAndRead(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	log := NewLog(git.NewRunner("git", repo))
+
+	for _, operation := range []string{"autosquash", "compact"} {
+		record := Record{Time: time.Now(), Branch: "main", Operation: operation, Rewrites: []Entry{{Old: "a", New: "b"}}}
+		if err := log.Append(context.Background(), record); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+
+	path, _ := log.Path(context.Background())
+	if want := filepath.Join(repo, ".git", "fcsm", "rewrites.jsonl"); path != want {
+		t.Errorf("Path() = %s, want %s", path, want)
+	}
+
+	records, err := log.Read(context.Background())
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Operation != "autosquash" || records[1].Rewrites[0].New != "b" {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+}
+
+func TestMoveNotes(t *testing.T) {
+	if !testutil.GitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	repo := testutil.CreateRepository(t)
+	run := func(args ...string) string {
+		return testutil.Git(t, repo, args...)
+	}
+
+	var commits []string
+	for _, message := range []string{"one", "two", "three"} {
+		run("commit", "--allow-empty", "-m", message)
+		commits = append(commits, run("rev-parse", "HEAD"))
+	}
+	run("notes", "add", "-m", "ci: one", commits[0])
+	run("notes", "add", "-m", "ci: two", commits[1])
+
+	// one と two が three にまとめられたものとして移す。
+	entries := []Entry{{Old: commits[0], New: commits[2]}, {Old: commits[1], New: commits[2]}}
+	if err := MoveNotes(context.Background(), git.NewRunner("git", repo), []string{"refs/notes/commits", "ci"}, entries); err != nil {
+		t.Fatalf("MoveNotes() failed: %v", err)
+	}
+
+	if note := run("notes", "show", commits[2]); note != "ci: one" {
+		t.Errorf("Note of the first commit should be moved, got %q", note)
+	}
+	if notes := run("notes", "list"); strings.Count(notes, "\n") != 0 {
+		t.Errorf("Old notes should be removed, got:\n%s", notes)
+	}
+}
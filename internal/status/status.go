@@ -0,0 +1,231 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fixup-commit-sync-manager/internal/backup"
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/fixup"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
+	"fixup-commit-sync-manager/internal/repolock"
+	fcsync "fixup-commit-sync-manager/internal/sync"
+	"fixup-commit-sync-manager/internal/vhdx"
+)
+
+// DaemonTimeout は実行中の run デーモンへの問い合わせを待つ最大時間。
+const DaemonTimeout = 2 * time.Second
+
+// 直近の実行結果の取得元。
+const (
+	// SourceDaemon は実行中の run デーモンから取得したことを表す。
+	SourceDaemon = "daemon"
+	// SourceHistory はデーモンが動いていないため、Dev リポジトリの実行履歴から取得したことを表す。
+	SourceHistory = "history"
+	// SourceRepository は実行履歴も無いため、Ops リポジトリの同期コミットとバックアップ ref から推定したことを表す。
+	SourceRepository = "repository"
+)
+
+// Report は現在の同期の状態。取得できなかった項目は空のままとし、理由を Errors に記録する。
+type Report struct {
+	DevBranch string `json:"devBranch"`
+	OpsBranch string `json:"opsBranch"`
+	// BranchesMatch は Dev と Ops のカレントブランチが一致しているかを表す。
+	BranchesMatch bool `json:"branchesMatch"`
+	// PendingChanges は今同期すると Ops へ反映される変更。
+	PendingChanges *PendingChanges `json:"pendingChanges,omitempty"`
+	// PendingFixups は Ops のカレントブランチに残っている未公開の fixup!/squash!/amend! コミット。
+	PendingFixups []fixup.PlannedCommit `json:"pendingFixups"`
+	// DaemonPID は実行中の run デーモンのプロセス ID。動いていない場合は 0。
+	DaemonPID int `json:"daemonPid,omitempty"`
+	// Source は LastSync と LastFixup の取得元（daemon / history / repository）。
+	Source    string           `json:"source"`
+	LastSync  *control.RunInfo `json:"lastSync,omitempty"`
+	LastFixup *control.RunInfo `json:"lastFixup,omitempty"`
+	Paused    bool             `json:"paused"`
+	// PausedBy は一時停止の理由（一時停止ファイルのパス、または ctl pause）。
+	PausedBy string     `json:"pausedBy,omitempty"`
+	Lock     *LockState `json:"lock,omitempty"`
+	VHDX     *VHDXState `json:"vhdx,omitempty"`
+	Errors   []string   `json:"errors,omitempty"`
+}
+
+// PendingChanges は同期待ちの変更。
+type PendingChanges struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+}
+
+// Count は同期待ちのファイル数を返す。
+func (p *PendingChanges) Count() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.Added) + len(p.Modified) + len(p.Deleted)
+}
+
+// LockState は Ops リポジトリのロックの保持者。
+type LockState struct {
+	repolock.Info
+	// Stale は保持者のプロセスが終了しているか、ハートビートが途絶えていることを表す。
+	Stale bool `json:"stale"`
+}
+
+// VHDXState は VHDX のマウント状態。
+type VHDXState struct {
+	Path       string `json:"path"`
+	MountPoint string `json:"mountPoint"`
+	Mounted    bool   `json:"mounted"`
+}
+
+// Collect は cfg のリポジトリの状態を集める。リポジトリやファイルは変更しない。
+// cfg.OpsRepoPath には実際に使用している Ops リポジトリのパスを指定する。
+func Collect(ctx context.Context, cfg *config.Config) *Report {
+	report := &Report{Source: SourceRepository}
+	// git status などはインデックスの stat 情報を書き戻すために index.lock を取得することがあり、
+	// 並行して動く sync / fixup の git 操作を失敗させるため、任意のロックを取得しないようにする。
+	dev := git.WithEnv(git.NewConfiguredRunner(cfg, cfg.DevRepoPath), "GIT_OPTIONAL_LOCKS=0")
+	ops := git.WithEnv(git.NewConfiguredRunner(cfg, cfg.OpsRepoPath), "GIT_OPTIONAL_LOCKS=0")
+
+	var err error
+	if report.DevBranch, err = git.Output(ctx, dev, "branch", "--show-current"); err != nil {
+		report.addError("dev branch", err)
+	}
+	if report.OpsBranch, err = git.Output(ctx, ops, "branch", "--show-current"); err != nil {
+		report.addError("ops branch", err)
+	}
+	report.BranchesMatch = report.DevBranch != "" && report.DevBranch == report.OpsBranch
+
+	if pending, err := fcsync.NewFileSyncerWithRunners(cfg, dev, ops).Pending(ctx); err != nil {
+		report.addError("pending changes", err)
+	} else {
+		report.PendingChanges = &PendingChanges{Added: pending.FilesAdded, Modified: pending.FilesModified, Deleted: pending.FilesDeleted}
+	}
+
+	if report.PendingFixups, err = fixup.NewFixupManagerWithRunners(cfg, dev, ops).PendingFixups(ctx); err != nil {
+		report.addError("pending fixups", err)
+	}
+	if report.PendingFixups == nil {
+		report.PendingFixups = []fixup.PlannedCommit{}
+	}
+
+	if cfg.PauseLockFile != "" {
+		pauseFile := filepath.Join(cfg.DevRepoPath, cfg.PauseLockFile)
+		if _, err := os.Stat(pauseFile); err == nil {
+			report.Paused = true
+			report.PausedBy = pauseFile
+		}
+	}
+
+	if holder, stale, err := repolock.NewLocker(cfg, ops).Holder(ctx); err != nil {
+		report.addError("lock", err)
+	} else if holder != nil {
+		report.Lock = &LockState{Info: *holder, Stale: stale}
+	}
+
+	if cfg.VHDXPath != "" {
+		report.VHDX = &VHDXState{
+			Path:       cfg.VHDXPath,
+			MountPoint: cfg.MountPoint,
+			Mounted:    vhdx.NewManager(cfg.VHDXPath, cfg.MountPoint).IsMounted(),
+		}
+	}
+
+	if daemon, err := queryDaemon(ctx, ops); err == nil {
+		report.Source = SourceDaemon
+		report.DaemonPID = daemon.PID
+		report.LastSync = daemon.LastSync
+		report.LastFixup = daemon.LastFixup
+		if daemon.Paused && !report.Paused {
+			report.Paused = true
+			report.PausedBy = "ctl pause"
+		}
+	} else {
+		if !errors.Is(err, control.ErrNotRunning) {
+			report.addError("daemon", err)
+		}
+		report.LastSync, report.LastFixup = lastRunsFromHistory(ctx, history.NewLog(dev))
+		if report.LastSync != nil || report.LastFixup != nil {
+			report.Source = SourceHistory
+		} else {
+			report.LastSync, report.LastFixup = lastRunsFromRepository(ctx, cfg, ops, report.OpsBranch)
+		}
+	}
+
+	return report
+}
+
+func (r *Report) addError(item string, err error) {
+	r.Errors = append(r.Errors, fmt.Sprintf("%s: %v", item, err))
+}
+
+// queryDaemon は実行中の run デーモンの状態を問い合わせる。
+func queryDaemon(ctx context.Context, ops git.Runner) (*control.Status, error) {
+	socketPath, err := control.SocketPath(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, DaemonTimeout)
+	defer cancel()
+	resp, err := control.Send(ctx, socketPath, control.Request{Command: control.CommandStatus})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.OK || resp.Status == nil {
+		return nil, fmt.Errorf("unexpected response from daemon: %s", resp.Error)
+	}
+	return resp.Status, nil
+}
+
+// lastRunsFromHistory は実行履歴から直近の sync / fixup の結果を返す。
+func lastRunsFromHistory(ctx context.Context, log *history.Log) (lastSync, lastFixup *control.RunInfo) {
+	toRunInfo := func(entryType string) *control.RunInfo {
+		entry, err := log.Last(ctx, entryType)
+		if err != nil || entry == nil {
+			return nil
+		}
+		return &control.RunInfo{Operation: entry.Type, Time: entry.Time, Duration: entry.Duration, Error: entry.Error}
+	}
+	return toRunInfo(history.TypeSync), toRunInfo(history.TypeFixup)
+}
+
+// lastRunsFromRepository は Ops のカレントブランチの最新の同期コミットと、最新の fixup! コミットまたは
+// autosquash 前のバックアップから直近の sync / fixup の時刻を推定する。
+// 失敗した実行は記録が残らないため、成功した実行のみが対象となる。
+func lastRunsFromRepository(ctx context.Context, cfg *config.Config, ops git.Runner, branch string) (lastSync, lastFixup *control.RunInfo) {
+	if t, ok := lastCommitTime(ctx, ops, "^"+fcsync.CommitTrailer+":"); ok {
+		lastSync = &control.RunInfo{Operation: "sync", Time: t}
+	}
+
+	latest, found := lastCommitTime(ctx, ops, "^fixup! ")
+	if backups, err := backup.NewManager(cfg, ops).List(ctx, branch); err == nil && len(backups) > 0 && backups[0].Time.After(latest) {
+		latest, found = backups[0].Time, true
+	}
+	if found {
+		lastFixup = &control.RunInfo{Operation: "fixup", Time: latest}
+	}
+	return lastSync, lastFixup
+}
+
+// lastCommitTime は HEAD から辿れる、メッセージが pattern に一致する最新のコミットの時刻を返す。
+func lastCommitTime(ctx context.Context, ops git.Runner, pattern string) (time.Time, bool) {
+	output, err := git.Output(ctx, ops, "log", "-1", "--format=%ct", "--grep="+pattern)
+	if err != nil || output == "" {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
@@ -0,0 +1,174 @@
+package status
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/control"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
+)
+
+func isGitAvailable() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// createTestRepositories は main ブランチにコミットが 1 つある Dev と Ops のリポジトリを作成する。
+func createTestRepositories(t *testing.T) (dev, ops string, run func(dir string, args ...string) string) {
+	t.Helper()
+	run = func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+
+	dev, ops = t.TempDir(), t.TempDir()
+	for _, repo := range []string{dev, ops} {
+		run(repo, "init", "-q")
+		run(repo, "checkout", "-q", "-b", "main")
+		os.WriteFile(filepath.Join(repo, "base.cpp"), []byte("base"), 0644)
+		run(repo, "add", "base.cpp")
+		run(repo, "commit", "-qm", "Base")
+	}
+	return dev, ops, run
+}
+
+func newTestConfig(dev, ops string) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = dev
+	cfg.OpsRepoPath = ops
+	return cfg
+}
+
+// TestCollectLeavesIndexUntouched は状態の収集で、stat 情報が古くなったインデックスを書き戻さないことをテストする。
+// 書き戻す際に index.lock を取得すると、並行して動く sync / fixup の git 操作が失敗するため。
+func TestCollectLeavesIndexUntouched(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	dev, ops, _ := createTestRepositories(t)
+	touched := time.Now().Add(time.Hour)
+	indexes := map[string][]byte{}
+	for _, repo := range []string{dev, ops} {
+		// 内容を変えずに更新時刻だけを変え、通常の git status ならインデックスを更新する状態にする。
+		os.Chtimes(filepath.Join(repo, "base.cpp"), touched, touched)
+		data, err := os.ReadFile(filepath.Join(repo, ".git", "index"))
+		if err != nil {
+			t.Fatalf("Failed to read index: %v", err)
+		}
+		indexes[repo] = data
+	}
+
+	report := Collect(context.Background(), newTestConfig(dev, ops))
+	if len(report.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", report.Errors)
+	}
+	for repo, before := range indexes {
+		after, err := os.ReadFile(filepath.Join(repo, ".git", "index"))
+		if err != nil || !bytes.Equal(before, after) {
+			t.Errorf("Collect() should not rewrite the index of %s (err: %v)", repo, err)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	dev, ops, run := createTestRepositories(t)
+	os.WriteFile(filepath.Join(ops, "synced.cpp"), []byte("synced"), 0644)
+	run(ops, "add", "synced.cpp")
+	run(ops, "commit", "-qm", "Auto-sync\n\nFcsm-Sync: auto")
+	os.WriteFile(filepath.Join(ops, "base.cpp"), []byte("base fixed"), 0644)
+	run(ops, "commit", "-qam", "fixup! Base")
+
+	os.WriteFile(filepath.Join(dev, "new.cpp"), []byte("new"), 0644)
+	os.WriteFile(filepath.Join(dev, ".sync-paused"), nil, 0644)
+
+	report := Collect(context.Background(), newTestConfig(dev, ops))
+	if len(report.Errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", report.Errors)
+	}
+	if report.DevBranch != "main" || report.OpsBranch != "main" || !report.BranchesMatch {
+		t.Errorf("Unexpected branches: dev=%s ops=%s match=%v", report.DevBranch, report.OpsBranch, report.BranchesMatch)
+	}
+	if report.PendingChanges.Count() != 1 || report.PendingChanges.Added[0] != "new.cpp" {
+		t.Errorf("Expected new.cpp to be pending, got %+v", report.PendingChanges)
+	}
+	if len(report.PendingFixups) != 1 || report.PendingFixups[0].Subject != "fixup! Base" {
+		t.Errorf("Expected one pending fixup, got %+v", report.PendingFixups)
+	}
+	if !report.Paused || report.PausedBy != filepath.Join(dev, ".sync-paused") {
+		t.Errorf("Expected paused by the pause file, got paused=%v by %q", report.Paused, report.PausedBy)
+	}
+	if report.Source != SourceRepository || report.LastSync == nil || report.LastFixup == nil {
+		t.Errorf("Expected last runs inferred from the repository, got source=%s sync=%+v fixup=%+v",
+			report.Source, report.LastSync, report.LastFixup)
+	}
+	if report.Lock != nil || report.VHDX != nil {
+		t.Errorf("Expected no lock and no VHDX, got %+v %+v", report.Lock, report.VHDX)
+	}
+
+	// 実行履歴があれば、リポジトリからの推定より優先する。
+	failed := history.Entry{Time: time.Now(), Type: history.TypeSync, Error: "ops unavailable"}
+	if err := history.NewLog(git.NewRunner("git", dev)).Append(context.Background(), failed); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	report = Collect(context.Background(), newTestConfig(dev, ops))
+	if report.Source != SourceHistory || report.LastSync == nil || report.LastSync.Error != "ops unavailable" || report.LastFixup != nil {
+		t.Errorf("Expected last runs from the history, got source=%s sync=%+v fixup=%+v",
+			report.Source, report.LastSync, report.LastFixup)
+	}
+
+	run(ops, "checkout", "-q", "-b", "other")
+	if report := Collect(context.Background(), newTestConfig(dev, ops)); report.BranchesMatch {
+		t.Error("Different branches should not match")
+	}
+}
+
+func TestCollectFromDaemon(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	dev, ops, _ := createTestRepositories(t)
+	socketPath, err := control.SocketPath(context.Background(), git.NewRunner("git", ops))
+	if err != nil {
+		t.Fatalf("SocketPath() failed: %v", err)
+	}
+
+	lastSync := &control.RunInfo{Operation: "sync", Time: time.Now(), Error: "ops unavailable"}
+	server, err := control.Listen(socketPath, func(req control.Request) control.Response {
+		return control.Response{OK: true, Status: &control.Status{PID: 4242, Paused: true, LastSync: lastSync}}
+	})
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer server.Close()
+
+	report := Collect(context.Background(), newTestConfig(dev, ops))
+	if report.Source != SourceDaemon || report.DaemonPID != 4242 {
+		t.Errorf("Expected the daemon as the source, got %s (pid %d)", report.Source, report.DaemonPID)
+	}
+	if report.LastSync == nil || report.LastSync.Error != "ops unavailable" || report.LastFixup != nil {
+		t.Errorf("Expected the daemon's last runs, got sync=%+v fixup=%+v", report.LastSync, report.LastFixup)
+	}
+	if !report.Paused || report.PausedBy != "ctl pause" {
+		t.Errorf("Expected paused by ctl pause, got paused=%v by %q", report.Paused, report.PausedBy)
+	}
+}
@@ -1,18 +1,31 @@
 package sync
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"fixup-commit-sync-manager/internal/autostash"
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
+	"fixup-commit-sync-manager/internal/indexlock"
+	"fixup-commit-sync-manager/internal/metrics"
+	"fixup-commit-sync-manager/internal/pathfilter"
+	"fixup-commit-sync-manager/internal/repolock"
 )
 
+// CommitTrailer は同期コミットに付与する trailer のキー。ツールが作成したコミットの識別に使用する。
+const CommitTrailer = "Fcsm-Sync"
+
 type FileSyncer struct {
 	cfg *config.Config
+	dev git.Runner
+	ops git.Runner
 }
 
 type SyncResult struct {
@@ -20,48 +33,130 @@ type SyncResult struct {
 	FilesModified []string
 	FilesDeleted  []string
 	CommitHash    string
+	// UnrelatedChanges は同期対象外の Ops 側の未コミット変更。コミットには含めず報告のみ行う。
+	UnrelatedChanges []string
 }
 
 func NewFileSyncer(cfg *config.Config) *FileSyncer {
-	return &FileSyncer{cfg: cfg}
+	return NewFileSyncerWithRunners(cfg,
+		git.NewConfiguredRunner(cfg, cfg.DevRepoPath),
+		git.NewConfiguredRunner(cfg, cfg.OpsRepoPath))
+}
+
+// NewFileSyncerWithRunners は Dev/Ops それぞれの git Runner を指定して FileSyncer を作成する。
+func NewFileSyncerWithRunners(cfg *config.Config, dev, ops git.Runner) *FileSyncer {
+	return &FileSyncer{cfg: cfg, dev: dev, ops: ops}
+}
+
+// Sync は Dev のカレントブランチの変更を Ops の同じブランチへ同期してコミットし、結果をメトリクスに記録する。
+// ctx が終了した場合、Ops のファイルを書き換える前であれば中止する。書き換えた後はコミットまで完了させる。
+func (s *FileSyncer) Sync(ctx context.Context) (*SyncResult, error) {
+	started := time.Now()
+	result, err := s.sync(ctx)
+
+	cycle := metrics.SyncCycle{Duration: time.Since(started), Err: err}
+	if result != nil {
+		cycle.Added = len(result.FilesAdded)
+		cycle.Modified = len(result.FilesModified)
+		cycle.Deleted = len(result.FilesDeleted)
+	}
+	metrics.ObserveSync(cycle)
+	s.recordHistory(ctx, started, cycle.Duration, result, err)
+	return result, err
+}
+
+// recordHistory は 1 回の同期の結果を Dev リポジトリの実行履歴に記録する。変更が無かった回もファイルとコミットを空として記録する。
+// 一時停止中の回は同期を行っていないため記録しない。
+func (s *FileSyncer) recordHistory(ctx context.Context, started time.Time, duration time.Duration, result *SyncResult, err error) {
+	if err != nil && metrics.Cause(err) == metrics.CausePaused {
+		return
+	}
+
+	// 中断した回も記録できるよう、ctx の終了を待たずに記録する。
+	ctx = context.WithoutCancel(ctx)
+	entry := history.Entry{Time: started, Type: history.TypeSync, Duration: duration}
+	entry.Branch, _ = s.getDevCurrentBranch(ctx)
+	if result != nil && result.CommitHash != "" {
+		entry.Files = result.syncedPaths()
+		entry.Commits = []string{result.CommitHash}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Cause = metrics.Cause(err)
+	}
+	if err := history.NewLog(s.dev).Append(ctx, entry); err != nil {
+		fmt.Printf("Warning: failed to record sync history: %v\n", err)
+	}
 }
 
-func (s *FileSyncer) Sync() (*SyncResult, error) {
+func (s *FileSyncer) sync(ctx context.Context) (result *SyncResult, err error) {
 	if s.isPaused() {
-		return nil, fmt.Errorf("sync is paused by lock file: %s", s.cfg.PauseLockFile)
+		return nil, metrics.WithCause(metrics.CausePaused, fmt.Errorf("sync is paused by lock file: %s", s.cfg.PauseLockFile))
 	}
 
 	if err := s.validateRepositories(); err != nil {
-		return nil, fmt.Errorf("repository validation failed: %w", err)
+		return nil, metrics.WithCause(metrics.CauseValidation, fmt.Errorf("repository validation failed: %w", err))
+	}
+
+	// 他のプロセスの sync/fixup と同時に Ops リポジトリを変更しないよう、完了までロックを保持する。
+	lock, err := repolock.NewLocker(s.cfg, s.ops).Acquire(ctx, "sync")
+	if err != nil {
+		return nil, metrics.WithCause(metrics.CauseLock, fmt.Errorf("failed to lock ops repository: %w", err))
+	}
+	defer lock.Release()
+	// 他のプロセスにロックを引き継がれた場合は以降の git 操作を中断し、ロックの失敗として返す。
+	ctx = lock.Context()
+	defer func() {
+		if lost := lock.Lost(); lost != nil && err != nil {
+			result, err = nil, metrics.WithCause(metrics.CauseLock, fmt.Errorf("stopped because %w: %v", lost, err))
+		}
+	}()
+
+	// 異常終了した git が残した index.lock があると以降の操作がすべて失敗するため先に除去する。
+	if err := indexlock.NewRecoverer(s.cfg, s.ops).Recover(ctx, "sync"); err != nil {
+		return nil, metrics.WithCause(metrics.CauseIndexLock, fmt.Errorf("failed to recover index.lock: %w", err))
 	}
 
 	// Dev側のカレントブランチを取得。
-	devBranch, err := s.getDevCurrentBranch()
+	devBranch, err := s.getDevCurrentBranch(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get dev current branch: %w", err)
+		return nil, metrics.WithCause(metrics.CauseBranch, fmt.Errorf("failed to get dev current branch: %w", err))
 	}
 
 	// Ops側を同じブランチに切り替え。
-	if err := s.ensureOpsBranch(devBranch); err != nil {
-		return nil, fmt.Errorf("failed to ensure ops branch: %w", err)
+	if err := s.ensureOpsBranch(ctx, devBranch); err != nil {
+		return nil, metrics.WithCause(metrics.CauseBranch, fmt.Errorf("failed to ensure ops branch: %w", err))
 	}
 
-	changes, err := s.detectChanges()
+	changes, err := s.detectChanges(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to detect changes: %w", err)
+		return nil, metrics.WithCause(metrics.CauseDetect, fmt.Errorf("failed to detect changes: %w", err))
 	}
 
-	if len(changes.FilesAdded)+len(changes.FilesModified)+len(changes.FilesDeleted) == 0 {
-		return &SyncResult{}, nil
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
+	// ここから先は Ops のファイルを書き換えるため、中断するとコミットされない変更が残る。
+	// ctx が終了しても、git のタイムアウトの範囲でコミットまで完了させる。
+	ctx = context.WithoutCancel(ctx)
+
 	if err := s.applyChanges(changes); err != nil {
-		return nil, fmt.Errorf("failed to apply changes: %w", err)
+		return nil, metrics.WithCause(metrics.CauseApply, fmt.Errorf("failed to apply changes: %w", err))
 	}
 
-	commitHash, err := s.commitChanges(changes)
+	// Ops 側で実際に差分が生じたパスのみをコミット対象とする。
+	if err := s.reconcileChanges(ctx, changes); err != nil {
+		return nil, metrics.WithCause(metrics.CauseDetect, fmt.Errorf("failed to inspect ops changes: %w", err))
+	}
+
+	if len(changes.FilesAdded)+len(changes.FilesModified)+len(changes.FilesDeleted) == 0 {
+		return &SyncResult{UnrelatedChanges: changes.UnrelatedChanges}, nil
+	}
+
+	commitHash, err := s.commitChanges(ctx, changes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to commit changes: %w", err)
+		return nil, metrics.WithCause(metrics.CauseCommit, fmt.Errorf("failed to commit changes: %w", err))
 	}
 
 	changes.CommitHash = commitHash
@@ -74,6 +169,19 @@ func (s *FileSyncer) isPaused() bool {
 	return err == nil
 }
 
+// Probe は同期を試さずに、両方のリポジトリが参照できるか（マウントが外れていないかなど）を確認する。
+func (s *FileSyncer) Probe(ctx context.Context) error {
+	if err := s.validateRepositories(); err != nil {
+		return err
+	}
+	for _, runner := range []git.Runner{s.dev, s.ops} {
+		if _, err := runner.Run(ctx, "rev-parse", "--git-dir"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *FileSyncer) validateRepositories() error {
 	devGitDir := filepath.Join(s.cfg.DevRepoPath, ".git")
 	if _, err := os.Stat(devGitDir); err != nil {
@@ -88,19 +196,65 @@ func (s *FileSyncer) validateRepositories() error {
 	return nil
 }
 
-func (s *FileSyncer) detectChanges() (*SyncResult, error) {
+// Pending は同期を実行せずに、今同期すると Ops へ反映される変更を返す。リポジトリは変更しない。
+// Ops のブランチが Dev と異なる場合は、現在の Ops の作業ツリーとの比較となる。
+func (s *FileSyncer) Pending(ctx context.Context) (*SyncResult, error) {
+	if err := s.validateRepositories(); err != nil {
+		return nil, err
+	}
+	changes, err := s.detectChanges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := git.Status(ctx, s.ops, "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	uncommitted := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		uncommitted[entry.Path] = true
+	}
+
+	// 反映しても Ops 側に差分が生じないパスは除く。
+	differs := func(files []string, deleted bool) []string {
+		kept := []string{}
+		for _, file := range files {
+			if uncommitted[file] || (deleted && s.fileExistsInOps(file)) || (!deleted && !s.sameInOps(file)) {
+				kept = append(kept, file)
+			}
+		}
+		return kept
+	}
+	changes.FilesAdded = differs(changes.FilesAdded, false)
+	changes.FilesModified = differs(changes.FilesModified, false)
+	changes.FilesDeleted = differs(changes.FilesDeleted, true)
+	return changes, nil
+}
+
+// sameInOps は Dev のファイルが Ops の同じパスのファイルと同じ内容かを返す。
+func (s *FileSyncer) sameInOps(filePath string) bool {
+	dev, err := os.ReadFile(filepath.Join(s.cfg.DevRepoPath, filePath))
+	if err != nil {
+		return false
+	}
+	ops, err := os.ReadFile(filepath.Join(s.cfg.OpsRepoPath, filePath))
+	return err == nil && bytes.Equal(dev, ops)
+}
+
+func (s *FileSyncer) detectChanges(ctx context.Context) (*SyncResult, error) {
 	result := &SyncResult{
 		FilesAdded:    []string{},
 		FilesModified: []string{},
 		FilesDeleted:  []string{},
 	}
 
-	trackedChanges, err := s.getTrackedChanges()
+	trackedChanges, err := s.getTrackedChanges(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get tracked changes: %w", err)
 	}
 
-	newFiles, err := s.getNewFiles()
+	newFiles, err := s.getNewFiles(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get new files: %w", err)
 	}
@@ -131,76 +285,32 @@ func (s *FileSyncer) detectChanges() (*SyncResult, error) {
 	return result, nil
 }
 
-func (s *FileSyncer) getTrackedChanges() ([]string, error) {
+func (s *FileSyncer) getTrackedChanges(ctx context.Context) ([]string, error) {
 	// 直前のコミットとの差分を取得。
-	cmd := exec.Command(s.cfg.GitExecutable, "diff", "--name-only", "HEAD^")
-	cmd.Dir = s.cfg.DevRepoPath
-	output, err := cmd.Output()
+	// リネームは削除と追加の組として扱うため --no-renames を指定する。
+	files, err := git.Paths(ctx, s.dev, "diff", "--name-only", "--no-renames", "-z", "HEAD^")
 	if err != nil {
 		// HEAD^が存在しない場合（初回コミット）は全ファイルを対象とする。
-		cmd = exec.Command(s.cfg.GitExecutable, "diff", "--name-only", "--cached")
-		cmd.Dir = s.cfg.DevRepoPath
-		output, err = cmd.Output()
+		files, err = git.Paths(ctx, s.dev, "diff", "--name-only", "--no-renames", "-z", "--cached")
 		if err != nil {
 			return nil, fmt.Errorf("git diff failed: %w", err)
 		}
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var files []string
-	for _, line := range lines {
-		if line != "" {
-			files = append(files, line)
-		}
-	}
-
 	return files, nil
 }
 
-func (s *FileSyncer) getNewFiles() ([]string, error) {
-	cmd := exec.Command(s.cfg.GitExecutable, "ls-files", "--others", "--exclude-standard")
-	cmd.Dir = s.cfg.DevRepoPath
-	output, err := cmd.Output()
+func (s *FileSyncer) getNewFiles(ctx context.Context) ([]string, error) {
+	files, err := git.Paths(ctx, s.dev, "ls-files", "-z", "--others", "--exclude-standard")
 	if err != nil {
 		return nil, fmt.Errorf("git ls-files failed: %w", err)
 	}
 
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var files []string
-	for _, line := range lines {
-		if line != "" {
-			files = append(files, line)
-		}
-	}
-
 	return files, nil
 }
 
 func (s *FileSyncer) shouldIncludeFile(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-
-	for _, includeExt := range s.cfg.IncludeExtensions {
-		if ext == strings.ToLower(includeExt) {
-			return !s.isExcluded(filePath)
-		}
-	}
-
-	for _, pattern := range s.cfg.IncludePatterns {
-		if matched, _ := filepath.Match(pattern, filePath); matched {
-			return !s.isExcluded(filePath)
-		}
-	}
-
-	return false
-}
-
-func (s *FileSyncer) isExcluded(filePath string) bool {
-	for _, pattern := range s.cfg.ExcludePatterns {
-		if matched, _ := filepath.Match(pattern, filePath); matched {
-			return true
-		}
-	}
-	return false
+	return pathfilter.New(s.cfg).Match(filePath)
 }
 
 func (s *FileSyncer) fileExistsInOps(filePath string) bool {
@@ -286,64 +396,99 @@ func (s *FileSyncer) deleteFileFromOps(filePath string) error {
 	return nil
 }
 
-func (s *FileSyncer) commitChanges(changes *SyncResult) (string, error) {
-	originalDir, err := os.Getwd()
+// reconcileChanges は同期結果を Ops 側で実際に差分が生じたパスに絞り込み、
+// 同期対象外の Ops 側の変更を UnrelatedChanges に記録する。
+func (s *FileSyncer) reconcileChanges(ctx context.Context, changes *SyncResult) error {
+	entries, err := git.Status(ctx, s.ops, "--untracked-files=all")
 	if err != nil {
-		return "", fmt.Errorf("failed to get current directory: %w", err)
+		return err
+	}
+
+	changed := make(map[string]bool)
+	for _, entry := range entries {
+		changed[entry.Path] = true
+		if entry.OrigPath != "" {
+			changed[entry.OrigPath] = true
+		}
+	}
+
+	synced := make(map[string]bool)
+	keep := func(files []string) []string {
+		kept := []string{}
+		for _, file := range files {
+			if changed[file] {
+				kept = append(kept, file)
+				synced[file] = true
+			}
+		}
+		return kept
 	}
-	defer os.Chdir(originalDir)
+	changes.FilesAdded = keep(changes.FilesAdded)
+	changes.FilesModified = keep(changes.FilesModified)
+	changes.FilesDeleted = keep(changes.FilesDeleted)
 
-	if err := os.Chdir(s.cfg.OpsRepoPath); err != nil {
-		return "", fmt.Errorf("failed to change to ops repository: %w", err)
+	changes.UnrelatedChanges = nil
+	for _, entry := range entries {
+		if !synced[entry.Path] {
+			changes.UnrelatedChanges = append(changes.UnrelatedChanges, entry.Path)
+		}
 	}
 
-	if err := s.gitAddChanges(); err != nil {
+	return nil
+}
+
+// syncedPaths は同期で適用したすべてのパスを返す。
+func (r *SyncResult) syncedPaths() []string {
+	paths := make([]string, 0, len(r.FilesAdded)+len(r.FilesModified)+len(r.FilesDeleted))
+	paths = append(paths, r.FilesAdded...)
+	paths = append(paths, r.FilesModified...)
+	paths = append(paths, r.FilesDeleted...)
+	return paths
+}
+
+func (s *FileSyncer) commitChanges(ctx context.Context, changes *SyncResult) (string, error) {
+	paths := changes.syncedPaths()
+	if err := s.gitAddChanges(ctx, paths); err != nil {
 		return "", fmt.Errorf("failed to add changes: %w", err)
 	}
 
-	commitMsg := s.generateCommitMessage(changes)
-	if err := s.gitCommit(commitMsg); err != nil {
+	commitMsg := s.generateCommitMessage(changes) + "\n\n" + CommitTrailer + ": auto"
+	if err := s.gitCommit(ctx, commitMsg, paths); err != nil {
 		return "", fmt.Errorf("failed to commit changes: %w", err)
 	}
 
-	return s.getLastCommitHash()
+	return s.getLastCommitHash(ctx)
 }
 
-func (s *FileSyncer) gitAddChanges() error {
-	cmd := exec.Command(s.cfg.GitExecutable, "add", "-A")
-	cmd.Dir = s.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git add failed: %w, output: %s", err, string(output))
+// gitAddChanges は同期したパスのみをステージする。
+func (s *FileSyncer) gitAddChanges(ctx context.Context, paths []string) error {
+	if err := git.AddPaths(ctx, s.ops, paths); err != nil {
+		return fmt.Errorf("git add failed: %w", err)
 	}
 	return nil
 }
 
-func (s *FileSyncer) gitCommit(message string) error {
-	args := []string{"commit", "-m", message}
+// gitCommit は同期したパスのみをコミットする。
+func (s *FileSyncer) gitCommit(ctx context.Context, message string, paths []string) error {
+	args := []string{"-m", message}
 
 	if s.cfg.AuthorName != "" && s.cfg.AuthorEmail != "" {
 		author := fmt.Sprintf("%s <%s>", s.cfg.AuthorName, s.cfg.AuthorEmail)
 		args = append(args, "--author", author)
 	}
 
-	cmd := exec.Command(s.cfg.GitExecutable, args...)
-	cmd.Dir = s.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git commit failed: %w, output: %s", err, string(output))
+	if err := git.CommitPaths(ctx, s.ops, paths, args...); err != nil {
+		return fmt.Errorf("git commit failed: %w", err)
 	}
 	return nil
 }
 
-func (s *FileSyncer) getLastCommitHash() (string, error) {
-	cmd := exec.Command(s.cfg.GitExecutable, "rev-parse", "HEAD")
-	cmd.Dir = s.cfg.OpsRepoPath
-	output, err := cmd.Output()
+func (s *FileSyncer) getLastCommitHash(ctx context.Context) (string, error) {
+	hash, err := git.Output(ctx, s.ops, "rev-parse", "HEAD")
 	if err != nil {
 		return "", fmt.Errorf("failed to get commit hash: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return hash, nil
 }
 
 func (s *FileSyncer) generateCommitMessage(changes *SyncResult) string {
@@ -366,113 +511,16 @@ func (s *FileSyncer) generateCommitMessage(changes *SyncResult) string {
 }
 
 // getDevCurrentBranch はDev側のカレントブランチを取得する。
-func (s *FileSyncer) getDevCurrentBranch() (string, error) {
-	cmd := exec.Command(s.cfg.GitExecutable, "branch", "--show-current")
-	cmd.Dir = s.cfg.DevRepoPath
-	output, err := cmd.Output()
+func (s *FileSyncer) getDevCurrentBranch(ctx context.Context) (string, error) {
+	branch, err := git.Output(ctx, s.dev, "branch", "--show-current")
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch from dev repo: %w", err)
 	}
-	return strings.TrimSpace(string(output)), nil
+	return branch, nil
 }
 
 // ensureOpsBranch はOps側を指定されたブランチに切り替える。
-func (s *FileSyncer) ensureOpsBranch(targetBranch string) error {
-	originalDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
-	}
-	defer os.Chdir(originalDir)
-
-	if err := os.Chdir(s.cfg.OpsRepoPath); err != nil {
-		return fmt.Errorf("failed to change to ops repository: %w", err)
-	}
-
-	// 現在のブランチを確認。
-	currentBranch, err := s.getOpsCurrentBranch()
-	if err != nil {
-		return fmt.Errorf("failed to get current ops branch: %w", err)
-	}
-
-	// 既に目的のブランチにいる場合はスキップ。
-	if currentBranch == targetBranch {
-		return nil
-	}
-
-	// ブランチの存在確認。
-	if err := s.ensureBranchExists(targetBranch); err != nil {
-		return fmt.Errorf("failed to ensure branch exists: %w", err)
-	}
-
-	// ブランチ切り替え。
-	if err := s.gitCheckout(targetBranch); err != nil {
-		return fmt.Errorf("failed to checkout branch %s: %w", targetBranch, err)
-	}
-
-	return nil
-}
-
-// getOpsCurrentBranch はOps側のカレントブランチを取得する。
-func (s *FileSyncer) getOpsCurrentBranch() (string, error) {
-	cmd := exec.Command(s.cfg.GitExecutable, "branch", "--show-current")
-	cmd.Dir = s.cfg.OpsRepoPath
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get current branch from ops repo: %w", err)
-	}
-	return strings.TrimSpace(string(output)), nil
-}
-
-// ensureBranchExists は指定されたブランチが存在することを確認し、必要に応じて作成する。
-func (s *FileSyncer) ensureBranchExists(branchName string) error {
-	// ローカルブランチの存在確認。
-	cmd := exec.Command(s.cfg.GitExecutable, "show-ref", "--verify", "--quiet", "refs/heads/"+branchName)
-	cmd.Dir = s.cfg.OpsRepoPath
-	if err := cmd.Run(); err == nil {
-		return nil // ブランチが存在する。
-	}
-
-	// リモートブランチの存在確認。
-	cmd = exec.Command(s.cfg.GitExecutable, "show-ref", "--verify", "--quiet", "refs/remotes/origin/"+branchName)
-	cmd.Dir = s.cfg.OpsRepoPath
-	if err := cmd.Run(); err == nil {
-		// リモートブランチから作成。
-		return s.gitCreateBranchFromRemote(branchName)
-	}
-
-	// ブランチが存在しない場合は新規作成。
-	return s.gitCreateBranch(branchName)
-}
-
-// gitCreateBranch は新しいブランチを作成する。
-func (s *FileSyncer) gitCreateBranch(branchName string) error {
-	cmd := exec.Command(s.cfg.GitExecutable, "checkout", "-b", branchName)
-	cmd.Dir = s.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to create branch %s: %w, output: %s", branchName, err, string(output))
-	}
-	return nil
-}
-
-// gitCreateBranchFromRemote はリモートブランチから新しいローカルブランチを作成する。
-func (s *FileSyncer) gitCreateBranchFromRemote(branchName string) error {
-	cmd := exec.Command(s.cfg.GitExecutable, "checkout", "-b", branchName, "origin/"+branchName)
-	cmd.Dir = s.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to create branch %s from remote: %w, output: %s", branchName, err, string(output))
-	}
-	return nil
-}
-
-// gitCheckout は指定されたブランチに切り替える。
-func (s *FileSyncer) gitCheckout(branchName string) error {
-	cmd := exec.Command(s.cfg.GitExecutable, "checkout", branchName)
-	cmd.Dir = s.cfg.OpsRepoPath
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to checkout branch %s: %w, output: %s", branchName, err, string(output))
-	}
-	return nil
+// 切り替え前のブランチの未コミット作業は退避し、切り替え先で以前退避した作業があれば復元する。
+func (s *FileSyncer) ensureOpsBranch(ctx context.Context, targetBranch string) error {
+	return autostash.NewStasher(s.ops).Switch(ctx, targetBranch)
 }
@@ -1,12 +1,19 @@
 package sync
 
 import (
+	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/repolock"
 )
 
 func TestGetDevCurrentBranch(t *testing.T) {
@@ -36,7 +43,7 @@ func TestGetDevCurrentBranch(t *testing.T) {
 
 	syncer := NewFileSyncer(cfg)
 	
-	branch, err := syncer.getDevCurrentBranch()
+	branch, err := syncer.getDevCurrentBranch(context.Background())
 	if err != nil {
 		t.Fatalf("getDevCurrentBranch() failed: %v", err)
 	}
@@ -67,15 +74,15 @@ func TestEnsureOpsBranch(t *testing.T) {
 	syncer := NewFileSyncer(cfg)
 	
 	// 新しいブランチに切り替えテスト。
-	err := syncer.ensureOpsBranch("feature-new")
+	err := syncer.ensureOpsBranch(context.Background(), "feature-new")
 	if err != nil {
 		t.Fatalf("ensureOpsBranch() failed: %v", err)
 	}
 
 	// 現在のブランチを確認。
-	currentBranch, err := syncer.getOpsCurrentBranch()
+	currentBranch, err := git.Output(context.Background(), syncer.ops, "branch", "--show-current")
 	if err != nil {
-		t.Fatalf("getOpsCurrentBranch() failed: %v", err)
+		t.Fatalf("Failed to get ops current branch: %v", err)
 	}
 
 	if currentBranch != "feature-new" {
@@ -83,6 +90,59 @@ func TestEnsureOpsBranch(t *testing.T) {
 	}
 }
 
+func TestEnsureOpsBranchPreservesUncommittedWork(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping ops branch test")
+	}
+
+	tempDir := t.TempDir()
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryDynamic(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	cmd := exec.Command("git", "branch", "--show-current")
+	cmd.Dir = opsRepo
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to get initial branch: %v", err)
+	}
+	initialBranch := strings.TrimSpace(string(output))
+
+	cfg := &config.Config{
+		OpsRepoPath:   opsRepo,
+		GitExecutable: "git",
+	}
+
+	syncer := NewFileSyncer(cfg)
+
+	// 初期ブランチに未コミットの作業を残したまま切り替える。
+	wipFile := filepath.Join(opsRepo, "wip.cpp")
+	os.WriteFile(wipFile, []byte("// work in progress"), 0644)
+
+	if err := syncer.ensureOpsBranch(context.Background(), "feature-other"); err != nil {
+		t.Fatalf("ensureOpsBranch(feature-other) failed: %v", err)
+	}
+
+	if _, err := os.Stat(wipFile); !os.IsNotExist(err) {
+		t.Error("Uncommitted work should not leak into another branch")
+	}
+
+	// 元のブランチに戻ると作業が復元される。
+	if err := syncer.ensureOpsBranch(context.Background(), initialBranch); err != nil {
+		t.Fatalf("ensureOpsBranch(%s) failed: %v", initialBranch, err)
+	}
+
+	content, err := os.ReadFile(wipFile)
+	if err != nil {
+		t.Fatalf("Uncommitted work was not restored: %v", err)
+	}
+	if string(content) != "// work in progress" {
+		t.Errorf("Unexpected restored content: %q", string(content))
+	}
+}
+
 func TestDynamicBranchSyncFlow(t *testing.T) {
 	if !isGitAvailable() {
 		t.Skip("Git not available, skipping dynamic branch sync test")
@@ -159,7 +219,7 @@ func TestDynamicBranchSyncFlow(t *testing.T) {
 	syncer := NewFileSyncer(cfg)
 	
 	// 動的ブランチ追従付きの同期を実行。
-	result, err := syncer.Sync()
+	result, err := syncer.Sync(context.Background())
 	if err != nil {
 		t.Fatalf("Sync() failed: %v", err)
 	}
@@ -172,7 +232,7 @@ func TestDynamicBranchSyncFlow(t *testing.T) {
 	}
 
 	// Ops側のブランチを確認。
-	currentBranch, err := syncer.getOpsCurrentBranch()
+	currentBranch, err := git.Output(context.Background(), syncer.ops, "branch", "--show-current")
 	if err != nil {
 		t.Fatalf("Failed to get ops current branch: %v", err)
 	}
@@ -188,6 +248,252 @@ func TestDynamicBranchSyncFlow(t *testing.T) {
 	}
 }
 
+func TestSyncSpecialCharacterPaths(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping special path sync test")
+	}
+
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryDynamic(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryDynamic(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	// core.quotePath の既定値ではエスケープされるパスをコミットする。
+	committed := []string{"日本語.cpp", "with space.cpp", `quote"d.cpp`}
+	if runtime.GOOS != "windows" {
+		committed = append(committed, "new\nline.cpp")
+	}
+	os.MkdirAll(filepath.Join(devRepo, "ソース"), 0755)
+	committed = append(committed, filepath.ToSlash(filepath.Join("ソース", "モジュール.cpp")))
+
+	for _, name := range committed {
+		if err := os.WriteFile(filepath.Join(devRepo, name), []byte("// "+name), 0644); err != nil {
+			t.Fatalf("Failed to create %q: %v", name, err)
+		}
+	}
+	for _, args := range [][]string{{"git", "add", "-A"}, {"git", "commit", "-m", "Add special files"}} {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = devRepo
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("Failed to run %v: %v", args, err)
+		}
+	}
+
+	// 未追跡の新規ファイルも同期対象。
+	untracked := "未追跡 ファイル.cpp"
+	os.WriteFile(filepath.Join(devRepo, untracked), []byte("// untracked"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		IncludeExtensions: []string{".cpp"},
+		GitExecutable:     "git",
+		CommitTemplate:    "Auto-sync test",
+		PauseLockFile:     ".sync-paused",
+	}
+
+	result, err := NewFileSyncer(cfg).Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	for _, name := range append(committed, untracked) {
+		content, err := os.ReadFile(filepath.Join(opsRepo, name))
+		if err != nil {
+			t.Errorf("File %q was not synced: %v (added: %q)", name, err, result.FilesAdded)
+			continue
+		}
+		if string(content) != "// "+name && name != untracked {
+			t.Errorf("Unexpected content for %q: %q", name, string(content))
+		}
+	}
+
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = opsRepo
+	output, _ := cmd.Output()
+	if len(strings.TrimSpace(string(output))) != 0 {
+		t.Errorf("Ops repository should be clean after sync, got: %s", output)
+	}
+}
+
+func TestSyncLeavesUnrelatedOpsChanges(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping unrelated changes test")
+	}
+
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryDynamic(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryDynamic(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(devRepo, "main.cpp"), []byte("// main"), 0644)
+
+	// Ops 側にのみ存在するビルド成果物やメモ。
+	os.MkdirAll(filepath.Join(opsRepo, "build"), 0755)
+	os.WriteFile(filepath.Join(opsRepo, "build", "main.o"), []byte("binary"), 0644)
+	os.WriteFile(filepath.Join(opsRepo, "notes.txt"), []byte("memo"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		IncludeExtensions: []string{".cpp"},
+		GitExecutable:     "git",
+		CommitTemplate:    "Auto-sync test",
+		PauseLockFile:     ".sync-paused",
+	}
+
+	syncer := NewFileSyncer(cfg)
+	result, err := syncer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	if len(result.FilesAdded) != 1 || result.FilesAdded[0] != "main.cpp" {
+		t.Errorf("Expected only main.cpp to be added, got %q", result.FilesAdded)
+	}
+	if len(result.UnrelatedChanges) != 2 {
+		t.Errorf("Expected 2 unrelated changes, got %q", result.UnrelatedChanges)
+	}
+
+	cmd := exec.Command("git", "show", "--name-only", "--format=", "HEAD")
+	cmd.Dir = opsRepo
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git show failed: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "main.cpp" {
+		t.Errorf("Sync commit should contain only main.cpp, got: %q", output)
+	}
+
+	// 圧縮時に同期コミットを識別できるよう trailer が付与される。
+	cmd = exec.Command("git", "log", "-1", "--format=%(trailers:key="+CommitTrailer+",valueonly)")
+	cmd.Dir = opsRepo
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("git log failed: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "auto" {
+		t.Errorf("Sync commit should carry the %s trailer, got: %q", CommitTrailer, output)
+	}
+
+	// 同期対象に変化がなければ、無関係な変更が残っていてもコミットしない。
+	result, err = syncer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Second Sync() failed: %v", err)
+	}
+	if result.CommitHash != "" {
+		t.Errorf("Second sync should not create a commit, got %s", result.CommitHash)
+	}
+	if len(result.UnrelatedChanges) != 2 {
+		t.Errorf("Unrelated changes should still be reported, got %q", result.UnrelatedChanges)
+	}
+}
+
+func TestSyncRecoversStaleIndexLock(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping index.lock recovery test")
+	}
+
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryDynamic(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryDynamic(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(devRepo, "main.cpp"), []byte("// main"), 0644)
+
+	// クラッシュした git が残した古い index.lock。
+	lockPath := filepath.Join(opsRepo, ".git", "index.lock")
+	os.WriteFile(lockPath, nil, 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(lockPath, old, old)
+
+	cfg := &config.Config{
+		DevRepoPath:         devRepo,
+		OpsRepoPath:         opsRepo,
+		IncludeExtensions:   []string{".cpp"},
+		GitExecutable:       "git",
+		IndexLockStaleAfter: "1m",
+		CommitTemplate:      "Auto-sync test",
+		PauseLockFile:       ".sync-paused",
+	}
+
+	result, err := NewFileSyncer(cfg).Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync() should recover from a stale index.lock: %v", err)
+	}
+	if result.CommitHash == "" {
+		t.Error("Expected sync commit after recovery")
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Error("Stale index.lock should be removed")
+	}
+}
+
+func TestSyncFailsWhileRepositoryLocked(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping repository lock test")
+	}
+
+	tempDir := t.TempDir()
+	devRepo := filepath.Join(tempDir, "dev")
+	opsRepo := filepath.Join(tempDir, "ops")
+
+	if err := createTestRepositoryDynamic(devRepo); err != nil {
+		t.Fatalf("Failed to create dev repository: %v", err)
+	}
+	if err := createTestRepositoryDynamic(opsRepo); err != nil {
+		t.Fatalf("Failed to create ops repository: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(devRepo, "main.cpp"), []byte("// main"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:       devRepo,
+		OpsRepoPath:       opsRepo,
+		IncludeExtensions: []string{".cpp"},
+		GitExecutable:     "git",
+		CommitTemplate:    "Auto-sync test",
+		PauseLockFile:     ".sync-paused",
+		RepoLockWait:      "0s",
+	}
+
+	// fixup --continuous など、他のプロセスが Ops リポジトリを変更中。
+	lock, err := repolock.NewLocker(cfg, git.NewRunner("git", opsRepo)).Acquire(context.Background(), "fixup")
+	if err != nil {
+		t.Fatalf("Failed to acquire repository lock: %v", err)
+	}
+
+	if _, err := NewFileSyncer(cfg).Sync(context.Background()); !errors.Is(err, repolock.ErrLocked) {
+		t.Fatalf("Sync() should fail while the repository is locked, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(opsRepo, "main.cpp")); !os.IsNotExist(err) {
+		t.Error("Ops repository should not be modified while locked")
+	}
+
+	lock.Release()
+	if _, err := NewFileSyncer(cfg).Sync(context.Background()); err != nil {
+		t.Fatalf("Sync() should succeed after the lock is released: %v", err)
+	}
+}
+
 func createTestRepositoryDynamic(repoPath string) error {
 	if err := os.MkdirAll(repoPath, 0755); err != nil {
 		return err
@@ -1,12 +1,19 @@
 package sync
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"fixup-commit-sync-manager/internal/config"
+	"fixup-commit-sync-manager/internal/git"
+	"fixup-commit-sync-manager/internal/history"
+	"fixup-commit-sync-manager/internal/metrics"
 )
 
 func TestNewFileSyncer(t *testing.T) {
@@ -55,34 +62,6 @@ func TestShouldIncludeFile(t *testing.T) {
 	}
 }
 
-func TestIsExcluded(t *testing.T) {
-	cfg := &config.Config{
-		ExcludePatterns: []string{"bin/**", "obj/**", "*.tmp"},
-	}
-
-	syncer := NewFileSyncer(cfg)
-
-	tests := []struct {
-		filePath string
-		expected bool
-	}{
-		{"main.cpp", false},
-		{"bin/output.exe", true},
-		{"obj/temp.o", true},
-		{"temp.tmp", true},
-		{"src/main.cpp", false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.filePath, func(t *testing.T) {
-			result := syncer.isExcluded(tt.filePath)
-			if result != tt.expected {
-				t.Errorf("isExcluded(%q) = %t, want %t", tt.filePath, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestIsPaused(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -233,3 +212,156 @@ func containsAt(s, substr string) bool {
 	}
 	return false
 }
+
+func TestSyncRecordsFailureCause(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, ".sync-paused"), []byte("paused"), 0644)
+
+	cfg := &config.Config{
+		DevRepoPath:   tempDir,
+		OpsRepoPath:   t.TempDir(),
+		PauseLockFile: ".sync-paused",
+	}
+
+	_, err := NewFileSyncer(cfg).Sync(context.Background())
+	if err == nil {
+		t.Fatal("Sync() should fail while paused")
+	}
+	if got := metrics.Cause(err); got != metrics.CausePaused {
+		t.Errorf("Cause() = %q, want %q", got, metrics.CausePaused)
+	}
+
+	var buf bytes.Buffer
+	metrics.Default.WriteText(&buf)
+	text := buf.String()
+	if !strings.Contains(text, `fcsm_sync_runs_total{result="paused"} `) || strings.Contains(text, `fcsm_sync_runs_total{result="paused"} 0`+"\n") {
+		t.Errorf("Paused sync should be counted as paused:\n%s", text)
+	}
+	if strings.Contains(text, `fcsm_sync_failures_total{cause="paused"} `) {
+		t.Errorf("Paused sync should not be counted as a failure:\n%s", text)
+	}
+}
+
+// TestSyncCanceled は中断済みの context では Ops リポジトリを変更せずに中断として失敗することをテストする。
+func TestSyncCanceled(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	devRepo := t.TempDir()
+	opsRepo := t.TempDir()
+	for _, repo := range []string{devRepo, opsRepo} {
+		if err := exec.Command("git", "init", repo).Run(); err != nil {
+			t.Fatalf("git init failed: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(devRepo, "file.txt"), []byte("content"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = devRepo
+	cfg.OpsRepoPath = opsRepo
+
+	_, err := NewFileSyncer(cfg).Sync(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Sync() should fail with context.Canceled, got %v", err)
+	}
+	if got := metrics.Cause(err); got != metrics.CauseCanceled {
+		t.Errorf("Cause() = %q, want %q", got, metrics.CauseCanceled)
+	}
+	if _, err := os.Stat(filepath.Join(opsRepo, "file.txt")); !os.IsNotExist(err) {
+		t.Error("Canceled sync should not copy files to ops")
+	}
+}
+
+// TestPending は同期を実行せずに、Ops に差分が生じる変更のみを返すことをテストする。
+func TestPending(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	devRepo := t.TempDir()
+	opsRepo := t.TempDir()
+	for _, repo := range []string{devRepo, opsRepo} {
+		if err := exec.Command("git", "init", repo).Run(); err != nil {
+			t.Fatalf("git init failed: %v", err)
+		}
+	}
+	os.WriteFile(filepath.Join(opsRepo, "same.cpp"), []byte("same"), 0644)
+	commit := exec.Command("git", "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-qm", "Base")
+	commit.Dir = opsRepo
+	exec.Command("git", "-C", opsRepo, "add", "same.cpp").Run()
+	if output, err := commit.CombinedOutput(); err != nil {
+		t.Fatalf("git commit failed: %v\n%s", err, output)
+	}
+
+	os.WriteFile(filepath.Join(devRepo, "same.cpp"), []byte("same"), 0644)
+	os.WriteFile(filepath.Join(devRepo, "new.cpp"), []byte("new"), 0644)
+
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = devRepo
+	cfg.OpsRepoPath = opsRepo
+
+	pending, err := NewFileSyncer(cfg).Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Pending() failed: %v", err)
+	}
+	if len(pending.FilesAdded) != 1 || pending.FilesAdded[0] != "new.cpp" || len(pending.FilesModified)+len(pending.FilesDeleted) != 0 {
+		t.Errorf("Only new.cpp should be pending, got %+v", pending)
+	}
+	if _, err := os.Stat(filepath.Join(opsRepo, "new.cpp")); !os.IsNotExist(err) {
+		t.Error("Pending() should not copy files to ops")
+	}
+}
+
+// TestSyncRecordsHistory は変更を同期した回のみを Dev リポジトリの実行履歴に記録することをテストする。
+func TestSyncRecordsHistory(t *testing.T) {
+	if !isGitAvailable() {
+		t.Skip("Git not available, skipping test")
+	}
+
+	devRepo := t.TempDir()
+	opsRepo := t.TempDir()
+	for _, repo := range []string{devRepo, opsRepo} {
+		for _, args := range [][]string{{"init", "-q"}, {"checkout", "-q", "-b", "main"}, {"config", "user.name", "Test"}, {"config", "user.email", "test@example.com"}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v failed: %v\n%s", args, err, output)
+			}
+		}
+	}
+	os.WriteFile(filepath.Join(devRepo, "file.cpp"), []byte("content"), 0644)
+
+	cfg := config.DefaultConfig()
+	cfg.DevRepoPath = devRepo
+	cfg.OpsRepoPath = opsRepo
+	syncer := NewFileSyncer(cfg)
+
+	result, err := syncer.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	// 変更が無い回もファイルとコミットを空として記録する。
+	if _, err := syncer.Sync(context.Background()); err != nil {
+		t.Fatalf("Second Sync() failed: %v", err)
+	}
+
+	entries, err := history.NewLog(git.NewRunner("git", devRepo)).Read(context.Background(), history.Filter{})
+	if err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected two history entries, got %+v", entries)
+	}
+	entry := entries[0]
+	if entry.Type != history.TypeSync || entry.Branch != "main" || entry.Commits[0] != result.CommitHash ||
+		len(entry.Files) != 1 || entry.Files[0] != "file.cpp" || entry.Failed() {
+		t.Errorf("Unexpected history entry: %+v", entry)
+	}
+	if idle := entries[1]; idle.Type != history.TypeSync || idle.Branch != "main" || len(idle.Files) != 0 || len(idle.Commits) != 0 || idle.Failed() {
+		t.Errorf("Unexpected history entry for the sync without changes: %+v", idle)
+	}
+}
@@ -0,0 +1,47 @@
+// Package testutil はテストで使う git リポジトリを作成する補助関数を提供する。
+package testutil
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// GitAvailable は git コマンドが使えるかを返す。
+func GitAvailable() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// InitRepository は dir に git リポジトリを作成し、コミット用のユーザーを設定する。dir が無い場合は作成する。
+func InitRepository(t testing.TB, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", dir, err)
+	}
+	Git(t, dir, "init")
+	Git(t, dir, "config", "user.email", "test@example.com")
+	Git(t, dir, "config", "user.name", "Test User")
+}
+
+// CreateRepository は空の初回コミットを持つ git リポジトリを一時ディレクトリに作成し、そのパスを返す。
+func CreateRepository(t testing.TB) string {
+	t.Helper()
+	repo := t.TempDir()
+	InitRepository(t, repo)
+	Git(t, repo, "commit", "--allow-empty", "-m", "Initial commit")
+	return repo
+}
+
+// Git は dir で git を実行し、前後の空白を除いた標準出力を返す。失敗した場合はテストを終了する。
+func Git(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("Failed to run git %v: %v", args, err)
+	}
+	return strings.TrimSpace(string(output))
+}
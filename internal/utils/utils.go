@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -154,17 +156,15 @@ func RemoveLockFile(path string) error {
 	return nil
 }
 
-func TimedOperation(operation func() error, timeout time.Duration) error {
-	done := make(chan error, 1)
+// TimedOperation は timeout で期限を区切った context を operation に渡して実行する。
+// operation は context の終了で処理を打ち切る必要がある。期限を過ぎて終了した場合はタイムアウトのエラーを返す。
+func TimedOperation(ctx context.Context, timeout time.Duration, operation func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	go func() {
-		done <- operation()
-	}()
-
-	select {
-	case err := <-done:
-		return err
-	case <-time.After(timeout):
-		return fmt.Errorf("operation timed out after %v", timeout)
+	err := operation(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("operation timed out after %v: %w", timeout, err)
 	}
+	return err
 }
@@ -1,6 +1,7 @@
 package vhdx
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,6 +9,9 @@ import (
 	"runtime"
 	"strings"
 	"time"
+
+	"fixup-commit-sync-manager/internal/history"
+	"fixup-commit-sync-manager/internal/metrics"
 )
 
 type VHDXManager struct {
@@ -15,7 +19,9 @@ type VHDXManager struct {
 	MountPoint string
 	Size       string
 	Encrypted  bool
-	handle     VirtualDisk // プラットフォーム固有のVHD handle
+	// History が設定されていれば、スナップショットの作成結果を実行履歴に記録する。
+	History *history.Log
+	handle  VirtualDisk // プラットフォーム固有のVHD handle
 }
 
 func NewVHDXManager(vhdxPath, mountPoint, size string, encrypted bool) *VHDXManager {
@@ -218,12 +224,39 @@ exit
 	return v.executeDiskpartScript(diskpartScript)
 }
 
+// CreateSnapshot は name のスナップショットを作成し、所要時間とサイズをメトリクスに記録する。
+// History が設定されていれば結果を実行履歴にも記録する。
 func (v *VHDXManager) CreateSnapshot(name string) error {
 	if name == "" {
 		name = fmt.Sprintf("snapshot_%d", time.Now().Unix())
 	}
 
 	snapshotPath := v.getSnapshotPath(name)
+	started := time.Now()
+	err := v.createSnapshot(snapshotPath)
+
+	var size int64
+	if err == nil {
+		if info, statErr := os.Stat(snapshotPath); statErr == nil {
+			size = info.Size()
+		}
+	}
+	metrics.ObserveSnapshot(time.Since(started), size, err)
+
+	if v.History != nil {
+		entry := history.Entry{Time: started, Type: history.TypeSnapshot, Duration: time.Since(started), Snapshot: name}
+		if err != nil {
+			entry.Error = err.Error()
+			entry.Cause = metrics.Cause(err)
+		}
+		if historyErr := v.History.Append(context.Background(), entry); historyErr != nil {
+			fmt.Printf("Warning: failed to record snapshot history: %v\n", historyErr)
+		}
+	}
+	return err
+}
+
+func (v *VHDXManager) createSnapshot(snapshotPath string) error {
 
 	// スナップショットディレクトリを作成。
 	snapshotDir := filepath.Dir(snapshotPath)
@@ -627,6 +660,11 @@ func (v *VHDXManager) RollbackToSnapshot(name string) error {
 	return nil
 }
 
+// IsMounted は VHDX がマウントされているかを返す。
+func (v *VHDXManager) IsMounted() bool {
+	return v.isMounted()
+}
+
 func (v *VHDXManager) isMounted() bool {
 	// Windows環境では実際のVHDマウント状態をPowerShellで確認。
 	if runtime.GOOS == "windows" {
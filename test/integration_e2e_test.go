@@ -348,7 +348,7 @@ func testStep_RealSyncOperation(t *testing.T, cfg *config.Config) {
 	
 	// Sync実行。
 	syncMgr := sync.NewFileSyncer(cfg)
-	result, err := syncMgr.Sync()
+	result, err := syncMgr.Sync(context.Background())
 	if err != nil {
 		t.Fatalf("Sync operation failed: %v", err)
 	}
@@ -393,7 +393,7 @@ func testStep_RealFixupOperation(t *testing.T, cfg *config.Config) {
 	
 	// Fixup実行。
 	fixupMgr := fixup.NewFixupManager(cfg)
-	result, err := fixupMgr.RunFixup()
+	result, err := fixupMgr.RunFixup(context.Background())
 	if err != nil {
 		t.Fatalf("Fixup operation failed: %v", err)
 	}
@@ -431,7 +431,7 @@ func testStep_RealIntegratedWorkflow(t *testing.T, cfg *config.Config, devRepo,
 	
 	// Sync実行。
 	syncMgr := sync.NewFileSyncer(cfg)
-	syncResult, err := syncMgr.Sync()
+	syncResult, err := syncMgr.Sync(context.Background())
 	if err != nil {
 		t.Fatalf("Integrated sync failed: %v", err)
 	}
@@ -462,7 +462,7 @@ func testStep_RealIntegratedWorkflow(t *testing.T, cfg *config.Config, devRepo,
 	}
 	
 	fixupMgr := fixup.NewFixupManager(cfg)
-	fixupResult, err := fixupMgr.RunFixup()
+	fixupResult, err := fixupMgr.RunFixup(context.Background())
 	if err != nil {
 		t.Fatalf("Integrated fixup failed: %v", err)
 	}
@@ -639,7 +639,7 @@ func testStep_SimulateContinuousDevelopment(t *testing.T, ctx context.Context, d
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				result, err := syncMgr.Sync()
+				result, err := syncMgr.Sync(context.Background())
 				if err != nil {
 					t.Logf("Sync error: %v", err)
 				} else if len(result.FilesAdded)+len(result.FilesModified)+len(result.FilesDeleted) > 0 {
@@ -660,7 +660,7 @@ func testStep_SimulateContinuousDevelopment(t *testing.T, ctx context.Context, d
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				result, err := fixupMgr.RunFixup()
+				result, err := fixupMgr.RunFixup(context.Background())
 				if err != nil {
 					t.Logf("Fixup error: %v", err)
 				} else if result.FilesModified > 0 {
@@ -272,7 +272,7 @@ func testStep7_SyncOperation(t *testing.T, ctx context.Context, cfg *config.Conf
 	syncManager := sync.NewFileSyncer(cfg)
 	
 	// 同期を1回実行してテスト。
-	result, err := syncManager.Sync()
+	result, err := syncManager.Sync(context.Background())
 	if err != nil {
 		t.Logf("Initial sync failed (expected): %v", err)
 		// 初回は失敗する可能性があるため、ログに記録。
@@ -290,7 +290,7 @@ func testStep7_SyncOperation(t *testing.T, ctx context.Context, cfg *config.Conf
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				_, err := syncManager.Sync()
+				_, err := syncManager.Sync(context.Background())
 				if err != nil {
 					t.Logf("Sync error: %v", err)
 				} else {
@@ -310,7 +310,7 @@ func testStep8_FixupOperation(t *testing.T, ctx context.Context, cfg *config.Con
 	fixupManager := fixup.NewFixupManager(cfg)
 	
 	// Fixupを1回実行してテスト。
-	result, err := fixupManager.RunFixup()
+	result, err := fixupManager.RunFixup(context.Background())
 	if err != nil {
 		t.Logf("Initial fixup failed (expected): %v", err)
 		// 初回は失敗する可能性があるため、ログに記録。
@@ -328,7 +328,7 @@ func testStep8_FixupOperation(t *testing.T, ctx context.Context, cfg *config.Con
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				_, err := fixupManager.RunFixup()
+				_, err := fixupManager.RunFixup(context.Background())
 				if err != nil {
 					t.Logf("Fixup error: %v", err)
 				} else {
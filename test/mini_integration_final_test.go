@@ -66,7 +66,7 @@ func TestMini12_SimpleSyncTest(t *testing.T) {
 	}
 	
 	// 同期実行
-	result, err := syncManager.Sync()
+	result, err := syncManager.Sync(context.Background())
 	if err != nil {
 		t.Logf("Sync failed (may be expected): %v", err)
 		// 同期失敗は許容（設定や環境による）
@@ -112,7 +112,7 @@ func TestMini13_SimpleFixupTest(t *testing.T) {
 	fixupManager := fixup.NewFixupManager(cfg)
 	
 	// Fixup実行
-	result, err := fixupManager.RunFixup()
+	result, err := fixupManager.RunFixup(context.Background())
 	if err != nil {
 		t.Logf("Fixup failed (may be expected): %v", err)
 		// Fixup失敗は許容（変更がない場合など）
@@ -219,7 +219,7 @@ func TestMini14_TimedOperationTest(t *testing.T) {
 			case <-ticker.C:
 				stats.syncs++
 				
-				_, err := syncManager.Sync()
+				_, err := syncManager.Sync(context.Background())
 				if err != nil {
 					t.Logf("  Sync %d failed: %v", stats.syncs, err)
 				} else {
@@ -242,7 +242,7 @@ func TestMini14_TimedOperationTest(t *testing.T) {
 			case <-ticker.C:
 				stats.fixups++
 				
-				_, err := fixupManager.RunFixup()
+				_, err := fixupManager.RunFixup(context.Background())
 				if err != nil {
 					t.Logf("  Fixup %d failed: %v", stats.fixups, err)
 				} else {
@@ -408,7 +408,7 @@ func performPeriodicSync(t *testing.T, ctx context.Context, cfg *config.Config)
 		case <-ticker.C:
 			count++
 			
-			_, err := syncManager.Sync()
+			_, err := syncManager.Sync(context.Background())
 			if err != nil {
 				t.Logf("  Sync %d failed: %v", count, err)
 			} else {
@@ -431,7 +431,7 @@ func performPeriodicFixup(t *testing.T, ctx context.Context, cfg *config.Config)
 		case <-ticker.C:
 			count++
 			
-			_, err := fixupManager.RunFixup()
+			_, err := fixupManager.RunFixup(context.Background())
 			if err != nil {
 				t.Logf("  Fixup %d failed: %v", count, err)
 			} else {